@@ -1,27 +1,66 @@
 package gogi
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/dejaniskra/go-gi/internal/config"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
+// Application is ready to use as a zero value; NewApplication is kept for
+// callers that prefer a constructor.
 type Application struct {
-	httpServer *HttpServer
+	httpServer    *HttpServer
+	handleSignals bool
+	shutdownOnce  sync.Once
+	shutdownInit  sync.Once
+	shutdownDone  chan struct{}
+	shutdownErr   error
+	health        *healthChecker
+	healthOnce    sync.Once
+
+	tracerProvider *sdktrace.TracerProvider
 }
 
 func NewApplication() *Application {
 	return &Application{}
 }
 
-func (application *Application) AddRoute(method HTTPMethod, path string, handler HTTPHandler) {
+func (application *Application) AddRoute(method HTTPMethod, path string, handler HTTPHandler, opts ...RouteOption) {
+	httpServer := getServer()
+	if application.httpServer == nil {
+		application.httpServer = httpServer
+	} // TODO: revisit this
+	httpServer.addRoute(nil, method, path, httpHandler(handler), opts...)
+}
+
+// AddWebSocket registers a GET route that upgrades to a WebSocket connection.
+// Global, group and route middleware run before the upgrade.
+func (application *Application) AddWebSocket(path string, handler WebSocketHandler, opts ...RouteOption) {
 	httpServer := getServer()
 	if application.httpServer == nil {
 		application.httpServer = httpServer
 	} // TODO: revisit this
-	httpServer.addRoute(method, path, handler)
+	httpServer.addWebSocket(nil, path, handler, opts...)
+}
+
+// Static serves the files in fsys, such as an embed.FS or os.DirFS, under
+// prefix for GET and HEAD requests. Configure it with WithStaticConfig.
+func (application *Application) Static(prefix string, fsys fs.FS, opts ...RouteOption) {
+	httpServer := getServer()
+	if application.httpServer == nil {
+		application.httpServer = httpServer
+	} // TODO: revisit this
+	httpServer.addStatic(nil, prefix, fsys, opts...)
 }
 
 func (application *Application) AddMiddleware(mw func(http.Handler) http.Handler) {
@@ -32,6 +71,27 @@ func (application *Application) AddMiddleware(mw func(http.Handler) http.Handler
 	httpServer.addMiddleware(mw)
 }
 
+func (application *Application) Group(prefix string) *RouteGroup {
+	httpServer := getServer()
+	if application.httpServer == nil {
+		application.httpServer = httpServer
+	} // TODO: revisit this
+	return &RouteGroup{httpServer: httpServer, prefix: prefix}
+}
+
+// Routes lists every registered route in registration order.
+func (application *Application) Routes() []RouteInfo {
+	if application.httpServer == nil {
+		return nil
+	}
+
+	routes := make([]RouteInfo, 0, len(application.httpServer.routes))
+	for _, route := range application.httpServer.routes {
+		routes = append(routes, route.RouteInfo)
+	}
+	return routes
+}
+
 func (application *Application) AddCronJob(name, cronExpr string, fn func()) error {
 	return addJobCron(name, cronExpr, fn)
 }
@@ -40,6 +100,15 @@ func (application *Application) AddIntervalJob(name string, interval time.Durati
 	return addJobInterval(name, interval, fn)
 }
 
+// EnableGracefulShutdown makes Start shut the application down on SIGINT or
+// SIGTERM, waiting at most http.timeouts.shutdown seconds, and return once
+// the shutdown has finished.
+func (application *Application) EnableGracefulShutdown() {
+	application.handleSignals = true
+}
+
+// Start serves HTTP until the application is shut down. It returns
+// http.ErrServerClosed if Shutdown was called before Start.
 func (application *Application) Start() error {
 	if application.httpServer == nil {
 		return fmt.Errorf("no need to start an empty application")
@@ -47,13 +116,106 @@ func (application *Application) Start() error {
 
 	cfg := config.GetConfig()
 
-	if application.httpServer != nil {
-		err := application.httpServer.start(cfg)
-		if err != nil {
-			fmt.Println("Error starting HTTP server:", err)
-			return err
+	var signalled chan error
+	if application.handleSignals {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(signals)
+
+		stopped := make(chan struct{})
+		defer close(stopped)
+
+		signalled = make(chan error, 1)
+		go func() {
+			select {
+			case <-signals:
+			case <-stopped:
+				return
+			}
+			GetLogger().Info("Shutdown signal received, draining")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(*cfg.Http.Timeouts.Shutdown)*time.Second)
+			defer cancel()
+			signalled <- application.Shutdown(shutdownCtx)
+		}()
+	}
+
+	err := application.httpServer.start(cfg)
+	if errors.Is(err, errShutDownBeforeStart) {
+		return http.ErrServerClosed
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		if signalled != nil {
+			select {
+			case err := <-signalled:
+				return err
+			case <-application.done():
+				return application.shutdownErr
+			}
 		}
+		return nil
+	}
+	if err != nil {
+		fmt.Println("Error starting HTTP server:", err)
+		return err
 	}
 
 	return nil
 }
+
+// Shutdown stops accepting connections, waits for in-flight requests and
+// running jobs, then closes every registered database and cache client and
+// flushes pending spans. It returns ctx.Err() if ctx expires first. Calling
+// it more than once waits for the first call to finish.
+func (application *Application) Shutdown(ctx context.Context) error {
+	done := application.done()
+	application.shutdownOnce.Do(func() {
+		go func() {
+			application.shutdownErr = application.shutdown(ctx)
+			close(done)
+		}()
+	})
+
+	select {
+	case <-done:
+		return application.shutdownErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// done is closed once the first Shutdown call has finished.
+func (application *Application) done() chan struct{} {
+	application.shutdownInit.Do(func() {
+		application.shutdownDone = make(chan struct{})
+	})
+	return application.shutdownDone
+}
+
+func (application *Application) shutdown(ctx context.Context) error {
+	var errs []error
+
+	if application.httpServer != nil {
+		if err := application.httpServer.shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("http server: %w", err))
+		}
+	}
+
+	if err := stopScheduler(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("scheduler: %w", err))
+	}
+
+	errs = append(errs,
+		closeMySQLClients(),
+		closePostgresClients(),
+		closeMongoClients(ctx),
+		closeRedisClients(),
+	)
+
+	if application.tracerProvider != nil {
+		if err := application.tracerProvider.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("tracer provider: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
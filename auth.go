@@ -0,0 +1,245 @@
+package gogi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AuthJWT    = "jwt"
+	AuthAPIKey = "api_key"
+
+	apiKeyDefaultHeader = "X-API-Key"
+
+	claimsKey contextKey = "claims"
+)
+
+// Claims describes who a request is authenticated as. Method is AuthJWT or
+// AuthAPIKey. Metadata is free-form data an APIKeyStore attaches; the full
+// token payload of a JWT is available through Decode.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	ID        string
+	Scopes    []string
+	Roles     []string
+	Method    string
+	Metadata  map[string]any
+	raw       []byte
+}
+
+func (claims *Claims) HasScope(scope string) bool {
+	return slices.Contains(claims.Scopes, scope)
+}
+
+func (claims *Claims) HasRole(role string) bool {
+	return slices.Contains(claims.Roles, role)
+}
+
+// Decode unmarshals the JWT payload, or the Metadata of an API key, into v,
+// for claims Claims does not model.
+func (claims *Claims) Decode(v any) error {
+	data := claims.raw
+	if data == nil {
+		var err error
+		if data, err = json.Marshal(claims.Metadata); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ClaimsFromContext returns the claims Authenticate stored for the request.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*Claims)
+	return claims, ok
+}
+
+// APIKeyStore resolves API keys. LookupAPIKey returns nil claims and a nil
+// error for unknown keys; an error means the store itself failed.
+type APIKeyStore interface {
+	LookupAPIKey(ctx context.Context, key string) (*Claims, error)
+}
+
+type staticAPIKeyStore map[[sha256.Size]byte]Claims
+
+// NewStaticAPIKeyStore serves a fixed set of keys. Keys are held as hashes,
+// so lookups do not leak how much of a guessed key matched.
+func NewStaticAPIKeyStore(keys map[string]Claims) APIKeyStore {
+	store := make(staticAPIKeyStore, len(keys))
+	for key, claims := range keys {
+		store[sha256.Sum256([]byte(key))] = claims
+	}
+	return store
+}
+
+func (store staticAPIKeyStore) LookupAPIKey(ctx context.Context, key string) (*Claims, error) {
+	claims, ok := store[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, nil
+	}
+	return &claims, nil
+}
+
+// AuthConfig configures Authenticate. JWT enables bearer tokens and APIKeys
+// enables keys sent in APIKeyHeader (default X-API-Key); either or both may
+// be set. Requests without credentials are rejected unless Optional is set,
+// in which case they continue anonymously and routes can still demand
+// credentials with RequireScopes or RequireRoles. Invalid credentials are
+// always rejected.
+type AuthConfig struct {
+	JWT          *JWTConfig
+	APIKeys      APIKeyStore
+	APIKeyHeader string
+	Optional     bool
+}
+
+// Authenticate returns a middleware that verifies the request's bearer
+// token or API key and stores its claims for ClaimsFromContext,
+// HTTPServerRequest.Claims and the RateLimitBySubject key. Failures are
+// answered with 401 and a WWW-Authenticate challenge. It panics if the JWT
+// configuration is invalid.
+func Authenticate(config AuthConfig) MiddlewareHandler {
+	var verifier *JWTVerifier
+	if config.JWT != nil {
+		var err error
+		if verifier, err = NewJWTVerifier(*config.JWT); err != nil {
+			panic(err.Error())
+		}
+	}
+	if verifier == nil && config.APIKeys == nil {
+		panic("authentication requires JWT or APIKeys")
+	}
+	if config.APIKeyHeader == "" {
+		config.APIKeyHeader = apiKeyDefaultHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				claims *Claims
+				err    error
+			)
+			if token, ok := bearerToken(r); ok && verifier != nil {
+				if claims, err = verifier.Verify(r.Context(), token); err != nil {
+					GetLogger().Debug(fmt.Sprintf("[Auth] %s %s: %v", r.Method, r.URL.Path, err))
+					writeUnauthorized(w, r, `Bearer error="invalid_token"`, "The access token is invalid.")
+					return
+				}
+			} else if key := r.Header.Get(config.APIKeyHeader); key != "" && config.APIKeys != nil {
+				if claims, err = config.APIKeys.LookupAPIKey(r.Context(), key); err != nil {
+					writeHTTPProblem(w, r, fmt.Errorf("looking up API key: %w", err))
+					return
+				}
+				if claims == nil {
+					writeUnauthorized(w, r, "", "The API key is invalid.")
+					return
+				}
+				// The store may hand out shared claims, so they are copied
+				// before anything is filled in.
+				copied := *claims
+				claims = &copied
+				if claims.Method == "" {
+					claims.Method = AuthAPIKey
+				}
+			}
+
+			if claims == nil {
+				if config.Optional {
+					next.ServeHTTP(w, r)
+					return
+				}
+				writeUnauthorized(w, r, "", "Authentication is required.")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), claimsKey, claims)
+			if claims.Subject != "" {
+				ctx = ContextWithSubject(ctx, claims.Subject)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScopes rejects requests whose claims lack any of scopes, with 401
+// when there are no claims and 403 otherwise. It needs Authenticate to run
+// first, globally, in a group or as an earlier WithMiddleware.
+func RequireScopes(scopes ...string) RouteOption {
+	return WithMiddleware(requireClaims(func(claims *Claims) error {
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				return &HTTPError{
+					Status:  http.StatusForbidden,
+					Code:    "insufficient_scope",
+					Message: "The credentials lack a required scope.",
+					Details: map[string][]string{"required_scopes": scopes},
+				}
+			}
+		}
+		return nil
+	}, fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopes, " "))))
+}
+
+// RequireRoles rejects requests whose claims hold none of roles, like
+// RequireScopes. One matching role is enough.
+func RequireRoles(roles ...string) RouteOption {
+	return WithMiddleware(requireClaims(func(claims *Claims) error {
+		if slices.ContainsFunc(roles, claims.HasRole) {
+			return nil
+		}
+		return &HTTPError{
+			Status:  http.StatusForbidden,
+			Code:    "forbidden",
+			Message: "The credentials lack a required role.",
+			Details: map[string][]string{"required_roles": roles},
+		}
+	}, ""))
+}
+
+func requireClaims(check func(*Claims) error, challenge string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				writeUnauthorized(w, r, "", "Authentication is required.")
+				return
+			}
+			if err := check(claims); err != nil {
+				if challenge != "" && claims.Method == AuthJWT {
+					w.Header().Set("WWW-Authenticate", challenge)
+				}
+				writeHTTPProblem(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, challenge, message string) {
+	if challenge == "" {
+		challenge = "Bearer"
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeHTTPProblem(w, r, NewHTTPError(http.StatusUnauthorized, "unauthorized", message))
+}
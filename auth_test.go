@@ -0,0 +1,141 @@
+package gogi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type sharedAPIKeyStore struct {
+	claims *Claims
+}
+
+func (store sharedAPIKeyStore) LookupAPIKey(ctx context.Context, key string) (*Claims, error) {
+	if key != "shared-key" {
+		return nil, nil
+	}
+	return store.claims, nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	withScope := validClaims()
+	withScope["scope"] = "read write"
+	withRole := validClaims()
+	withRole["roles"] = []string{"admin"}
+
+	keys := NewStaticAPIKeyStore(map[string]Claims{
+		"reader-key": {Subject: "svc-reader", Scopes: []string{"read"}},
+		"admin-key":  {Subject: "svc-admin", Roles: []string{"admin"}},
+	})
+	config := AuthConfig{JWT: &JWTConfig{Secret: testJWTSecret}, APIKeys: keys}
+
+	server := &HttpServer{router: newRouter()}
+	whoami := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		w.Write([]byte(claims.Subject + " " + claims.Method))
+	})
+	server.addRoute(nil, HTTP_GET, "/me", whoami, WithMiddleware(Authenticate(config)))
+	server.addRoute(nil, HTTP_GET, "/write", whoami, WithMiddleware(Authenticate(config)), RequireScopes("write"))
+	server.addRoute(nil, HTTP_GET, "/admin", whoami, WithMiddleware(Authenticate(config)), RequireRoles("admin", "owner"))
+	optional := config
+	optional.Optional = true
+	server.addRoute(nil, HTTP_GET, "/optional", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := ClaimsFromContext(r.Context()); ok {
+			w.Write([]byte("signed in"))
+			return
+		}
+		w.Write([]byte("anonymous"))
+	}), WithMiddleware(Authenticate(optional)))
+	server.addRoute(nil, HTTP_GET, "/optional-write", whoami, WithMiddleware(Authenticate(optional)), RequireScopes("write"))
+	handler := server.handler()
+
+	tests := []struct {
+		name      string
+		path      string
+		token     string
+		apiKey    string
+		status    int
+		body      string
+		challenge string
+	}{
+		{name: "no credentials", path: "/me", status: http.StatusUnauthorized, challenge: "Bearer"},
+		{name: "invalid token", path: "/me", token: "not.a.jwt", status: http.StatusUnauthorized, challenge: `Bearer error="invalid_token"`},
+		{name: "valid token", path: "/me", token: signHS256(testJWTSecret, validClaims()), status: http.StatusOK, body: "user-1 jwt"},
+		{name: "unknown API key", path: "/me", apiKey: "nope", status: http.StatusUnauthorized, challenge: "Bearer"},
+		{name: "valid API key", path: "/me", apiKey: "reader-key", status: http.StatusOK, body: "svc-reader api_key"},
+		{name: "token without scope", path: "/write", token: signHS256(testJWTSecret, validClaims()), status: http.StatusForbidden, challenge: `Bearer error="insufficient_scope", scope="write"`},
+		{name: "token with scope", path: "/write", token: signHS256(testJWTSecret, withScope), status: http.StatusOK, body: "user-1 jwt"},
+		{name: "API key without scope", path: "/write", apiKey: "reader-key", status: http.StatusForbidden},
+		{name: "token without role", path: "/admin", token: signHS256(testJWTSecret, withScope), status: http.StatusForbidden},
+		{name: "token with role", path: "/admin", token: signHS256(testJWTSecret, withRole), status: http.StatusOK, body: "user-1 jwt"},
+		{name: "API key with role", path: "/admin", apiKey: "admin-key", status: http.StatusOK, body: "svc-admin api_key"},
+		{name: "optional anonymous", path: "/optional", status: http.StatusOK, body: "anonymous"},
+		{name: "optional signed in", path: "/optional", apiKey: "reader-key", status: http.StatusOK, body: "signed in"},
+		{name: "optional invalid token", path: "/optional", token: "not.a.jwt", status: http.StatusUnauthorized, challenge: `Bearer error="invalid_token"`},
+		{name: "optional anonymous needing scope", path: "/optional-write", status: http.StatusUnauthorized, challenge: "Bearer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.apiKey != "" {
+				r.Header.Set("X-API-Key", tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.challenge {
+				t.Errorf("WWW-Authenticate %q, want %q", got, tt.challenge)
+			}
+			if tt.status >= http.StatusBadRequest && w.Header().Get("Content-Type") != problemContentType {
+				t.Errorf("Content-Type %q, want a problem", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAuthenticateCopiesAPIKeyClaims(t *testing.T) {
+	shared := &Claims{Subject: "svc", Metadata: map[string]any{"tier": "gold"}}
+	handler := Authenticate(AuthConfig{APIKeys: sharedAPIKeyStore{claims: shared}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := ClaimsFromContext(r.Context())
+		if claims == shared || claims.Method != AuthAPIKey {
+			t.Errorf("claims %+v, want a copy with Method set", claims)
+		}
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-API-Key", "shared-key")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if shared.Method != "" {
+		t.Fatalf("store claims changed to Method %q", shared.Method)
+	}
+}
+
+func TestAuthenticateConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AuthConfig
+	}{
+		{name: "no methods", config: AuthConfig{}},
+		{name: "invalid JWT", config: AuthConfig{JWT: &JWTConfig{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			Authenticate(tt.config)
+		})
+	}
+}
@@ -0,0 +1,573 @@
+package gogi
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes one field that could not be bound or failed
+// validation. Source is "body", "path", "query" or "header".
+type FieldError struct {
+	Field   string `json:"field"`
+	Source  string `json:"source"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// BindError lists every field that failed. StatusCode is 400 when the
+// request could not be decoded, 415 for an unsupported body type and 422
+// when decoding worked but validation did not.
+type BindError struct {
+	StatusCode int          `json:"-"`
+	Errors     []FieldError `json:"errors"`
+}
+
+func (e *BindError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		parts[i] = fieldErr.Field + " " + fieldErr.Message
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// Bind builds a T from the request. The body is decoded first with the
+// codec registered for its Content-Type (JSON when absent), then
+// fields tagged `path:"id"`, `query:"limit"` or `header:"X-Tenant"` are
+// filled from the matching source, converting strings to the field type.
+// Multipart bodies fill fields by their `form` tag (or json name), with
+// *UploadedFile and []*UploadedFile fields receiving the stored files.
+// Finally `validate:"required,min=1,email"` rules are checked; omitempty
+// skips the remaining rules when the field is empty.
+//
+// Supported rules: required, omitempty, min, max, len, oneof, email, url,
+// uuid, alpha, alphanum and numeric. A malformed rule, or a path, query or
+// header field of a type strings cannot be converted into, is returned as a
+// plain error, which WriteProblem answers with 500. So is a form or multipart
+// value sent for such a field.
+func Bind[T any](req *HTTPServerRequest) (T, error) {
+	var result T
+	err := bind(req, &result)
+	return result, err
+}
+
+func bind(req *HTTPServerRequest, dest any) error {
+	if err := checkBindType(reflect.TypeOf(dest).Elem()); err != nil {
+		return err
+	}
+	if err := decodeBody(req, dest); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(dest).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	if errs := bindParams(req, v); len(errs) > 0 {
+		return &BindError{StatusCode: http.StatusBadRequest, Errors: errs}
+	}
+	if errs := validateValue(v, ""); len(errs) > 0 {
+		return &BindError{StatusCode: http.StatusUnprocessableEntity, Errors: errs}
+	}
+	return nil
+}
+
+func decodeBody(req *HTTPServerRequest, dest any) error {
+	if req.Body == nil {
+		return nil
+	}
+
+	var codec Codec = JSONCodec{}
+	if contentType := req.Headers["Content-Type"]; contentType != "" {
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "multipart/form-data" {
+			return decodeMultipart(req, dest)
+		}
+		var ok bool
+		codec, ok = codecForContentType(contentType)
+		if !ok {
+			mediaType, _, _ := mime.ParseMediaType(contentType)
+			return &BindError{
+				StatusCode: http.StatusUnsupportedMediaType,
+				Errors: []FieldError{{
+					Field:   "body",
+					Source:  "body",
+					Rule:    "content_type",
+					Message: fmt.Sprintf("unsupported content type %q", mediaType),
+				}},
+			}
+		}
+	}
+
+	err := codec.Decode(req.Body, dest)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || errors.Is(err, errUnsupportedFieldType) {
+		return err
+	}
+
+	field := "body"
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		field = typeErr.Field
+	}
+	return &BindError{
+		StatusCode: http.StatusBadRequest,
+		Errors:     []FieldError{{Field: field, Source: "body", Rule: "decode", Message: err.Error()}},
+	}
+}
+
+func bindParams(req *HTTPServerRequest, v reflect.Value) []FieldError {
+	var errs []FieldError
+	for _, field := range bindPlanFor(v.Type()).fields {
+		if field.source == "" {
+			continue
+		}
+
+		var values []string
+		switch field.source {
+		case "path":
+			if value, ok := req.PathParams[field.key]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = req.QueryAll(field.key)
+		case "header":
+			values = req.HeaderValues(field.key)
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		target, err := v.FieldByIndexErr(field.index)
+		if err != nil {
+			continue
+		}
+		if err := setFieldValues(target, values); err != nil {
+			errs = append(errs, FieldError{
+				Field:   field.name,
+				Source:  field.source,
+				Rule:    "type",
+				Message: err.Error(),
+			})
+		}
+	}
+	return errs
+}
+
+func validateValue(v reflect.Value, prefix string) []FieldError {
+	var errs []FieldError
+	for _, field := range bindPlanFor(v.Type()).fields {
+		fv, err := v.FieldByIndexErr(field.index)
+		if err != nil {
+			continue
+		}
+
+		name := prefix + field.name
+		source := field.source
+		if source == "" {
+			source = "body"
+		}
+		for _, rule := range field.rules {
+			if rule.name == "omitempty" {
+				if isEmpty(fv) {
+					break
+				}
+				continue
+			}
+			if message, ok := rule.check(fv); !ok {
+				errs = append(errs, FieldError{Field: name, Source: source, Rule: rule.name, Message: message})
+			}
+		}
+
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		switch {
+		case isNestedStruct(fv.Type()):
+			errs = append(errs, validateValue(fv, name+".")...)
+		case fv.Kind() == reflect.Slice && isNestedStruct(derefType(fv.Type().Elem())):
+			for i := 0; i < fv.Len(); i++ {
+				elem := fv.Index(i)
+				for elem.Kind() == reflect.Pointer && !elem.IsNil() {
+					elem = elem.Elem()
+				}
+				if elem.Kind() == reflect.Struct {
+					errs = append(errs, validateValue(elem, fmt.Sprintf("%s[%d].", name, i))...)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+type bindPlan struct {
+	fields []bindField
+	err    error // the first malformed validate tag
+}
+
+type bindField struct {
+	index  []int
+	name   string
+	source string // "path", "query", "header" or "" for body-only fields
+	key    string
+	rules  []validationRule
+}
+
+var bindPlans sync.Map // reflect.Type -> *bindPlan
+
+func bindPlanFor(t reflect.Type) *bindPlan {
+	if plan, ok := bindPlans.Load(t); ok {
+		return plan.(*bindPlan)
+	}
+
+	plan := &bindPlan{}
+	for _, sf := range reflect.VisibleFields(t) {
+		if sf.Anonymous || !sf.IsExported() {
+			continue
+		}
+
+		field := bindField{index: sf.Index, name: jsonFieldName(sf)}
+		for _, source := range []string{"path", "query", "header"} {
+			if key := sf.Tag.Get(source); key != "" {
+				field.source = source
+				field.key = key
+				field.name = key
+				break
+			}
+		}
+		if field.name == "" {
+			continue
+		}
+		rules, err := parseValidationRules(t, sf)
+		if err != nil && plan.err == nil {
+			plan.err = err
+		}
+		field.rules = rules
+		plan.fields = append(plan.fields, field)
+	}
+
+	actual, _ := bindPlans.LoadOrStore(t, plan)
+	return actual.(*bindPlan)
+}
+
+var bindChecks sync.Map // reflect.Type -> error
+
+// checkBindType reports the first malformed validate tag or unsupported
+// path, query or header field type on t or the structs it nests, so a bad
+// tag fails Handle at registration and a direct Bind with a 500 rather than
+// a panic mid-request.
+func checkBindType(t reflect.Type) error {
+	t = derefType(t)
+	if !isNestedStruct(t) {
+		return nil
+	}
+	if cached, ok := bindChecks.Load(t); ok {
+		err, _ := cached.(error)
+		return err
+	}
+
+	err := checkBindStruct(t, make(map[reflect.Type]bool))
+	bindChecks.Store(t, err)
+	return err
+}
+
+func checkBindStruct(t reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	plan := bindPlanFor(t)
+	if plan.err != nil {
+		return plan.err
+	}
+	for _, field := range plan.fields {
+		sf := t.FieldByIndex(field.index)
+		if field.source != "" && !paramTypeSupported(sf.Type) {
+			return fmt.Errorf("%s.%s: unsupported %s parameter type %s", t, sf.Name, field.source, sf.Type)
+		}
+		ft := derefType(sf.Type)
+		if ft.Kind() == reflect.Slice {
+			ft = derefType(ft.Elem())
+		}
+		if isNestedStruct(ft) {
+			if err := checkBindStruct(ft, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func jsonFieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return sf.Name
+	default:
+		return name
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func setFieldValues(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setFieldValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setFieldValue(field, values[0])
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// errUnsupportedFieldType marks a field no string converts into. That is a
+// fault in the destination type rather than the request, so it is not
+// reported as a BindError.
+var errUnsupportedFieldType = errors.New("unsupported field type")
+
+// paramTypeSupported reports whether setFieldValues can fill a field of
+// type t.
+func paramTypeSupported(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+	}
+	t = derefType(t)
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func setFieldValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setFieldValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := unmarshaler.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid value %q", value)
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be a boolean")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Type() == durationType {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("must be a duration")
+			}
+			field.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a non-negative integer")
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("%w %s", errUnsupportedFieldType, field.Type())
+	}
+	return nil
+}
+
+type validationRule struct {
+	name  string
+	param string
+	limit float64
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	alphaPattern    = regexp.MustCompile(`^[A-Za-z]+$`)
+	alphanumPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	numericPattern  = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+)
+
+func parseValidationRules(t reflect.Type, sf reflect.StructField) ([]validationRule, error) {
+	tag := sf.Tag.Get("validate")
+	if tag == "" {
+		return nil, nil
+	}
+
+	var rules []validationRule
+	for _, part := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		rule := validationRule{name: name, param: param}
+		switch name {
+		case "required", "omitempty", "email", "url", "uuid", "alpha", "alphanum", "numeric", "oneof":
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				return rules, fmt.Errorf("%s.%s: invalid %s value %q", t, sf.Name, name, param)
+			}
+			rule.limit = limit
+		default:
+			return rules, fmt.Errorf("%s.%s: unknown validation rule %q", t, sf.Name, name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (rule validationRule) check(v reflect.Value) (string, bool) {
+	if rule.name == "required" {
+		if isEmpty(v) {
+			return "is required", false
+		}
+		return "", true
+	}
+
+	// Absent optional values have nothing to check.
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", true
+		}
+		v = v.Elem()
+	}
+
+	switch rule.name {
+	case "min", "max", "len":
+		size, isLength := ruleSize(v)
+		subject := "must be"
+		if isLength {
+			subject = "length must be"
+		}
+		switch {
+		case rule.name == "min" && size < rule.limit:
+			return fmt.Sprintf("%s at least %s", subject, rule.param), false
+		case rule.name == "max" && size > rule.limit:
+			return fmt.Sprintf("%s at most %s", subject, rule.param), false
+		case rule.name == "len" && size != rule.limit:
+			return fmt.Sprintf("%s exactly %s", subject, rule.param), false
+		}
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(rule.param) {
+			if value == option {
+				return "", true
+			}
+		}
+		return fmt.Sprintf("must be one of [%s]", rule.param), false
+	case "email":
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() {
+			return "must be a valid email address", false
+		}
+	case "url":
+		u, err := url.ParseRequestURI(v.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be a valid URL", false
+		}
+	case "uuid":
+		if !uuidPattern.MatchString(v.String()) {
+			return "must be a valid UUID", false
+		}
+	case "alpha":
+		if !alphaPattern.MatchString(v.String()) {
+			return "must contain only letters", false
+		}
+	case "alphanum":
+		if !alphanumPattern.MatchString(v.String()) {
+			return "must contain only letters and digits", false
+		}
+	case "numeric":
+		if !numericPattern.MatchString(v.String()) {
+			return "must be numeric", false
+		}
+	}
+	return "", true
+}
+
+func isEmpty(v reflect.Value) bool {
+	return v.IsZero() || ((v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0)
+}
+
+// ruleSize returns what min, max and len compare against: the value of a
+// number, or the length of a string, slice or map.
+func ruleSize(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
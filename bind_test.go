@@ -0,0 +1,169 @@
+package gogi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindValidInput struct {
+	ID    int    `path:"id" validate:"min=1"`
+	Email string `json:"email" validate:"required,email"`
+}
+
+type bindUnknownRule struct {
+	Name string `json:"name" validate:"required,shiny"`
+}
+
+type bindBadLimit struct {
+	Name string `json:"name" validate:"max=ten"`
+}
+
+type bindNestedBad struct {
+	Items []*bindBadLimit `json:"items"`
+}
+
+type bindRecursive struct {
+	Name     string          `json:"name" validate:"required"`
+	Children []bindRecursive `json:"children"`
+}
+
+type bindParamTypes struct {
+	ID      *int          `path:"id"`
+	Tags    []string      `query:"tag"`
+	Since   time.Time     `query:"since"`
+	Wait    time.Duration `query:"wait"`
+	Client  netip.Addr    `header:"X-Client"`
+	Ratio   float32       `query:"ratio"`
+	Enabled bool          `query:"enabled"`
+}
+
+type bindMapParam struct {
+	Filter map[string]string `query:"filter"`
+}
+
+type bindNestedMapParam struct {
+	Page struct {
+		Cursor []byte `header:"X-Cursor"`
+	} `json:"page"`
+}
+
+type bindMapFormField struct {
+	Name  string            `json:"name"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+func TestCheckBindType(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     reflect.Type
+		wantErr string
+	}{
+		{name: "valid", typ: reflect.TypeFor[bindValidInput]()},
+		{name: "pointer", typ: reflect.TypeFor[*bindValidInput]()},
+		{name: "not a struct", typ: reflect.TypeFor[[]string]()},
+		{name: "recursive", typ: reflect.TypeFor[bindRecursive]()},
+		{name: "unknown rule", typ: reflect.TypeFor[bindUnknownRule](), wantErr: `unknown validation rule "shiny"`},
+		{name: "bad limit", typ: reflect.TypeFor[bindBadLimit](), wantErr: `invalid max value "ten"`},
+		{name: "nested", typ: reflect.TypeFor[bindNestedBad](), wantErr: `invalid max value "ten"`},
+		{name: "parameter types", typ: reflect.TypeFor[bindParamTypes]()},
+		{name: "map parameter", typ: reflect.TypeFor[bindMapParam](), wantErr: "unsupported query parameter type map[string]string"},
+		{name: "nested byte slice parameter", typ: reflect.TypeFor[bindNestedMapParam](), wantErr: "unsupported header parameter type []uint8"},
+		{name: "map body field", typ: reflect.TypeFor[bindMapFormField]()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBindType(tt.typ)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandlePanicsOnMalformedValidateTag(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "shiny") {
+			t.Fatalf("recovered %v, want a panic naming the rule", r)
+		}
+	}()
+	Handle(func(ctx context.Context, in bindUnknownRule) (NoContent, error) {
+		return NoContent{}, nil
+	})
+}
+
+func TestHandlePanicsOnUnsupportedParameterType(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "bindMapParam.Filter") {
+			t.Fatalf("recovered %v, want a panic naming the field", r)
+		}
+	}()
+	Handle(func(ctx context.Context, in bindMapParam) (NoContent, error) {
+		return NoContent{}, nil
+	})
+}
+
+func TestBindUnsupportedFormFieldIs500(t *testing.T) {
+	handler := httpHandler(Handle(func(ctx context.Context, in bindMapFormField) (NoContent, error) {
+		return NoContent{}, nil
+	}))
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "field not sent", body: "name=x", status: http.StatusNoContent},
+		{name: "field sent", body: "name=x&attrs=y", status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("multipart field sent", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newTestMultipartRequest(t,
+			testMultipartPart{field: "name", content: "x"},
+			testMultipartPart{field: "attrs", content: "y"},
+		))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status %d, want 500: %s", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestBindMalformedValidateTagIs500(t *testing.T) {
+	handler := httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		if _, err := Bind[bindBadLimit](req); err != nil {
+			WriteProblem(req, res, err)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"x"}`)))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "ten") {
+		t.Fatalf("problem leaks the tag: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,127 @@
+package gogi
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// WithMaxBodySize overrides http.max_body_bytes for the route. A negative
+// size removes the limit.
+func WithMaxBodySize(size int64) RouteOption {
+	return func(route *route) {
+		route.MaxBodySize = size
+	}
+}
+
+// limitRequestBody caps the body at limit bytes and transparently decodes
+// gzip, deflate and br Content-Encodings. The decoded body is held to the
+// same limit, so a small compressed payload cannot expand without bound.
+// A declared Content-Length over the limit is rejected before any handler
+// runs. Other reads past the limit fail with *http.MaxBytesError, which
+// Bind and WriteProblem report as 413; an HTTPHandler that hits it answers
+// 413 whatever it wrote. r is modified in place, so it must be a copy owned
+// by the caller.
+func limitRequestBody(w http.ResponseWriter, r *http.Request, limit int64) error {
+	if limit > 0 && r.ContentLength > limit {
+		return &http.MaxBytesError{Limit: limit}
+	}
+
+	body := r.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(w, body, limit)
+	}
+
+	encoding := r.Header.Get("Content-Encoding")
+	if encoding == "" || r.Body == http.NoBody {
+		r.Body = limitedBodyFor(body, limit)
+		return nil
+	}
+
+	// Encodings are listed in the order they were applied.
+	var decoded io.Reader = body
+	codings := strings.Split(encoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		var err error
+		switch coding {
+		case "identity", "":
+			continue
+		case "gzip", "x-gzip":
+			decoded, err = gzip.NewReader(decoded)
+		case "deflate":
+			decoded, err = zlib.NewReader(decoded)
+		case "br":
+			decoded = brotli.NewReader(decoded)
+		default:
+			w.Header().Set("Accept-Encoding", "gzip, deflate, br")
+			return &HTTPError{
+				Status:  http.StatusUnsupportedMediaType,
+				Code:    "unsupported_content_encoding",
+				Message: fmt.Sprintf("content encoding %q is not supported", coding),
+			}
+		}
+		if err != nil {
+			return &HTTPError{
+				Status:  http.StatusBadRequest,
+				Code:    "invalid_content_encoding",
+				Message: fmt.Sprintf("body is not valid %s", coding),
+				Err:     err,
+			}
+		}
+	}
+
+	if limit > 0 {
+		decoded = http.MaxBytesReader(w, io.NopCloser(decoded), limit)
+	}
+
+	r.Body = limitedBodyFor(decodedBody{Reader: decoded, Closer: body}, limit)
+	r.Header = r.Header.Clone()
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// limitedBody remembers a read that went past the body limit, so
+// httpHandler can answer 413 even when the handler turned the read error
+// into some other response.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded *http.MaxBytesError
+}
+
+func limitedBodyFor(body io.ReadCloser, limit int64) io.ReadCloser {
+	if limit <= 0 {
+		return body
+	}
+	return &limitedBody{ReadCloser: body}
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if err != nil && body.exceeded == nil {
+		errors.As(err, &body.exceeded)
+	}
+	return n, err
+}
+
+// bodyLimitExceeded returns the error of a read past r's body limit.
+func bodyLimitExceeded(r *http.Request) (*http.MaxBytesError, bool) {
+	body, ok := r.Body.(*limitedBody)
+	if !ok || body.exceeded == nil {
+		return nil, false
+	}
+	return body.exceeded, true
+}
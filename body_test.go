@@ -0,0 +1,75 @@
+package gogi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipTestBody(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(data))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBodyLimitPlainHandler(t *testing.T) {
+	server := &HttpServer{router: newRouter()}
+	called := false
+	server.addRoute(nil, HTTP_POST, "/echo", httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		called = true
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			res.StatusCode = http.StatusBadRequest
+			return
+		}
+		res.Body = bytes.NewReader(data)
+	}), WithMaxBodySize(64))
+	handler := server.handler()
+
+	tests := []struct {
+		name       string
+		body       []byte
+		chunked    bool
+		encoding   string
+		status     int
+		wantCalled bool
+	}{
+		{name: "within limit", body: []byte("small"), status: http.StatusOK, wantCalled: true},
+		{name: "declared length over limit", body: []byte(strings.Repeat("x", 65)), status: http.StatusRequestEntityTooLarge},
+		{name: "chunked over limit", body: []byte(strings.Repeat("x", 65)), chunked: true, status: http.StatusRequestEntityTooLarge, wantCalled: true},
+		{name: "decoded over limit", body: gzipTestBody(t, strings.Repeat("x", 1000)), encoding: "gzip", status: http.StatusRequestEntityTooLarge, wantCalled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			r := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(tt.body))
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if called != tt.wantCalled {
+				t.Errorf("handler called %v, want %v", called, tt.wantCalled)
+			}
+			if tt.status == http.StatusRequestEntityTooLarge && w.Header().Get("Content-Type") != problemContentType {
+				t.Errorf("Content-Type %q, want a problem", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
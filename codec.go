@@ -0,0 +1,485 @@
+package gogi
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec reads and writes one media type. Bind picks a codec from the
+// request Content-Type; Handle and Respond pick one from Accept.
+type Codec interface {
+	ContentType() string
+	Decode(r io.Reader, v any) error
+	Encode(w io.Writer, v any) error
+}
+
+// TypedCodec is implemented by codecs that can only encode some types.
+// Negotiation skips them when the response type is one they cannot encode.
+type TypedCodec interface {
+	Codec
+	CanEncode(t reflect.Type) bool
+}
+
+type codecEntry struct {
+	mediaType string
+	codec     Codec
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   []codecEntry // negotiation order; the first entry is the default
+)
+
+func init() {
+	RegisterCodec(JSONCodec{})
+	RegisterCodec(XMLCodec{}, "text/xml")
+	RegisterCodec(MsgPackCodec{}, "application/x-msgpack", "application/vnd.msgpack")
+	RegisterCodec(FormCodec{})
+	RegisterCodec(ProtobufCodec{}, "application/protobuf", "application/vnd.google.protobuf")
+}
+
+// RegisterCodec makes codec available under its content type and any
+// aliases, replacing a codec already registered for the same type. New
+// media types are tried after the built-in ones when Accept ties.
+func RegisterCodec(codec Codec, aliases ...string) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	for _, mediaType := range append([]string{codec.ContentType()}, aliases...) {
+		mediaType = strings.ToLower(mediaType)
+		replaced := false
+		for i := range codecs {
+			if codecs[i].mediaType == mediaType {
+				codecs[i].codec = codec
+				replaced = true
+			}
+		}
+		if !replaced {
+			codecs = append(codecs, codecEntry{mediaType: mediaType, codec: codec})
+		}
+	}
+}
+
+// codecForContentType returns the codec for a Content-Type header. Types
+// with a +json or +xml suffix fall back to the JSON and XML codecs.
+func codecForContentType(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	for _, entry := range codecs {
+		if entry.mediaType == mediaType {
+			return entry.codec, true
+		}
+	}
+	for _, suffix := range []string{"json", "xml"} {
+		if strings.HasSuffix(mediaType, "+"+suffix) {
+			for _, entry := range codecs {
+				if entry.mediaType == "application/"+suffix {
+					return entry.codec, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiateCodec picks the codec the client prefers according to Accept
+// among those able to encode out. A more specific range overrides a
+// wildcard one, ties on q go to the range listed first and a missing header
+// selects the first codec able to encode out. A nil or interface out is
+// not known until the value exists, so every codec is considered.
+func negotiateCodec(req *HTTPServerRequest, out reflect.Type) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	candidates := make([]codecEntry, 0, len(codecs))
+	for _, entry := range codecs {
+		if canEncode(entry.codec, out) {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	accept := strings.Join(req.HeaderValues("Accept"), ",")
+	if strings.TrimSpace(accept) == "" && len(candidates) > 0 {
+		return candidates[0].codec, nil
+	}
+	ranges := parseAccept(accept)
+
+	var best Codec
+	bestQ, bestPos := 0.0, len(ranges)
+	for _, entry := range candidates {
+		q, pos := acceptQuality(ranges, entry.mediaType)
+		if q > bestQ || (q == bestQ && q > 0 && pos < bestPos) {
+			best, bestQ, bestPos = entry.codec, q, pos
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	supported := make([]string, 0, len(candidates))
+	for _, entry := range candidates {
+		supported = append(supported, entry.mediaType)
+	}
+	return nil, &HTTPError{
+		Status:  http.StatusNotAcceptable,
+		Code:    "not_acceptable",
+		Message: fmt.Sprintf("none of %q can be produced", accept),
+		Details: map[string][]string{"supported": supported},
+	}
+}
+
+func canEncode(codec Codec, out reflect.Type) bool {
+	if out == nil || out.Kind() == reflect.Interface {
+		return true
+	}
+	typed, ok := codec.(TypedCodec)
+	return !ok || typed.CanEncode(out)
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				continue
+			}
+			q = parsed
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the q of the most specific range matching
+// mediaType and that range's position in the header.
+func acceptQuality(ranges []acceptRange, mediaType string) (float64, int) {
+	major, _, _ := strings.Cut(mediaType, "/")
+
+	q, pos, specificity := 0.0, len(ranges), -1
+	for i, r := range ranges {
+		var s int
+		switch {
+		case r.mediaType == mediaType:
+			s = 2
+		case r.mediaType == major+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, pos, specificity = r.q, i, s
+		}
+	}
+	return q, pos
+}
+
+// Respond encodes v with the codec negotiated from the request's Accept
+// header, or writes a 406 problem when none fits.
+func Respond(req *HTTPServerRequest, res *HTTPServerResponse, status int, v any) {
+	codec, err := negotiateCodec(req, reflect.TypeOf(v))
+	if err != nil {
+		WriteProblem(req, res, err)
+		return
+	}
+	encodeResponse(req, res, codec, status, v)
+}
+
+func encodeResponse(req *HTTPServerRequest, res *HTTPServerResponse, codec Codec, status int, v any) {
+	var buf bytes.Buffer
+	if err := codec.Encode(&buf, v); err != nil {
+		WriteProblem(req, res, fmt.Errorf("encoding %s response: %w", codec.ContentType(), err))
+		return
+	}
+	res.StatusCode = status
+	res.Headers["Content-Type"] = codec.ContentType()
+	res.Headers["Vary"] = "Accept"
+	res.Body = &buf
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return "application/json" }
+
+func (JSONCodec) Decode(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+func (JSONCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type XMLCodec struct{}
+
+func (XMLCodec) ContentType() string { return "application/xml" }
+
+func (XMLCodec) Decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func (XMLCodec) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// MsgPackCodec reads and writes MessagePack using the json struct tags, so
+// the same types serve both formats.
+type MsgPackCodec struct{}
+
+func (MsgPackCodec) ContentType() string { return "application/msgpack" }
+
+func (MsgPackCodec) Decode(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func (MsgPackCodec) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// FormCodec handles application/x-www-form-urlencoded bodies. Structs use
+// the `form` tag, falling back to the json name; url.Values and string maps
+// are also supported.
+type FormCodec struct{}
+
+func (FormCodec) ContentType() string { return "application/x-www-form-urlencoded" }
+
+func (FormCodec) Decode(r io.Reader, v any) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	switch dest := v.(type) {
+	case *url.Values:
+		*dest = values
+		return nil
+	case *map[string][]string:
+		*dest = values
+		return nil
+	case *map[string]string:
+		*dest = make(map[string]string, len(values))
+		for key := range values {
+			(*dest)[key] = values.Get(key)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("form: cannot decode into %T", v)
+	}
+	rv = rv.Elem()
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("form: cannot decode into %T", v)
+	}
+
+	for _, sf := range reflect.VisibleFields(rv.Type()) {
+		name := formFieldName(sf)
+		if name == "" {
+			continue
+		}
+		fieldValues, ok := values[name]
+		if !ok || len(fieldValues) == 0 {
+			continue
+		}
+		if err := setFieldValues(rv.FieldByIndex(sf.Index), fieldValues); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (FormCodec) Encode(w io.Writer, v any) error {
+	var values url.Values
+	switch src := v.(type) {
+	case url.Values:
+		values = src
+	case map[string][]string:
+		values = src
+	case map[string]string:
+		values = make(url.Values, len(src))
+		for key, value := range src {
+			values.Set(key, value)
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return fmt.Errorf("form: cannot encode %T", v)
+		}
+		values = make(url.Values)
+		for _, sf := range reflect.VisibleFields(rv.Type()) {
+			name := formFieldName(sf)
+			if name == "" {
+				continue
+			}
+			field := rv.FieldByIndex(sf.Index)
+			if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+				for i := 0; i < field.Len(); i++ {
+					values.Add(name, formatFormValue(field.Index(i)))
+				}
+				continue
+			}
+			if field.Kind() == reflect.Pointer && field.IsNil() {
+				continue
+			}
+			values.Set(name, formatFormValue(field))
+		}
+	}
+
+	_, err := io.WriteString(w, values.Encode())
+	return err
+}
+
+var (
+	urlValuesType    = reflect.TypeFor[url.Values]()
+	stringsMapType   = reflect.TypeFor[map[string][]string]()
+	stringMapType    = reflect.TypeFor[map[string]string]()
+	protoMessageType = reflect.TypeFor[proto.Message]()
+)
+
+// CanEncode reports whether Encode accepts t: url.Values, string maps and
+// structs or pointers to them.
+func (FormCodec) CanEncode(t reflect.Type) bool {
+	if t == urlValuesType || t == stringsMapType || t == stringMapType {
+		return true
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func formFieldName(sf reflect.StructField) string {
+	if sf.Anonymous || !sf.IsExported() {
+		return ""
+	}
+	if name, _, _ := strings.Cut(sf.Tag.Get("form"), ","); name != "" {
+		if name == "-" {
+			return ""
+		}
+		return name
+	}
+	for _, source := range []string{"path", "query", "header"} {
+		if sf.Tag.Get(source) != "" {
+			return ""
+		}
+	}
+	return jsonFieldName(sf)
+}
+
+func formatFormValue(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if marshaler, ok := v.Interface().(interface{ MarshalText() ([]byte, error) }); ok {
+		if text, err := marshaler.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// ProtobufCodec handles binary protocol buffers. Values must implement
+// proto.Message; a pointer to a nil message pointer is allocated first.
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) ContentType() string { return "application/x-protobuf" }
+
+func (ProtobufCodec) Decode(r io.Reader, v any) error {
+	message, err := protoMessage(v)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(data, message)
+}
+
+func (ProtobufCodec) Encode(w io.Writer, v any) error {
+	message, err := protoMessage(v)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// CanEncode reports whether t implements proto.Message.
+func (ProtobufCodec) CanEncode(t reflect.Type) bool {
+	return t.Implements(protoMessageType)
+}
+
+func protoMessage(v any) (proto.Message, error) {
+	if message, ok := v.(proto.Message); ok {
+		return message, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Pointer {
+		if rv.Elem().IsNil() {
+			rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+		}
+		if message, ok := rv.Elem().Interface().(proto.Message); ok {
+			return message, nil
+		}
+	}
+	return nil, fmt.Errorf("protobuf: %T is not a proto.Message", v)
+}
@@ -0,0 +1,73 @@
+package gogi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestNegotiateCodec(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		out    reflect.Type
+		want   string // empty for 406
+	}{
+		{name: "no header", want: "application/json"},
+		{name: "exact", accept: "application/xml", out: reflect.TypeFor[openAPIUser](), want: "application/xml"},
+		{name: "q order", accept: "application/json;q=0.5, application/msgpack", want: "application/msgpack"},
+		{name: "wildcard", accept: "*/*", want: "application/json"},
+		{name: "protobuf for a message", accept: "application/x-protobuf", out: reflect.TypeFor[*wrapperspb.StringValue](), want: "application/x-protobuf"},
+		{name: "protobuf for a struct", accept: "application/x-protobuf", out: reflect.TypeFor[openAPIUser]()},
+		{name: "protobuf falls back", accept: "application/x-protobuf, application/json;q=0.1", out: reflect.TypeFor[openAPIUser](), want: "application/json"},
+		{name: "form for a struct", accept: "application/x-www-form-urlencoded", out: reflect.TypeFor[*openAPIUser](), want: "application/x-www-form-urlencoded"},
+		{name: "form for url.Values", accept: "application/x-www-form-urlencoded", out: reflect.TypeFor[url.Values](), want: "application/x-www-form-urlencoded"},
+		{name: "form for a slice", accept: "application/x-www-form-urlencoded", out: reflect.TypeFor[[]openAPIUser]()},
+		{name: "interface is unknown", accept: "application/x-protobuf", out: reflect.TypeFor[any](), want: "application/x-protobuf"},
+		{name: "unsupported", accept: "text/csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			codec, err := negotiateCodec(newServerRequest(r), tt.out)
+			if tt.want == "" {
+				httpErr, ok := err.(*HTTPError)
+				if !ok || httpErr.Status != http.StatusNotAcceptable {
+					t.Fatalf("got %v, %v, want a 406", codec, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if codec.ContentType() != tt.want {
+				t.Fatalf("codec %s, want %s", codec.ContentType(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleRejectsUnencodableAccept(t *testing.T) {
+	called := false
+	handler := httpHandler(Handle(func(ctx context.Context, in struct{}) ([]openAPIUser, error) {
+		called = true
+		return nil, nil
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotAcceptable || called {
+		t.Fatalf("status %d, called %v, want 406 before the handler runs", w.Code, called)
+	}
+}
@@ -0,0 +1,368 @@
+package gogi
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const compressionDefaultMinSize = 1024
+
+// CompressionConfig tunes the Compress middleware. Encodings lists the
+// encodings to offer in order of preference (default br, zstd, gzip,
+// deflate); the client's q-values win over this order. Responses smaller
+// than MinSize (default 1 KiB) are sent as is, unless they are streamed.
+// Level is passed to gzip and deflate and defaults to their default level.
+// ContentTypes, when set, replaces the built-in check of which types are
+// worth compressing; entries may end in "/*".
+type CompressionConfig struct {
+	Encodings    []string
+	MinSize      int
+	Level        int
+	ContentTypes []string
+}
+
+// incompressibleTypes are already compressed; wildcards match a whole
+// top-level type.
+var incompressibleTypes = []string{
+	"image/*",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-brotli",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/pdf",
+	"application/octet-stream",
+	"application/x-protobuf",
+	"application/msgpack",
+}
+
+// compressibleExceptions are matched by incompressibleTypes but compress well.
+var compressibleExceptions = []string{
+	"image/svg+xml",
+	"image/bmp",
+	"image/x-icon",
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// Compress returns a middleware that compresses responses with the best
+// encoding the client accepts. Responses that already carry a
+// Content-Encoding, partial content, HEAD requests and upgrades are left
+// alone. Flushed responses such as SSE streams are compressed frame by frame
+// when their type is eligible.
+func Compress(config CompressionConfig) MiddlewareHandler {
+	if len(config.Encodings) == 0 {
+		config.Encodings = []string{"br", "zstd", "gzip", "deflate"}
+	}
+	if config.MinSize <= 0 {
+		config.MinSize = compressionDefaultMinSize
+	}
+	if config.Level == 0 {
+		config.Level = gzip.DefaultCompression
+	}
+
+	pools := make(map[string]*sync.Pool, len(config.Encodings))
+	for _, encoding := range config.Encodings {
+		newEncoder := encoderFactory(encoding, config.Level)
+		if newEncoder == nil {
+			panic("unsupported compression encoding " + strconv.Quote(encoding))
+		}
+		pools[encoding] = &sync.Pool{New: func() any { return newEncoder() }}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				config:         &config,
+				encoding:       negotiateEncoding(r.Header.Values("Accept-Encoding"), config.Encodings),
+			}
+			if cw.encoding != "" {
+				cw.pool = pools[cw.encoding]
+			}
+			cw.serve(next, r)
+		})
+	}
+}
+
+func encoderFactory(encoding string, level int) func() encoder {
+	switch encoding {
+	case "gzip":
+		return func() encoder {
+			w, err := gzip.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				w = gzip.NewWriter(io.Discard)
+			}
+			return w
+		}
+	case "deflate":
+		return func() encoder {
+			w, err := zlib.NewWriterLevel(io.Discard, level)
+			if err != nil {
+				w = zlib.NewWriter(io.Discard)
+			}
+			return w
+		}
+	case "br":
+		return func() encoder {
+			return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+		}
+	case "zstd":
+		return func() encoder {
+			w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+			return w
+		}
+	}
+	return nil
+}
+
+// negotiateEncoding picks the offered encoding with the highest q in
+// Accept-Encoding, breaking ties by the order of offered.
+func negotiateEncoding(header []string, offered []string) string {
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+	for _, value := range header {
+		for _, part := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			q := 1.0
+			if qValue, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				parsed, err := strconv.ParseFloat(qValue, 64)
+				if err != nil {
+					continue
+				}
+				q = parsed
+			}
+			if name == "*" {
+				wildcard = q
+			} else {
+				qualities[name] = q
+			}
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range offered {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = max(wildcard, 0)
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func (config *CompressionConfig) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if len(config.ContentTypes) > 0 {
+		return matchesMediaType(mediaType, config.ContentTypes)
+	}
+	return matchesMediaType(mediaType, compressibleExceptions) || !matchesMediaType(mediaType, incompressibleTypes)
+}
+
+func matchesMediaType(mediaType string, patterns []string) bool {
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, pattern := range patterns {
+		if pattern == mediaType || pattern == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers up to MinSize bytes before choosing between
+// compressing and passing the response through untouched.
+type compressWriter struct {
+	http.ResponseWriter
+	config   *CompressionConfig
+	encoding string
+	pool     *sync.Pool
+	status   int
+	decided  bool
+	encoder  encoder
+	buf      []byte
+}
+
+// serve runs next and finishes the response, or only releases the encoder
+// when next panics, leaving the stream unfinished.
+func (cw *compressWriter) serve(next http.Handler, r *http.Request) {
+	completed := false
+	defer func() {
+		if !completed {
+			cw.release()
+		}
+	}()
+	next.ServeHTTP(cw, r)
+	completed = true
+	cw.finish()
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if cw.decided || cw.status != 0 {
+		return
+	}
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) < cw.config.MinSize {
+		return len(p), nil
+	}
+	cw.decide(false)
+	if err := cw.writeBuffered(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush commits to compressing eligible responses regardless of size, since
+// a flushed response is a stream whose final length is unknown.
+func (cw *compressWriter) Flush() {
+	cw.FlushError()
+}
+
+func (cw *compressWriter) FlushError() error {
+	if !cw.decided {
+		cw.decide(true)
+		if err := cw.writeBuffered(); err != nil {
+			return err
+		}
+	}
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) decide(streaming bool) {
+	cw.decided = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	header := cw.Header()
+	if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Sniff before compressing, or net/http would sniff the compressed bytes.
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+
+	eligible := cw.status != http.StatusNoContent &&
+		cw.status != http.StatusNotModified &&
+		cw.status != http.StatusPartialContent &&
+		header.Get("Content-Encoding") == "" &&
+		cw.config.compressible(header.Get("Content-Type"))
+	if eligible {
+		addVary(header, "Accept-Encoding")
+	}
+
+	if eligible && cw.encoding != "" && (streaming || len(cw.buf) >= cw.config.MinSize) {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		cw.encoder = cw.pool.Get().(encoder)
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+func (cw *compressWriter) writeBuffered() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+func (cw *compressWriter) finish() {
+	if !cw.decided {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return
+		}
+		cw.decide(false)
+		cw.writeBuffered()
+	}
+	if cw.encoder != nil {
+		cw.encoder.Close()
+	}
+	cw.release()
+}
+
+// release returns the encoder to its pool without flushing it.
+func (cw *compressWriter) release() {
+	if cw.encoder != nil {
+		cw.encoder.Reset(io.Discard)
+		cw.pool.Put(cw.encoder)
+		cw.encoder = nil
+	}
+}
+
+func addVary(header http.Header, value string) {
+	for _, existing := range header.Values("Vary") {
+		for _, field := range strings.Split(existing, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
@@ -0,0 +1,306 @@
+package gogi
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+var compressTestBody = strings.Repeat("compress me please, ", 200)
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := []string{"br", "zstd", "gzip", "deflate"}
+	tests := []struct {
+		name   string
+		header []string
+		want   string
+	}{
+		{name: "no header"},
+		{name: "single", header: []string{"gzip"}, want: "gzip"},
+		{name: "server order breaks ties", header: []string{"gzip, br"}, want: "br"},
+		{name: "q wins over order", header: []string{"br;q=0.5, gzip"}, want: "gzip"},
+		{name: "case and spaces", header: []string{" GZIP ; q=0.8 "}, want: "gzip"},
+		{name: "several headers", header: []string{"deflate;q=0.2", "zstd;q=0.9"}, want: "zstd"},
+		{name: "wildcard", header: []string{"*"}, want: "br"},
+		{name: "wildcard with exclusion", header: []string{"*, br;q=0"}, want: "zstd"},
+		{name: "q=0 refuses", header: []string{"gzip;q=0"}},
+		{name: "wildcard q=0 refuses the rest", header: []string{"gzip;q=0.1, *;q=0"}, want: "gzip"},
+		{name: "identity only", header: []string{"identity"}},
+		{name: "bad q is ignored", header: []string{"br;q=high, gzip"}, want: "gzip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := negotiateEncoding(tt.header, offered); got != tt.want {
+				t.Fatalf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	tests := []struct {
+		contentType  string
+		contentTypes []string
+		want         bool
+	}{
+		{contentType: "text/html; charset=utf-8", want: true},
+		{contentType: "application/json", want: true},
+		{contentType: "text/event-stream", want: true},
+		{contentType: "image/png"},
+		{contentType: "image/svg+xml", want: true},
+		{contentType: "application/octet-stream"},
+		{contentType: "video/mp4"},
+		{contentType: "not a type"},
+		{contentType: "application/json", contentTypes: []string{"text/*"}},
+		{contentType: "text/csv", contentTypes: []string{"text/*"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			config := &CompressionConfig{ContentTypes: tt.contentTypes}
+			if got := config.compressible(tt.contentType); got != tt.want {
+				t.Fatalf("compressible(%q) = %v, want %v", tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func decodeTestBody(t *testing.T, encoding string, r io.Reader) string {
+	t.Helper()
+	var decoded io.Reader
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded = zr
+	case "deflate":
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded = zr
+	case "br":
+		decoded = brotli.NewReader(r)
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		decoded = zr
+	default:
+		decoded = r
+	}
+	data, err := io.ReadAll(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	handler := Compress(CompressionConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", "4000")
+		io.WriteString(w, compressTestBody)
+	}))
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	for _, encoding := range []string{"gzip", "br", "zstd", "deflate"} {
+		t.Run(encoding, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if got := res.Header.Get("Content-Encoding"); got != encoding {
+				t.Fatalf("Content-Encoding %q, want %q", got, encoding)
+			}
+			if res.Header.Get("Vary") != "Accept-Encoding" || res.ContentLength == 4000 {
+				t.Errorf("Vary %q, length %d, want Vary set and the handler's length dropped", res.Header.Get("Vary"), res.ContentLength)
+			}
+			if body := decodeTestBody(t, encoding, res.Body); body != compressTestBody {
+				t.Fatalf("decoded %d bytes, want the original %d", len(body), len(compressTestBody))
+			}
+		})
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		upgrade     bool
+		status      int
+		contentType string
+		encoding    string // Content-Encoding set by the handler
+		body        string
+		etag        string
+		wantEncoded bool
+		wantVary    bool
+		wantETag    string
+	}{
+		{name: "compressed", body: compressTestBody, etag: `"v1"`, wantEncoded: true, wantVary: true, wantETag: `W/"v1"`},
+		{name: "weak etag kept", body: compressTestBody, etag: `W/"v1"`, wantEncoded: true, wantVary: true, wantETag: `W/"v1"`},
+		{name: "under MinSize", body: "small", etag: `"v1"`, wantVary: true, wantETag: `"v1"`},
+		{name: "no content", status: http.StatusNoContent},
+		{name: "not modified", status: http.StatusNotModified, etag: `"v1"`, wantETag: `"v1"`},
+		{name: "partial content", status: http.StatusPartialContent, body: compressTestBody},
+		{name: "incompressible type", contentType: "image/png", body: compressTestBody},
+		{name: "already encoded", encoding: "br", body: compressTestBody},
+		{name: "HEAD", method: http.MethodHead, body: compressTestBody},
+		{name: "upgrade", upgrade: true, body: compressTestBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Compress(CompressionConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType := tt.contentType
+				if contentType == "" {
+					contentType = "text/plain"
+				}
+				w.Header().Set("Content-Type", contentType)
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				io.WriteString(w, tt.body)
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			if tt.upgrade {
+				r.Header.Set("Upgrade", "websocket")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			encoded := w.Header().Get("Content-Encoding") == "gzip"
+			if encoded != tt.wantEncoded {
+				t.Fatalf("gzip encoded %v, want %v", encoded, tt.wantEncoded)
+			}
+			if vary := w.Header().Get("Vary") == "Accept-Encoding"; vary != tt.wantVary {
+				t.Errorf("Vary %q, want set %v", w.Header().Get("Vary"), tt.wantVary)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag %q, want %q", got, tt.wantETag)
+			}
+			if !encoded && w.Body.String() != tt.body && method != http.MethodHead {
+				t.Errorf("body changed to %q", w.Body.String())
+			}
+			if encoded && decodeTestBody(t, "gzip", w.Body) != tt.body {
+				t.Error("body does not decode to the original")
+			}
+		})
+	}
+}
+
+func TestCompressStreamsSSE(t *testing.T) {
+	release := make(chan struct{})
+	server := &HttpServer{router: newRouter()}
+	server.addRoute(nil, HTTP_GET, "/events", httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		res.SSE(func(w StreamWriter) error {
+			if err := w.Event(&SSEEvent{Data: "hello"}); err != nil {
+				return err
+			}
+			<-release
+			return w.Event(&SSEEvent{Data: "bye"})
+		})
+	}), WithMiddleware(Compress(CompressionConfig{})))
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+	defer close(release)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}, Timeout: 5 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding %q, want a compressed stream", res.Header.Get("Content-Encoding"))
+	}
+
+	// The first event must arrive while the handler is still blocked,
+	// although it is far below MinSize.
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(zr).ReadString('\n')
+	if err != nil || line != "data: hello\n" {
+		t.Fatalf("first line %q (%v), want the flushed event", line, err)
+	}
+}
+
+type testEncoder struct {
+	bytes.Buffer
+	closed  bool
+	discard bool
+}
+
+func (e *testEncoder) Close() error { e.closed = true; return nil }
+func (e *testEncoder) Flush() error { return nil }
+func (e *testEncoder) Reset(w io.Writer) {
+	e.discard = w == io.Discard
+}
+
+func TestCompressReleasesEncoderOnPanic(t *testing.T) {
+	enc := &testEncoder{}
+	pool := &sync.Pool{New: func() any { return enc }}
+	w := httptest.NewRecorder()
+	cw := &compressWriter{
+		ResponseWriter: w,
+		config:         &CompressionConfig{MinSize: 10},
+		encoding:       "gzip",
+		pool:           pool,
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, compressTestBody)
+		panic("boom")
+	})
+
+	func() {
+		defer func() {
+			if recover() != "boom" {
+				t.Fatal("panic did not propagate")
+			}
+		}()
+		cw.serve(next, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	if cw.encoder != nil || !enc.discard {
+		t.Fatal("encoder was not reset and returned to the pool")
+	}
+	if enc.closed {
+		t.Error("encoder was closed, finishing a response that panicked")
+	}
+}
@@ -0,0 +1,28 @@
+package gogi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dejaniskra/go-gi/internal/config"
+	"github.com/dejaniskra/go-gi/middleware"
+)
+
+// corsHandler applies http.cors ahead of routing, so preflight requests are
+// answered even for paths that have no OPTIONS route and never reach the
+// global middlewares, which commonly reject requests without credentials.
+func corsHandler(cfg *config.CORS, next http.Handler) http.Handler {
+	if cfg == nil {
+		return next
+	}
+
+	return middleware.CORS(middleware.CORSConfig{
+		AllowedOrigins:        cfg.AllowedOrigins,
+		AllowedOriginPatterns: cfg.AllowedOriginPatterns,
+		AllowedMethods:        cfg.AllowedMethods,
+		AllowedHeaders:        cfg.AllowedHeaders,
+		ExposedHeaders:        cfg.ExposedHeaders,
+		AllowCredentials:      *cfg.AllowCredentials,
+		MaxAge:                time.Duration(*cfg.MaxAge) * time.Second,
+	})(next)
+}
@@ -0,0 +1,140 @@
+package gogi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dejaniskra/go-gi/internal/config"
+)
+
+func newTestCORSHandler(cors *config.CORS) http.Handler {
+	server := &HttpServer{router: newRouter()}
+	server.addRoute(nil, HTTP_GET, "/items", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("items"))
+	}))
+	return corsHandler(cors, server.handler())
+}
+
+func testCORSConfig(origins []string, credentials bool) *config.CORS {
+	maxAge := 600
+	return &config.CORS{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: &credentials,
+		MaxAge:           &maxAge,
+	}
+}
+
+func TestCORSHandler(t *testing.T) {
+	allowed := []string{"https://app.example.com"}
+	tests := []struct {
+		name        string
+		cors        *config.CORS
+		method      string
+		headers     map[string]string
+		status      int
+		wantHeaders map[string]string
+	}{
+		{
+			name:   "preflight without an OPTIONS route",
+			cors:   testCORSConfig(allowed, false),
+			method: http.MethodOptions,
+			headers: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "Content-Type",
+			},
+			status: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Methods":     "GET, POST",
+				"Access-Control-Allow-Headers":     "Content-Type",
+				"Access-Control-Max-Age":           "600",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:        "preflight from another origin",
+			cors:        testCORSConfig(allowed, false),
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://evil.example", "Access-Control-Request-Method": "POST"},
+			status:      http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:        "preflight for a method not allowed",
+			cors:        testCORSConfig(allowed, false),
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+			status:      http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name:    "actual request",
+			cors:    testCORSConfig(allowed, false),
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			status:  http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://app.example.com",
+				"Access-Control-Expose-Headers": "X-Request-Id",
+				"Vary":                          "Origin",
+			},
+		},
+		{
+			name:        "actual request from another origin",
+			cors:        testCORSConfig(allowed, false),
+			headers:     map[string]string{"Origin": "https://evil.example"},
+			status:      http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "wildcard origin",
+			cors:        testCORSConfig([]string{"*"}, false),
+			headers:     map[string]string{"Origin": "https://anything.example"},
+			status:      http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:    "credentials echo the origin",
+			cors:    testCORSConfig(allowed, true),
+			headers: map[string]string{"Origin": "https://app.example.com"},
+			status:  http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:        "no CORS section",
+			method:      http.MethodOptions,
+			headers:     map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "GET"},
+			status:      http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "", "Allow": "GET, OPTIONS"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/items", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			newTestCORSHandler(tt.cors).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			for k, want := range tt.wantHeaders {
+				if got := w.Header().Get(k); got != want {
+					t.Errorf("%s %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
@@ -35,7 +35,7 @@ func GetDynamoClient(role string) (*DynamoClient, error) {
 		return nil, fmt.Errorf("no Dynamo config found for role: %s", role)
 	}
 
-	client, err := newDynamoClient(cfg)
+	client, err := newDynamoClient(role, cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -44,7 +44,7 @@ func GetDynamoClient(role string) (*DynamoClient, error) {
 	return client, nil
 }
 
-func newDynamoClient(cfg *appconfig.DynamoConfig) (*DynamoClient, error) {
+func newDynamoClient(role string, cfg *appconfig.DynamoConfig) (*DynamoClient, error) {
 	var awsCfg aws.Config
 	var err error
 
@@ -72,6 +72,7 @@ func newDynamoClient(cfg *appconfig.DynamoConfig) (*DynamoClient, error) {
 		)
 	}
 
+	awsCfg.APIOptions = append(awsCfg.APIOptions, dynamoInstrumentation(role))
 	client := dynamodb.NewFromConfig(awsCfg)
 	GetLogger().Debug(fmt.Sprintf("[DynamoDB] Connected to region=%s endpoint=%v", cfg.Region, cfg.Endpoint))
 	return &DynamoClient{Client: client}, nil
@@ -0,0 +1,228 @@
+package gogi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+const problemContentType = "application/problem+json"
+
+// HTTPError is an error with an HTTP status. Code is a stable,
+// machine-readable identifier and Details any extra data for the client.
+// Err, if set, is logged but never sent.
+type HTTPError struct {
+	Status  int
+	Code    string
+	Message string
+	Details any
+	Err     error
+}
+
+func NewHTTPError(status int, code, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %s: %v", e.Status, e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Problem is an RFC 9457 problem details document.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code,omitempty"`
+	Details  any          `json:"details,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// StatusCoder lets a Handle output choose its own success status.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// NoContent is a Handle output that answers 204 with no body.
+type NoContent struct{}
+
+func (NoContent) StatusCode() int {
+	return http.StatusNoContent
+}
+
+// Handle adapts a typed function into an HTTPHandler. The input is built
+// with Bind, the output is encoded with the codec negotiated from Accept
+// using status 200 (or its StatusCode) and any error becomes a
+// problem+json response via WriteProblem. Codecs that cannot encode Out,
+// such as protobuf for a type that is not a proto.Message, are not offered,
+// and an Accept header no remaining codec satisfies is rejected with 406
+// before fn runs. It panics if In has a malformed validate tag or a path,
+// query or header field of a type Bind cannot convert strings into.
+//
+// Register the result with WithTypes[In, Out]() to document In and Out in
+// the OpenAPI document.
+func Handle[In, Out any](fn func(ctx context.Context, in In) (Out, error)) HTTPHandler {
+	if err := checkBindType(reflect.TypeFor[In]()); err != nil {
+		panic(err.Error())
+	}
+	// A 204 output has no body, so any codec will do.
+	outType := reflect.TypeFor[Out]()
+	if successStatus(outType) == http.StatusNoContent {
+		outType = nil
+	}
+	return func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		codec, err := negotiateCodec(req, outType)
+		if err != nil {
+			WriteProblem(req, res, err)
+			return
+		}
+
+		in, err := Bind[In](req)
+		if err != nil {
+			// fn never sees files stored for a request that failed to bind,
+			// so a custom sink would otherwise keep them.
+			if req.multipart != nil {
+				req.multipart.RemoveAll()
+			}
+			WriteProblem(req, res, err)
+			return
+		}
+
+		out, err := fn(req.Context, in)
+		if err != nil {
+			WriteProblem(req, res, err)
+			return
+		}
+
+		status := http.StatusOK
+		if coder, ok := any(out).(StatusCoder); ok {
+			status = coder.StatusCode()
+		}
+		if status == http.StatusNoContent || isNil(out) {
+			if status == http.StatusOK {
+				status = http.StatusNoContent
+			}
+			res.StatusCode = status
+			return
+		}
+
+		encodeResponse(req, res, codec, status, out)
+	}
+}
+
+// WriteProblem turns err into a problem+json response. HTTPError and
+// BindError keep their status and details, oversized bodies become 413,
+// context deadlines 504 and anything else a 500 whose message is logged
+// rather than returned.
+func WriteProblem(req *HTTPServerRequest, res *HTTPServerResponse, err error) {
+	status, data := encodeProblem(req.Method, req.Path, err)
+	res.StatusCode = status
+	res.Headers["Content-Type"] = problemContentType
+	res.Body = bytes.NewReader(data)
+}
+
+// writeHTTPProblem is WriteProblem for code running outside an HTTPHandler.
+func writeHTTPProblem(w http.ResponseWriter, r *http.Request, err error) {
+	status, data := encodeProblem(r.Method, r.URL.Path, err)
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func encodeProblem(method, path string, err error) (int, []byte) {
+	problem := problemFor(err)
+	problem.Instance = path
+	if problem.Status >= http.StatusInternalServerError {
+		GetLogger().Error(fmt.Sprintf("[HTTP] %s %s: %v", method, path, err))
+	}
+
+	data, marshalErr := json.Marshal(problem)
+	if marshalErr != nil {
+		GetLogger().Error(fmt.Sprintf("[HTTP] Encoding problem for %s: %v", path, marshalErr))
+		problem = &Problem{Type: "about:blank", Title: http.StatusText(problem.Status), Status: problem.Status}
+		data, _ = json.Marshal(problem)
+	}
+	return problem.Status, data
+}
+
+func problemFor(err error) *Problem {
+	var httpErr *HTTPError
+	var bindErr *BindError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &httpErr):
+		status := httpErr.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		return &Problem{
+			Type:    "about:blank",
+			Title:   http.StatusText(status),
+			Status:  status,
+			Detail:  httpErr.Message,
+			Code:    httpErr.Code,
+			Details: httpErr.Details,
+		}
+	case errors.As(err, &bindErr):
+		code := "invalid_request"
+		detail := "The request could not be read."
+		if bindErr.StatusCode == http.StatusUnprocessableEntity {
+			code = "validation_failed"
+			detail = "One or more fields are invalid."
+		}
+		return &Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(bindErr.StatusCode),
+			Status: bindErr.StatusCode,
+			Detail: detail,
+			Code:   code,
+			Errors: bindErr.Errors,
+		}
+	case errors.As(err, &maxBytesErr):
+		return &Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusRequestEntityTooLarge),
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit),
+			Code:   "request_too_large",
+		}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusGatewayTimeout),
+			Status: http.StatusGatewayTimeout,
+			Code:   "timeout",
+		}
+	default:
+		return &Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusInternalServerError),
+			Status: http.StatusInternalServerError,
+			Code:   "internal_error",
+		}
+	}
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
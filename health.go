@@ -0,0 +1,351 @@
+package gogi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthDefaultPath      = "/healthz"
+	healthDefaultReadyPath = "/readyz"
+	healthDefaultTimeout   = 2 * time.Second
+	healthDefaultCacheTTL  = time.Second
+
+	HealthUp       = "up"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// HealthCheck reports whether a dependency is usable. It should honour ctx,
+// which expires after HealthConfig.Timeout.
+type HealthCheck func(ctx context.Context) error
+
+// HealthConfig configures EnableHealthChecks. Path (default /healthz) and
+// ReadyPath (default /readyz) are the endpoints. Each check runs with its own
+// Timeout (default 2s), and a report is reused for CacheTTL (default 1s) so
+// frequent probes do not hammer the dependencies; a negative CacheTTL
+// disables caching.
+type HealthConfig struct {
+	Path      string
+	ReadyPath string
+	Timeout   time.Duration
+	CacheTTL  time.Duration
+}
+
+// HealthReport is the JSON body of the health endpoints, with one entry per
+// check. Client checks are named after their kind and role, e.g.
+// "mysql:primary".
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+type HealthCheckResult struct {
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs float64   `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+	liveness   bool
+}
+
+func (config HealthConfig) withDefaults() HealthConfig {
+	if config.Path == "" {
+		config.Path = healthDefaultPath
+	}
+	if config.ReadyPath == "" {
+		config.ReadyPath = healthDefaultReadyPath
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = healthDefaultTimeout
+	}
+	if config.CacheTTL == 0 {
+		config.CacheTTL = healthDefaultCacheTTL
+	}
+	return config
+}
+
+// EnableHealthChecks registers the liveness and readiness endpoints. Both
+// probe every MySQL, Postgres, Mongo, Redis and Dynamo client created so
+// far, plus the checks added with AddHealthCheck and AddLivenessCheck, and
+// return the breakdown as JSON.
+//
+// The readiness endpoint answers 503 when any check fails. The liveness
+// endpoint only answers 503 when a liveness check fails; a failing
+// dependency is reported as "degraded" with 200, so an orchestrator does not
+// restart the process over an outage it cannot fix.
+func (application *Application) EnableHealthChecks(config HealthConfig) {
+	httpServer := getServer()
+	if application.httpServer == nil {
+		application.httpServer = httpServer
+	} // TODO: revisit this
+
+	checker := application.healthChecker()
+	checker.configure(config.withDefaults())
+	httpServer.addHealthRoutes(checker)
+}
+
+func (httpServer *HttpServer) addHealthRoutes(checker *healthChecker) {
+	hidden := WithOpenAPI(OpenAPIOperation{Hidden: true})
+
+	httpServer.addRoute(nil, HTTP_GET, checker.config.Path, httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		writeHealthReport(res, livenessReport(checker.report()))
+	}), hidden)
+
+	httpServer.addRoute(nil, HTTP_GET, checker.config.ReadyPath, httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		writeHealthReport(res, readinessReport(checker.report()))
+	}), hidden)
+}
+
+// AddHealthCheck adds a dependency check to the health endpoints. A failing
+// check makes the application unready.
+func (application *Application) AddHealthCheck(name string, check HealthCheck) {
+	application.healthChecker().add(name, check, false)
+}
+
+// AddLivenessCheck adds a check that also fails the liveness endpoint. Use
+// it for conditions only a restart can fix, such as a wedged worker.
+func (application *Application) AddLivenessCheck(name string, check HealthCheck) {
+	application.healthChecker().add(name, check, true)
+}
+
+// CheckHealth runs every check, or returns the cached report, and reports
+// "down" if any of them failed.
+func (application *Application) CheckHealth() HealthReport {
+	return readinessReport(application.healthChecker().report())
+}
+
+// livenessReport is "down" only when a liveness check failed, and
+// "degraded" when only dependency checks did.
+func livenessReport(report HealthReport) HealthReport {
+	report.Status = HealthUp
+	for _, result := range report.Checks {
+		if result.Status != HealthDown {
+			continue
+		}
+		if result.liveness {
+			report.Status = HealthDown
+			break
+		}
+		report.Status = HealthDegraded
+	}
+	return report
+}
+
+func readinessReport(report HealthReport) HealthReport {
+	report.Status = HealthUp
+	for _, result := range report.Checks {
+		if result.Status == HealthDown {
+			report.Status = HealthDown
+			break
+		}
+	}
+	return report
+}
+
+func (application *Application) healthChecker() *healthChecker {
+	application.healthOnce.Do(func() {
+		application.health = &healthChecker{config: HealthConfig{}.withDefaults()}
+	})
+	return application.health
+}
+
+func writeHealthReport(res *HTTPServerResponse, report HealthReport) {
+	body, err := json.Marshal(report)
+	if err != nil {
+		res.StatusCode = http.StatusInternalServerError
+		return
+	}
+	res.StatusCode = http.StatusOK
+	if report.Status == HealthDown {
+		res.StatusCode = http.StatusServiceUnavailable
+	}
+	res.Headers["Content-Type"] = "application/json"
+	res.Headers["Cache-Control"] = "no-store"
+	res.Body = bytes.NewReader(body)
+}
+
+type namedHealthCheck struct {
+	name     string
+	check    HealthCheck
+	liveness bool
+}
+
+// healthChecker runs the checks and caches the outcome. Concurrent callers
+// share a single run instead of each probing the dependencies.
+type healthChecker struct {
+	mu       sync.Mutex
+	config   HealthConfig
+	checks   []namedHealthCheck
+	cached   map[string]HealthCheckResult
+	cachedAt time.Time
+	running  chan struct{}
+}
+
+func (checker *healthChecker) configure(config HealthConfig) {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	checker.config = config
+	checker.cached = nil
+}
+
+func (checker *healthChecker) add(name string, check HealthCheck, liveness bool) {
+	checker.mu.Lock()
+	defer checker.mu.Unlock()
+
+	for i := range checker.checks {
+		if checker.checks[i].name == name {
+			checker.checks[i] = namedHealthCheck{name: name, check: check, liveness: liveness}
+			checker.cached = nil
+			return
+		}
+	}
+	checker.checks = append(checker.checks, namedHealthCheck{name: name, check: check, liveness: liveness})
+	checker.cached = nil
+}
+
+func (checker *healthChecker) report() HealthReport {
+	checker.mu.Lock()
+	for {
+		if checker.cached != nil && time.Since(checker.cachedAt) < checker.config.CacheTTL {
+			report := HealthReport{Checks: checker.cached}
+			checker.mu.Unlock()
+			return report
+		}
+		if checker.running == nil {
+			break
+		}
+		running := checker.running
+		checker.mu.Unlock()
+		<-running
+		checker.mu.Lock()
+		if checker.cached != nil && checker.config.CacheTTL <= 0 {
+			// Nothing is cached between requests, but callers that waited
+			// still share the run they waited for.
+			report := HealthReport{Checks: checker.cached}
+			checker.mu.Unlock()
+			return report
+		}
+	}
+
+	running := make(chan struct{})
+	checker.running = running
+	checks := append(clientHealthChecks(), checker.checks...)
+	timeout := checker.config.Timeout
+	checker.mu.Unlock()
+
+	results := runHealthChecks(checks, timeout)
+
+	checker.mu.Lock()
+	checker.cached = results
+	checker.cachedAt = time.Now()
+	checker.running = nil
+	checker.mu.Unlock()
+	close(running)
+
+	return HealthReport{Checks: results}
+}
+
+func runHealthChecks(checks []namedHealthCheck, timeout time.Duration) map[string]HealthCheckResult {
+	results := make(map[string]HealthCheckResult, len(checks))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runHealthCheck(check, timeout)
+			mu.Lock()
+			results[check.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runHealthCheck gives up on a check once its timeout passes, even if the
+// check ignores ctx; the check's goroutine is left to finish on its own.
+func runHealthCheck(check namedHealthCheck, timeout time.Duration) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- check.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := HealthCheckResult{
+		Status:     HealthUp,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt:  start.UTC(),
+		liveness:   check.liveness,
+	}
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+		GetLogger().Warn(fmt.Sprintf("[Health] Check %s failed: %v", check.name, err))
+	}
+	return result
+}
+
+// clientHealthChecks snapshots the client registries, so clients created
+// after the health endpoints were enabled are included too.
+func clientHealthChecks() []namedHealthCheck {
+	var checks []namedHealthCheck
+	add := func(kind, role string, check HealthCheck) {
+		checks = append(checks, namedHealthCheck{name: kind + ":" + role, check: check})
+	}
+
+	mysqlClientsMu.Lock()
+	for role, client := range mysqlClients {
+		add("mysql", role, client.Ping)
+	}
+	mysqlClientsMu.Unlock()
+
+	postgresClientsMu.Lock()
+	for role, client := range postgresClients {
+		add("postgres", role, client.Ping)
+	}
+	postgresClientsMu.Unlock()
+
+	mongoClientsMu.Lock()
+	for role, client := range mongoClients {
+		add("mongo", role, client.Ping)
+	}
+	mongoClientsMu.Unlock()
+
+	redisClientsMu.Lock()
+	for role, client := range redisClients {
+		add("redis", role, client.Ping)
+	}
+	redisClientsMu.Unlock()
+
+	dynamoMu.Lock()
+	for role, client := range dynamoClients {
+		add("dynamo", role, client.Ping)
+	}
+	dynamoMu.Unlock()
+
+	return checks
+}
@@ -0,0 +1,212 @@
+package gogi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func healthyCheck(ctx context.Context) error { return nil }
+
+func failingCheck(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestHealthEndpoints(t *testing.T) {
+	tests := []struct {
+		name           string
+		checks         map[string]HealthCheck
+		liveness       map[string]HealthCheck
+		liveStatus     string
+		liveCode       int
+		readyStatus    string
+		readyCode      int
+		wantCheckError string
+	}{
+		{
+			name:        "no checks",
+			liveStatus:  HealthUp,
+			liveCode:    http.StatusOK,
+			readyStatus: HealthUp,
+			readyCode:   http.StatusOK,
+		},
+		{
+			name:        "all healthy",
+			checks:      map[string]HealthCheck{"db": healthyCheck, "cache": healthyCheck},
+			liveness:    map[string]HealthCheck{"worker": healthyCheck},
+			liveStatus:  HealthUp,
+			liveCode:    http.StatusOK,
+			readyStatus: HealthUp,
+			readyCode:   http.StatusOK,
+		},
+		{
+			name:           "dependency down",
+			checks:         map[string]HealthCheck{"db": failingCheck, "cache": healthyCheck},
+			liveStatus:     HealthDegraded,
+			liveCode:       http.StatusOK,
+			readyStatus:    HealthDown,
+			readyCode:      http.StatusServiceUnavailable,
+			wantCheckError: "connection refused",
+		},
+		{
+			name:           "liveness down",
+			checks:         map[string]HealthCheck{"db": failingCheck},
+			liveness:       map[string]HealthCheck{"worker": failingCheck},
+			liveStatus:     HealthDown,
+			liveCode:       http.StatusServiceUnavailable,
+			readyStatus:    HealthDown,
+			readyCode:      http.StatusServiceUnavailable,
+			wantCheckError: "connection refused",
+		},
+		{
+			name:           "panicking check",
+			checks:         map[string]HealthCheck{"db": func(ctx context.Context) error { panic("boom") }},
+			liveStatus:     HealthDegraded,
+			liveCode:       http.StatusOK,
+			readyStatus:    HealthDown,
+			readyCode:      http.StatusServiceUnavailable,
+			wantCheckError: "panic: boom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &healthChecker{config: HealthConfig{CacheTTL: -1}.withDefaults()}
+			for name, check := range tt.checks {
+				checker.add(name, check, false)
+			}
+			for name, check := range tt.liveness {
+				checker.add(name, check, true)
+			}
+			server := &HttpServer{router: newRouter()}
+			server.addHealthRoutes(checker)
+			handler := server.handler()
+
+			for _, probe := range []struct {
+				path   string
+				status string
+				code   int
+			}{
+				{path: "/healthz", status: tt.liveStatus, code: tt.liveCode},
+				{path: "/readyz", status: tt.readyStatus, code: tt.readyCode},
+			} {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, probe.path, nil))
+				if w.Code != probe.code {
+					t.Errorf("%s: status %d, want %d", probe.path, w.Code, probe.code)
+				}
+				var report HealthReport
+				if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+					t.Fatalf("%s: %v", probe.path, err)
+				}
+				if report.Status != probe.status {
+					t.Errorf("%s: report status %q, want %q", probe.path, report.Status, probe.status)
+				}
+				if len(report.Checks) != len(tt.checks)+len(tt.liveness) {
+					t.Errorf("%s: %d checks reported, want %d", probe.path, len(report.Checks), len(tt.checks)+len(tt.liveness))
+				}
+				if tt.wantCheckError != "" && report.Checks["db"].Error != tt.wantCheckError {
+					t.Errorf("%s: db error %q, want %q", probe.path, report.Checks["db"].Error, tt.wantCheckError)
+				}
+				if w.Header().Get("Cache-Control") != "no-store" {
+					t.Errorf("%s: Cache-Control %q, want no-store", probe.path, w.Header().Get("Cache-Control"))
+				}
+			}
+		})
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var sawDeadline atomic.Bool
+
+	checker := &healthChecker{config: HealthConfig{Timeout: 20 * time.Millisecond, CacheTTL: -1}.withDefaults()}
+	checker.add("honours ctx", func(ctx context.Context) error {
+		_, ok := ctx.Deadline()
+		sawDeadline.Store(ok)
+		<-ctx.Done()
+		return ctx.Err()
+	}, false)
+	checker.add("ignores ctx", func(ctx context.Context) error {
+		<-release
+		return nil
+	}, false)
+	checker.add("fast", healthyCheck, false)
+
+	start := time.Now()
+	report := readinessReport(checker.report())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("report took %v, want the timeout to cut slow checks short", elapsed)
+	}
+	if report.Status != HealthDown {
+		t.Fatalf("status %q, want down", report.Status)
+	}
+	if got := report.Checks["ignores ctx"].Error; !strings.Contains(got, "timed out after 20ms") {
+		t.Errorf("error %q, want a timeout", got)
+	}
+	if report.Checks["honours ctx"].Status != HealthDown || !sawDeadline.Load() {
+		t.Error("check did not get a deadline or reported up past it")
+	}
+	if report.Checks["fast"].Status != HealthUp {
+		t.Error("fast check was held up by slow ones")
+	}
+}
+
+func TestHealthReportCache(t *testing.T) {
+	var calls atomic.Int32
+	checker := &healthChecker{config: HealthConfig{CacheTTL: time.Hour}.withDefaults()}
+	checker.add("db", func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}, false)
+
+	checker.report()
+	checker.report()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("check ran %d times within CacheTTL, want 1", n)
+	}
+
+	checker.add("cache", healthyCheck, false)
+	checker.report()
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("check ran %d times, want adding a check to drop the cache", n)
+	}
+}
+
+func TestHealthIncludesRegisteredClients(t *testing.T) {
+	// A port nothing listens on, so the ping fails fast.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1})
+	redisClientsMu.Lock()
+	redisClients["health-test"] = &RedisClient{Writer: client, Reader: client}
+	redisClientsMu.Unlock()
+	t.Cleanup(func() {
+		redisClientsMu.Lock()
+		delete(redisClients, "health-test")
+		redisClientsMu.Unlock()
+		client.Close()
+	})
+
+	checker := &healthChecker{config: HealthConfig{Timeout: time.Second, CacheTTL: -1}.withDefaults()}
+	report := livenessReport(checker.report())
+	result, ok := report.Checks["redis:health-test"]
+	if !ok {
+		t.Fatalf("checks %v lack the registered Redis client", report.Checks)
+	}
+	if result.Status != HealthDown || report.Status != HealthDegraded {
+		t.Fatalf("client %+v, report %q, want the client down and liveness degraded", result, report.Status)
+	}
+}
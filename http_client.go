@@ -2,6 +2,7 @@ package gogi
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/url"
@@ -15,7 +16,10 @@ type HTTPClient struct {
 	Timeout *int
 }
 
+// HTTPClientRequest describes a call made with HTTPClient.Execute. Context,
+// when set, bounds the call and carries the trace propagated to the server.
 type HTTPClientRequest struct {
+	Context     context.Context
 	Method      HTTPMethod
 	Path        *string
 	Headers     *map[string]string
@@ -37,7 +41,20 @@ func GetHTTPClient(baseURL *string, headers *map[string]string, timeout *int) *H
 		Timeout: timeout,
 	}
 }
-func (c *HTTPClient) Execute(req *HTTPClientRequest) (*HTTPClientResponse, error) {
+func (c *HTTPClient) Execute(req *HTTPClientRequest) (response *HTTPClientResponse, err error) {
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := startHTTPClientSpan(ctx, string(req.Method))
+	defer func() {
+		if response != nil {
+			endHTTPClientSpan(span, response.StatusCode)
+			return
+		}
+		endSpan(span, err)
+	}()
+
 	var baseUrl, path string
 
 	if c.BaseURL != nil {
@@ -77,7 +94,8 @@ func (c *HTTPClient) Execute(req *HTTPClientRequest) (*HTTPClientResponse, error
 		parsedURL.RawQuery = q.Encode()
 	}
 
-	httpReq, err := http.NewRequest(string(req.Method), parsedURL.String(), body)
+	span.SetAttributes(urlAttribute(parsedURL))
+	httpReq, err := http.NewRequestWithContext(ctx, string(req.Method), parsedURL.String(), body)
 
 	if err != nil {
 		return nil, err
@@ -97,6 +115,8 @@ func (c *HTTPClient) Execute(req *HTTPClientRequest) (*HTTPClientResponse, error
 		}
 	}
 
+	injectTraceHeaders(ctx, httpReq.Header)
+
 	timeout := 30
 	if c.Timeout != nil {
 		timeout = *c.Timeout
@@ -121,7 +141,7 @@ func (c *HTTPClient) Execute(req *HTTPClientRequest) (*HTTPClientResponse, error
 
 	defer resp.Body.Close()
 
-	response := &HTTPClientResponse{
+	response = &HTTPClientResponse{
 		StatusCode: resp.StatusCode,
 		Headers:    make(map[string]string),
 		Body:       bytes.NewReader(data),
@@ -2,10 +2,16 @@ package gogi
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dejaniskra/go-gi/internal/config"
@@ -16,78 +22,259 @@ var httpServer *HttpServer
 func getServer() *HttpServer {
 	if httpServer == nil {
 		httpServer = &HttpServer{
-			routes: make(map[routeKey]http.HandlerFunc),
+			router: newRouter(),
 		}
 	}
 
 	return httpServer
 }
 
-func (httpServer *HttpServer) addRoute(method HTTPMethod, path string, handler HTTPHandler) {
-	httpServer.routes[routeKey{Method: string(method), Path: path}] = httpHandler(handler)
+func (httpServer *HttpServer) addRoute(group *RouteGroup, method HTTPMethod, path string, endpoint http.Handler, opts ...RouteOption) *route {
+	route := &route{
+		RouteInfo: RouteInfo{
+			Method:   string(method),
+			Path:     cleanPath(group.fullPath(path)),
+			Metadata: make(map[string]any),
+		},
+		group:    group,
+		endpoint: endpoint,
+	}
+	for _, opt := range opts {
+		opt(route)
+	}
+
+	httpServer.router.add(route.Method, route.Path, route)
+	httpServer.routes = append(httpServer.routes, route)
+	return route
+}
+
+func (httpServer *HttpServer) addWebSocket(group *RouteGroup, path string, handler WebSocketHandler, opts ...RouteOption) {
+	endpoint := &webSocketEndpoint{httpServer: httpServer, handler: handler}
+	route := httpServer.addRoute(group, HTTP_GET, path, endpoint, opts...)
+	if route.Timeout > 0 {
+		panic(fmt.Sprintf("route %s %s: WithTimeout cannot be used on a WebSocket route", route.Method, route.Path))
+	}
+
+	var config WebSocketConfig
+	if route.webSocket != nil {
+		config = *route.webSocket
+	}
+	endpoint.config = config.withDefaults()
 }
 
 func (httpServer *HttpServer) addMiddleware(mw func(http.Handler) http.Handler) {
 	httpServer.middlewares = append(httpServer.middlewares, mw)
 }
 
-func (httpServer *HttpServer) start(cfg *config.Config) error {
-	startScheduler()
+// handler wraps every route in its group middlewares and the router in the
+// global ones. It runs once on start so middleware may be added in any order
+// relative to the routes it applies to.
+func (httpServer *HttpServer) handler() http.Handler {
+	for _, route := range httpServer.routes {
+		route.build()
+		route.metrics = httpServer.metricsEnabled
+		route.tracing = httpServer.tracingEnabled
+	}
 
-	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for key, handler := range httpServer.routes {
-			params, matched := matchRoute(key.Path, r.URL.Path)
-			if matched && key.Method == r.Method {
-				ctx := context.WithValue(r.Context(), "pathParams", params)
-				handler(w, r.WithContext(ctx))
-				return
-			}
-		}
-		http.NotFound(w, r)
-	})
+	httpServer.router.metrics = httpServer.metricsEnabled
 
-	var handler http.Handler = router
+	var handler http.Handler = httpServer.router
 	for i := len(httpServer.middlewares) - 1; i >= 0; i-- {
 		handler = httpServer.middlewares[i](handler)
 	}
+	return handler
+}
+
+// errShutDownBeforeStart is returned by start once shutdown has run, so an
+// application that was shut down never begins serving.
+var errShutDownBeforeStart = fmt.Errorf("%w before it started", http.ErrServerClosed)
+
+func (httpServer *HttpServer) start(cfg *config.Config) error {
+	httpServer.mu.Lock()
+	closing := httpServer.closing
+	httpServer.mu.Unlock()
+	if closing {
+		return errShutDownBeforeStart
+	}
+
+	startScheduler()
+
+	srv, err := newHTTPServer(cfg.Http, corsHandler(cfg.Http.CORS, httpServer.handler()))
+	if err != nil {
+		return err
+	}
+
+	httpServer.mu.Lock()
+	if httpServer.closing {
+		httpServer.mu.Unlock()
+		return errShutDownBeforeStart
+	}
+	httpServer.server = srv
+	httpServer.mu.Unlock()
 
+	if cfg.Http.TLS != nil {
+		fmt.Printf("🚀 Server running on %s (%s over TLS)\n", srv.Addr, protocolName(cfg.Http))
+		return srv.ListenAndServeTLS(cfg.Http.TLS.CertFile, cfg.Http.TLS.KeyFile)
+	}
+
+	fmt.Printf("🚀 Server running on %s (%s)\n", srv.Addr, protocolName(cfg.Http))
+	return srv.ListenAndServe()
+}
+
+func newHTTPServer(cfg *config.Http, handler http.Handler) (*http.Server, error) {
 	srv := &http.Server{
-		Addr:              ":" + fmt.Sprintf("%d", *cfg.Http.Port),
+		Addr:              ":" + fmt.Sprintf("%d", *cfg.Port),
 		Handler:           handler,
-		ReadTimeout:       time.Duration(*cfg.Http.Timeouts.ReadRequest) * time.Second,
-		ReadHeaderTimeout: time.Duration(*cfg.Http.Timeouts.ReadRequestHeader) * time.Second,
-		WriteTimeout:      time.Duration(*cfg.Http.Timeouts.ResponseWrite) * time.Second,
-		IdleTimeout:       time.Duration(*cfg.Http.Timeouts.Idle) * time.Second,
-		MaxHeaderBytes:    *cfg.Http.MaxHeaderBytes,
+		ReadTimeout:       time.Duration(*cfg.Timeouts.ReadRequest) * time.Second,
+		ReadHeaderTimeout: time.Duration(*cfg.Timeouts.ReadRequestHeader) * time.Second,
+		WriteTimeout:      time.Duration(*cfg.Timeouts.ResponseWrite) * time.Second,
+		IdleTimeout:       time.Duration(*cfg.Timeouts.Idle) * time.Second,
+		MaxHeaderBytes:    *cfg.MaxHeaderBytes,
+		Protocols:         serverProtocols(cfg),
 	}
 
-	fmt.Printf("🚀 Server running on %s\n", srv.Addr)
-	return srv.ListenAndServe()
+	if cfg.TLS != nil {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		srv.TLSConfig = tlsConfig
+	}
+	return srv, nil
+}
+
+// serverProtocols maps http.protocols onto the server. HTTP/2 is negotiated
+// over TLS when certificates are configured and spoken as h2c otherwise.
+// HTTP/1.1 is only served when http_1 is set, so enable both to keep
+// HTTP/1.1 clients such as health probes working next to HTTP/2.
+func serverProtocols(cfg *config.Http) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(cfg.Protocols.HTTP1)
+	if cfg.Protocols.HTTP2 {
+		if cfg.TLS != nil {
+			protocols.SetHTTP2(true)
+		} else {
+			protocols.SetUnencryptedHTTP2(true)
+		}
+	}
+	return protocols
+}
+
+func protocolName(cfg *config.Http) string {
+	var names []string
+	if cfg.Protocols.HTTP1 {
+		names = append(names, "HTTP/1.1")
+	}
+	if cfg.Protocols.HTTP2 {
+		if cfg.TLS != nil {
+			names = append(names, "HTTP/2")
+		} else {
+			names = append(names, "h2c")
+		}
+	}
+	return strings.Join(names, " and ")
+}
+
+func newTLSConfig(cfg *config.TLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.MinVersion == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+
+	if cfg.ClientAuth == "verify_if_given" {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	} else {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+func (httpServer *HttpServer) shutdown(ctx context.Context) error {
+	// Marking the server closed first keeps a later start from serving and
+	// turns away upgrades while in-flight requests drain.
+	httpServer.mu.Lock()
+	httpServer.closing = true
+	srv := httpServer.server
+	httpServer.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+	// The WebSockets are closed even if srv.Shutdown gives up, since
+	// hijacked connections are invisible to it.
+	err := srv.Shutdown(ctx)
+
+	httpServer.mu.Lock()
+	conns := make([]*WebSocketConn, 0, len(httpServer.webSockets))
+	for conn := range httpServer.webSockets {
+		conns = append(conns, conn)
+	}
+	httpServer.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.CloseWithReason(WebSocketCloseGoingAway, "server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		httpServer.webSocketsWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(err, ctx.Err()) {
+			return err
+		}
+		return errors.Join(err, ctx.Err())
+	}
+}
+
+func (httpServer *HttpServer) trackWebSocket(conn *WebSocketConn) bool {
+	httpServer.mu.Lock()
+	defer httpServer.mu.Unlock()
+
+	if httpServer.closing {
+		return false
+	}
+	if httpServer.webSockets == nil {
+		httpServer.webSockets = make(map[*WebSocketConn]struct{})
+	}
+	httpServer.webSockets[conn] = struct{}{}
+	httpServer.webSocketsWg.Add(1)
+	return true
+}
+
+func (httpServer *HttpServer) untrackWebSocket(conn *WebSocketConn) {
+	httpServer.mu.Lock()
+	delete(httpServer.webSockets, conn)
+	httpServer.mu.Unlock()
+	httpServer.webSocketsWg.Done()
 }
 
 func httpHandler(handler HTTPHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		req := &HTTPServerRequest{
-			Method:      r.Method,
-			Path:        r.URL.Path,
-			PathParams:  make(map[string]string),
-			QueryParams: make(map[string]string),
-			Headers:     make(map[string]string),
-			Body:        r.Body,
-			Context:     r.Context(),
-		}
-		for k, v := range r.Header {
-			req.Headers[k] = v[0]
-		}
-		for k, v := range r.URL.Query() {
-			req.QueryParams[k] = v[0]
-		}
-		params, ok := r.Context().Value("pathParams").(map[string]string)
-		if ok {
-			for k, v := range params {
-				req.PathParams[k] = v
-			}
-		}
+		req := newServerRequest(r)
+		defer req.cleanupMultipart()
 
 		res := &HTTPServerResponse{
 			Headers: make(map[string]string),
@@ -95,6 +282,11 @@ func httpHandler(handler HTTPHandler) http.HandlerFunc {
 
 		handler(req, res)
 
+		if err, ok := bodyLimitExceeded(r); ok {
+			res.stream = nil
+			WriteProblem(req, res, err)
+		}
+
 		if res.StatusCode == 0 {
 			res.StatusCode = http.StatusOK // Default
 		}
@@ -102,51 +294,101 @@ func httpHandler(handler HTTPHandler) http.HandlerFunc {
 		for k, v := range res.Headers {
 			w.Header().Set(k, v)
 		}
+		for k, values := range res.extraHeaders {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
 
 		w.WriteHeader(res.StatusCode)
 
+		if res.stream != nil {
+			writeStream(w, r, res.stream)
+			return
+		}
+
 		if res.Body != nil {
 			io.Copy(w, res.Body)
 		}
 	}
 }
 
-func matchRoute(pattern, actual string) (map[string]string, bool) {
-	pParts := strings.Split(strings.Trim(pattern, "/"), "/")
-	aParts := strings.Split(strings.Trim(actual, "/"), "/")
-
-	if len(pParts) != len(aParts) {
-		return nil, false
+func newServerRequest(r *http.Request) *HTTPServerRequest {
+	req := &HTTPServerRequest{
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		PathParams:  make(map[string]string),
+		QueryParams: make(map[string]string),
+		Headers:     make(map[string]string),
+		Body:        r.Body,
+		Context:     r.Context(),
+		raw:         r,
+		query:       r.URL.Query(),
 	}
-
-	params := make(map[string]string)
-	for i := range pParts {
-		if strings.HasPrefix(pParts[i], ":") {
-			params[strings.TrimPrefix(pParts[i], ":")] = aParts[i]
-		} else if pParts[i] != aParts[i] {
-			return nil, false
+	for k, v := range r.Header {
+		req.Headers[k] = v[0]
+	}
+	for k, v := range req.query {
+		req.QueryParams[k] = v[0]
+	}
+	params, ok := r.Context().Value(pathParamsKey).(map[string]string)
+	if ok {
+		for k, v := range params {
+			req.PathParams[k] = v
 		}
 	}
+	req.Claims, _ = ClaimsFromContext(r.Context())
+	return req
+}
 
-	return params, true
+type HttpServer struct {
+	mu             sync.Mutex
+	server         *http.Server
+	closing        bool
+	webSockets     map[*WebSocketConn]struct{}
+	webSocketsWg   sync.WaitGroup
+	middlewares    []func(http.Handler) http.Handler
+	router         *router
+	routes         []*route
+	openAPIConfig  *OpenAPIConfig
+	metricsEnabled bool
+	tracingEnabled bool
 }
 
-type routeKey struct {
-	Method string
-	Path   string
+type HTTPServerResponse struct {
+	StatusCode   int
+	Headers      map[string]string
+	Body         io.Reader
+	extraHeaders http.Header
+	stream       func(StreamWriter) error
 }
 
-type HttpServer struct {
-	middlewares []func(http.Handler) http.Handler
-	routes      map[routeKey]http.HandlerFunc
+// AddHeader appends a value to a response header, alongside any value set
+// through Headers.
+func (res *HTTPServerResponse) AddHeader(key, value string) {
+	if res.extraHeaders == nil {
+		res.extraHeaders = make(http.Header)
+	}
+	res.extraHeaders.Add(key, value)
 }
 
-type HTTPServerResponse struct {
-	StatusCode int
-	Headers    map[string]string
-	Body       io.Reader
+// SetCookie adds a Set-Cookie header. Invalid cookies are dropped with a
+// warning.
+func (res *HTTPServerResponse) SetCookie(cookie *http.Cookie) {
+	if err := cookie.Valid(); err != nil {
+		GetLogger().Warn(fmt.Sprintf("[HTTP] Dropping cookie %q: %v", cookie.Name, err))
+		return
+	}
+	res.AddHeader("Set-Cookie", cookie.String())
+}
+
+// ClearCookie tells the client to delete the named cookie set for path.
+func (res *HTTPServerResponse) ClearCookie(name, path string) {
+	res.SetCookie(&http.Cookie{Name: name, Path: path, MaxAge: -1})
 }
 
+// HTTPServerRequest exposes the first value of every header and query
+// parameter through its maps; use HeaderValues and QueryAll for the rest.
 type HTTPServerRequest struct {
 	Method      string
 	Path        string
@@ -155,6 +397,55 @@ type HTTPServerRequest struct {
 	Headers     map[string]string
 	Body        io.Reader
 	Context     context.Context
+	Claims      *Claims // set when Authenticate ran
+	raw         *http.Request
+	query       url.Values
+
+	multipart          *MultipartForm
+	multipartErr       error
+	multipartTemporary bool
+}
+
+func (req *HTTPServerRequest) QueryAll(key string) []string {
+	if req.query == nil {
+		if value, ok := req.QueryParams[key]; ok {
+			return []string{value}
+		}
+		return nil
+	}
+	return req.query[key]
+}
+
+func (req *HTTPServerRequest) HeaderValues(key string) []string {
+	if req.raw == nil {
+		if value, ok := req.Headers[http.CanonicalHeaderKey(key)]; ok {
+			return []string{value}
+		}
+		return nil
+	}
+	return req.raw.Header.Values(key)
+}
+
+func (req *HTTPServerRequest) Cookie(name string) (*http.Cookie, bool) {
+	for _, cookie := range req.Cookies() {
+		if cookie.Name == name {
+			return cookie, true
+		}
+	}
+	return nil, false
+}
+
+// Cookies parses the Cookie header, falling back to Headers for requests
+// built by hand, such as in tests.
+func (req *HTTPServerRequest) Cookies() []*http.Cookie {
+	if req.raw == nil {
+		header, ok := req.Headers["Cookie"]
+		if !ok {
+			return nil
+		}
+		return (&http.Request{Header: http.Header{"Cookie": {header}}}).Cookies()
+	}
+	return req.raw.Cookies()
 }
 
 type HTTPHandler func(*HTTPServerRequest, *HTTPServerResponse)
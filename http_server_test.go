@@ -0,0 +1,445 @@
+package gogi
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dejaniskra/go-gi/internal/config"
+)
+
+// dialTestWebSocket completes a WebSocket handshake with path on ts.
+func dialTestWebSocket(t *testing.T, ts *httptest.Server, path string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status %d, want 101", res.StatusCode)
+	}
+	return conn, br
+}
+
+func TestShutdownClosesWebSocketsWhenServerShutdownFails(t *testing.T) {
+	server := &HttpServer{router: newRouter()}
+	started, release := make(chan struct{}), make(chan struct{})
+	server.addRoute(nil, HTTP_GET, "/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	server.addWebSocket(nil, "/ws", func(req *HTTPServerRequest, conn *WebSocketConn) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	ts := httptest.NewServer(server.handler())
+	server.server = ts.Config
+	defer ts.Close()
+	defer close(release)
+
+	conn, br := dialTestWebSocket(t, ts, "/ws")
+	go http.Get(ts.URL + "/slow")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("shutdown error %v, want deadline exceeded", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	frame := make([]byte, 4)
+	if _, err := io.ReadFull(br, frame); err != nil {
+		t.Fatalf("reading close frame: %v", err)
+	}
+	if frame[0] != 0x88 || frame[2] != 0x03 || frame[3] != 0xe9 {
+		t.Fatalf("frame % x, want a 1001 close", frame)
+	}
+}
+
+func TestShutdownBeforeStart(t *testing.T) {
+	var application Application
+	application.httpServer = &HttpServer{router: newRouter()}
+	application.httpServer.addRoute(nil, HTTP_GET, "/", http.NotFoundHandler())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := application.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if err := application.Shutdown(ctx); err != nil {
+		t.Fatalf("second shutdown: %v", err)
+	}
+
+	started := make(chan error, 1)
+	go func() { started <- application.Start() }()
+	select {
+	case err := <-started:
+		if err != http.ErrServerClosed {
+			t.Fatalf("start error %v, want http.ErrServerClosed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("start served after the application was shut down")
+	}
+}
+
+func TestZeroApplicationShutdown(t *testing.T) {
+	var application Application
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := application.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+type testCertificates struct {
+	caFile, certFile, keyFile string
+	client                    tls.Certificate
+	roots                     *x509.CertPool
+}
+
+// newTestCertificates writes a CA and a server certificate for 127.0.0.1
+// signed by it to dir, and returns a client certificate from the same CA.
+func newTestCertificates(t *testing.T, dir string) *testCertificates {
+	t.Helper()
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	caKey := newKey()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key := newKey()
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der, key
+	}
+
+	certs := &testCertificates{roots: x509.NewCertPool()}
+	certs.roots.AddCert(ca)
+	certs.caFile = writePEM("ca.pem", "CERTIFICATE", caDER)
+
+	serverDER, serverKey := issue(2, x509.ExtKeyUsageServerAuth)
+	serverKeyDER, _ := x509.MarshalPKCS8PrivateKey(serverKey)
+	certs.certFile = writePEM("server.pem", "CERTIFICATE", serverDER)
+	certs.keyFile = writePEM("server-key.pem", "PRIVATE KEY", serverKeyDER)
+
+	clientDER, clientKey := issue(3, x509.ExtKeyUsageClientAuth)
+	certs.client = tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+	return certs
+}
+
+// serveTestConfig serves a handler echoing the protocol with the server
+// newHTTPServer builds for cfg, and returns its base URL.
+func serveTestConfig(t *testing.T, cfg *config.Http) string {
+	t.Helper()
+	intPtr := func(v int) *int { return &v }
+	cfg.Port = intPtr(0)
+	cfg.MaxHeaderBytes = intPtr(1 << 20)
+	cfg.Timeouts = &config.Timeouts{ReadRequest: intPtr(5), ReadRequestHeader: intPtr(5), ResponseWrite: intPtr(5), Idle: intPtr(5)}
+
+	srv, err := newHTTPServer(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	if cfg.TLS != nil {
+		go srv.ServeTLS(ln, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		return "https://" + ln.Addr().String()
+	}
+	go srv.Serve(ln)
+	return "http://" + ln.Addr().String()
+}
+
+func TestServerProtocols(t *testing.T) {
+	certs := newTestCertificates(t, t.TempDir())
+	serverTLS := func(minVersion, clientCA, clientAuth string) *config.TLS {
+		return &config.TLS{CertFile: certs.certFile, KeyFile: certs.keyFile, MinVersion: minVersion, ClientCAFile: clientCA, ClientAuth: clientAuth}
+	}
+
+	type client struct {
+		http1, http2, h2c bool
+		cert              bool
+		maxVersion        uint16
+		wantProto         string // empty expects the request to fail
+	}
+	tests := []struct {
+		name      string
+		protocols config.Protocols
+		tls       *config.TLS
+		clients   []client
+	}{
+		{
+			name:      "HTTP/1.1 only",
+			protocols: config.Protocols{HTTP1: true},
+			clients: []client{
+				{http1: true, wantProto: "HTTP/1.1"},
+				{h2c: true},
+			},
+		},
+		{
+			name:      "h2c with HTTP/1.1",
+			protocols: config.Protocols{HTTP1: true, HTTP2: true},
+			clients: []client{
+				{h2c: true, wantProto: "HTTP/2.0"},
+				{http1: true, wantProto: "HTTP/1.1"},
+			},
+		},
+		{
+			name:      "h2c only",
+			protocols: config.Protocols{HTTP2: true},
+			clients: []client{
+				{h2c: true, wantProto: "HTTP/2.0"},
+				{http1: true},
+			},
+		},
+		{
+			name:      "TLS negotiates HTTP/2",
+			protocols: config.Protocols{HTTP1: true, HTTP2: true},
+			tls:       serverTLS("1.2", "", ""),
+			clients: []client{
+				{http1: true, http2: true, wantProto: "HTTP/2.0"},
+				{http1: true, wantProto: "HTTP/1.1"},
+			},
+		},
+		{
+			name:      "TLS with HTTP/2 only",
+			protocols: config.Protocols{HTTP2: true},
+			tls:       serverTLS("1.2", "", ""),
+			clients: []client{
+				{http2: true, wantProto: "HTTP/2.0"},
+				{http1: true},
+			},
+		},
+		{
+			name:      "TLS 1.3 minimum",
+			protocols: config.Protocols{HTTP1: true},
+			tls:       serverTLS("1.3", "", ""),
+			clients: []client{
+				{http1: true, wantProto: "HTTP/1.1"},
+				{http1: true, maxVersion: tls.VersionTLS12},
+			},
+		},
+		{
+			name:      "mTLS requires a client certificate",
+			protocols: config.Protocols{HTTP1: true},
+			tls:       serverTLS("1.2", certs.caFile, "require_and_verify"),
+			clients: []client{
+				{http1: true, cert: true, wantProto: "HTTP/1.1"},
+				{http1: true},
+			},
+		},
+		{
+			name:      "mTLS verifies a certificate if given",
+			protocols: config.Protocols{HTTP1: true},
+			tls:       serverTLS("1.2", certs.caFile, "verify_if_given"),
+			clients: []client{
+				{http1: true, wantProto: "HTTP/1.1"},
+				{http1: true, cert: true, wantProto: "HTTP/1.1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			protocols := tt.protocols
+			url := serveTestConfig(t, &config.Http{Protocols: &protocols, TLS: tt.tls})
+
+			for _, c := range tt.clients {
+				transport := &http.Transport{
+					Protocols: new(http.Protocols),
+					TLSClientConfig: &tls.Config{
+						RootCAs:    certs.roots,
+						MaxVersion: c.maxVersion,
+					},
+				}
+				transport.Protocols.SetHTTP1(c.http1)
+				transport.Protocols.SetHTTP2(c.http2)
+				transport.Protocols.SetUnencryptedHTTP2(c.h2c)
+				if c.cert {
+					transport.TLSClientConfig.Certificates = []tls.Certificate{certs.client}
+				}
+				client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+
+				res, err := client.Get(url)
+				if c.wantProto == "" {
+					if err == nil {
+						body, _ := io.ReadAll(res.Body)
+						res.Body.Close()
+						t.Errorf("client %+v: served %s, want the request refused", c, body)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("client %+v: %v", c, err)
+					continue
+				}
+				body, _ := io.ReadAll(res.Body)
+				res.Body.Close()
+				if string(body) != c.wantProto {
+					t.Errorf("client %+v: served over %s, want %s", c, body, c.wantProto)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestMultiValueAccessors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/items?tag=a&tag=b&empty=&limit=10", nil)
+	r.Header.Add("X-Forwarded-For", "10.0.0.1")
+	r.Header.Add("X-Forwarded-For", "10.0.0.2")
+	r.Header.Set("Cookie", "session=abc; theme=dark")
+	fromRaw := newServerRequest(r)
+
+	handBuilt := &HTTPServerRequest{
+		QueryParams: map[string]string{"tag": "a", "empty": "", "limit": "10"},
+		Headers:     map[string]string{"X-Forwarded-For": "10.0.0.1", "Cookie": "session=abc; theme=dark"},
+	}
+
+	tests := []struct {
+		name       string
+		req        *HTTPServerRequest
+		wantTags   []string
+		wantHeader []string
+	}{
+		{name: "from the raw request", req: fromRaw, wantTags: []string{"a", "b"}, wantHeader: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "without a raw request", req: handBuilt, wantTags: []string{"a"}, wantHeader: []string{"10.0.0.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			if got := req.QueryAll("tag"); !slices.Equal(got, tt.wantTags) {
+				t.Errorf("QueryAll(tag) = %q, want %q", got, tt.wantTags)
+			}
+			if got := req.QueryAll("empty"); !slices.Equal(got, []string{""}) {
+				t.Errorf("QueryAll(empty) = %q, want one empty value", got)
+			}
+			if got := req.QueryAll("missing"); got != nil {
+				t.Errorf("QueryAll(missing) = %q, want nil", got)
+			}
+			if req.QueryParams["tag"] != "a" {
+				t.Errorf("QueryParams[tag] = %q, want the first value", req.QueryParams["tag"])
+			}
+
+			// Header lookups are case-insensitive.
+			if got := req.HeaderValues("x-forwarded-for"); !slices.Equal(got, tt.wantHeader) {
+				t.Errorf("HeaderValues = %q, want %q", got, tt.wantHeader)
+			}
+			if got := req.HeaderValues("X-Missing"); len(got) != 0 {
+				t.Errorf("HeaderValues(X-Missing) = %q, want none", got)
+			}
+			if req.Headers["X-Forwarded-For"] != "10.0.0.1" {
+				t.Errorf("Headers[X-Forwarded-For] = %q, want the first value", req.Headers["X-Forwarded-For"])
+			}
+
+			if cookie, ok := req.Cookie("theme"); !ok || cookie.Value != "dark" {
+				t.Errorf("Cookie(theme) = %v, %v, want dark", cookie, ok)
+			}
+			if _, ok := req.Cookie("missing"); ok {
+				t.Error("Cookie(missing) found a cookie")
+			}
+			if got := len(req.Cookies()); got != 2 {
+				t.Errorf("%d cookies, want 2", got)
+			}
+		})
+	}
+
+	if got := (&HTTPServerRequest{}).Cookies(); got != nil {
+		t.Errorf("Cookies() on an empty request = %v, want nil", got)
+	}
+}
+
+func TestResponseMultiValueHeaders(t *testing.T) {
+	server := &HttpServer{router: newRouter()}
+	server.addRoute(nil, HTTP_GET, "/", httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		res.StatusCode = http.StatusOK
+		res.Headers["Link"] = "</a>; rel=preload"
+		res.AddHeader("Link", "</b>; rel=preload")
+		res.SetCookie(&http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+		res.SetCookie(&http.Cookie{Name: "bad name", Value: "x"})
+		res.ClearCookie("theme", "/")
+	}))
+
+	w := httptest.NewRecorder()
+	server.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := w.Header().Values("Link"); !slices.Equal(got, []string{"</a>; rel=preload", "</b>; rel=preload"}) {
+		t.Errorf("Link %q, want both values", got)
+	}
+	want := []string{"session=abc; HttpOnly", "theme=; Path=/; Max-Age=0"}
+	if got := w.Header().Values("Set-Cookie"); !slices.Equal(got, want) {
+		t.Errorf("Set-Cookie %q, want %q without the invalid cookie", got, want)
+	}
+}
@@ -16,13 +16,15 @@ func NewInMemoryPubSub() *InMemoryPubSub {
 	}
 }
 
-func (ps *InMemoryPubSub) Publish(ctx context.Context, event *Event) error {
+func (ps *InMemoryPubSub) Publish(ctx context.Context, event *Event) (err error) {
+	_, event, done := startPublish(ctx, "memory", event)
+	defer done(&err)
 	ps.mu.RLock()
 	handlers := ps.subscribers[event.Topic]
 	ps.mu.RUnlock()
 
 	for _, handler := range handlers {
-		go handler(event) // fire-and-forget
+		go processEvent(context.Background(), "memory", event, handler) // fire-and-forget
 	}
 	return nil
 }
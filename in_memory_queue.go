@@ -17,7 +17,9 @@ func NewInMemoryJobQueue() *InMemoryJobQueue {
 	}
 }
 
-func (q *InMemoryJobQueue) SendJob(ctx context.Context, job *Job) error {
+func (q *InMemoryJobQueue) SendJob(ctx context.Context, job *Job) (err error) {
+	_, job, done := startJobSend(ctx, "memory", "", job)
+	defer done(&err)
 	q.mu.Lock()
 	defer q.mu.Unlock()
 	q.queue = append(q.queue, job)
@@ -34,7 +36,7 @@ func (q *InMemoryJobQueue) ReceiveJobs(ctx context.Context, handler func(*Job) e
 		job := q.queue[0]
 		q.queue = q.queue[1:]
 		q.mu.Unlock()
-		if err := handler(job); err != nil {
+		if err := processJob(ctx, "memory", "", job, handler); err != nil {
 			return err
 		}
 	}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
 )
 
 type DBRoleConfig struct {
@@ -23,6 +25,8 @@ type DBConnection struct {
 	ConnMaxLifetime *int    `json:"max_life_time"`        // seconds
 }
 
+// Protocols selects what the server speaks. Both may be enabled, so HTTP/2
+// clients and HTTP/1.1 ones such as health probes share the port.
 type Protocols struct {
 	HTTP1 bool `json:"http_1"`
 	HTTP2 bool `json:"http_2"`
@@ -33,13 +37,35 @@ type Timeouts struct {
 	ReadRequestHeader *int `json:"read_request_header"`
 	ResponseWrite     *int `json:"response_write"`
 	Idle              *int `json:"idle"`
+	Shutdown          *int `json:"shutdown"`
+}
+
+type TLS struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	MinVersion   string `json:"min_version"`    // "1.2" or "1.3"
+	ClientCAFile string `json:"client_ca_file"` // enables mTLS
+	ClientAuth   string `json:"client_auth"`    // "verify_if_given" or "require_and_verify"
+}
+
+type CORS struct {
+	AllowedOrigins        []string `json:"allowed_origins"`         // exact, "*" or wildcard subdomain like "https://*.example.com"
+	AllowedOriginPatterns []string `json:"allowed_origin_patterns"` // regular expressions matched against the whole origin
+	AllowedMethods        []string `json:"allowed_methods"`
+	AllowedHeaders        []string `json:"allowed_headers"` // empty allows whatever a preflight asks for
+	ExposedHeaders        []string `json:"exposed_headers"`
+	AllowCredentials      *bool    `json:"allow_credentials"`
+	MaxAge                *int     `json:"max_age"` // seconds
 }
 
 type Http struct {
 	Port           *int       `json:"port"`
 	Protocols      *Protocols `json:"protocols"`
+	TLS            *TLS       `json:"tls"`
+	CORS           *CORS      `json:"cors"`
 	Timeouts       *Timeouts  `json:"timeouts"`
 	MaxHeaderBytes *int       `json:"max_header_bytes"`
+	MaxBodyBytes   *int       `json:"max_body_bytes"`
 }
 
 type Log struct {
@@ -137,19 +163,9 @@ func setDefaultHttp(cfg *Config) {
 	if cfg.Http.Protocols == nil {
 		fmt.Println("No http.protocols provided, defaulting to HTTP1")
 		cfg.Http.Protocols = &Protocols{HTTP1: true}
-	} else {
-		if !cfg.Http.Protocols.HTTP1 && !cfg.Http.Protocols.HTTP2 {
-			fmt.Println("All http.protocols set to false, defaulting to HTTP1")
-			cfg.Http.Protocols.HTTP1 = true
-		} else {
-			// Ensure only one protocol is true
-			if cfg.Http.Protocols.HTTP2 {
-				cfg.Http.Protocols.HTTP1 = false
-			} else {
-				cfg.Http.Protocols.HTTP1 = true
-				cfg.Http.Protocols.HTTP2 = false
-			}
-		}
+	} else if !cfg.Http.Protocols.HTTP1 && !cfg.Http.Protocols.HTTP2 {
+		fmt.Println("All http.protocols set to false, defaulting to HTTP1")
+		cfg.Http.Protocols.HTTP1 = true
 	}
 
 	// Timeouts
@@ -161,16 +177,90 @@ func setDefaultHttp(cfg *Config) {
 	defaultInt(&cfg.Http.Timeouts.ReadRequestHeader, 30, "http.timeouts.read_request_header")
 	defaultInt(&cfg.Http.Timeouts.ResponseWrite, 30, "http.timeouts.response_write")
 	defaultInt(&cfg.Http.Timeouts.Idle, 30, "http.timeouts.idle")
+	defaultInt(&cfg.Http.Timeouts.Shutdown, 30, "http.timeouts.shutdown")
 
 	// Max header bytes
 	defaultInt(&cfg.Http.MaxHeaderBytes, 1<<20, "http.max_header_bytes")
 
+	// Max body bytes, also applied to decompressed bodies
+	defaultInt(&cfg.Http.MaxBodyBytes, 10<<20, "http.max_body_bytes")
+
 	if *cfg.Http.Port <= 0 {
 		panic("http.port must be a positive integer")
 	}
 	if *cfg.Http.MaxHeaderBytes <= 0 {
 		panic("http.max_header_bytes must be a positive integer")
 	}
+	if *cfg.Http.MaxBodyBytes <= 0 {
+		panic("http.max_body_bytes must be a positive integer")
+	}
+
+	setDefaultTLS(cfg.Http.TLS)
+	setDefaultCORS(cfg.Http.CORS)
+}
+
+func setDefaultTLS(tls *TLS) {
+	if tls == nil {
+		return
+	}
+
+	if tls.CertFile == "" || tls.KeyFile == "" {
+		panic("http.tls.cert_file and http.tls.key_file are required when http.tls is set")
+	}
+
+	if tls.MinVersion == "" {
+		fmt.Println("No http.tls.min_version provided, defaulting to 1.2")
+		tls.MinVersion = "1.2"
+	}
+	if tls.MinVersion != "1.2" && tls.MinVersion != "1.3" {
+		panic("http.tls.min_version must be 1.2 or 1.3")
+	}
+
+	if tls.ClientCAFile == "" {
+		if tls.ClientAuth != "" {
+			panic("http.tls.client_auth requires http.tls.client_ca_file")
+		}
+		return
+	}
+	if tls.ClientAuth == "" {
+		fmt.Println("No http.tls.client_auth provided, defaulting to require_and_verify")
+		tls.ClientAuth = "require_and_verify"
+	}
+	if tls.ClientAuth != "verify_if_given" && tls.ClientAuth != "require_and_verify" {
+		panic("http.tls.client_auth must be verify_if_given or require_and_verify")
+	}
+}
+
+func setDefaultCORS(cors *CORS) {
+	if cors == nil {
+		return
+	}
+
+	if len(cors.AllowedOrigins) == 0 && len(cors.AllowedOriginPatterns) == 0 {
+		panic("http.cors requires allowed_origins or allowed_origin_patterns")
+	}
+	for _, pattern := range cors.AllowedOriginPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			panic(fmt.Sprintf("http.cors.allowed_origin_patterns has an invalid pattern %q: %v", pattern, err))
+		}
+	}
+
+	if len(cors.AllowedMethods) == 0 {
+		fmt.Println("No http.cors.allowed_methods provided, defaulting to GET, HEAD, POST, PUT, PATCH, DELETE")
+		cors.AllowedMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	}
+	if cors.AllowCredentials == nil {
+		allow := false
+		cors.AllowCredentials = &allow
+	}
+	if *cors.AllowCredentials && slices.Contains(cors.AllowedOrigins, "*") {
+		panic("http.cors.allowed_origins cannot contain \"*\" when allow_credentials is true")
+	}
+
+	defaultInt(&cors.MaxAge, 600, "http.cors.max_age")
+	if *cors.MaxAge < 0 {
+		panic("http.cors.max_age must not be negative")
+	}
 }
 
 func defaultInt(target **int, value int, name string) {
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func parseTestConfig(t *testing.T, data string) (cfg *Config, panicked string) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = fmt.Sprint(r)
+		}
+	}()
+	validateConfig(cfg)
+	return cfg, ""
+}
+
+func TestDefaultCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		cors        string
+		wantPanic   string
+		wantMethods []string
+		wantMaxAge  int
+		wantCreds   bool
+	}{
+		{
+			name:        "defaults",
+			cors:        `{"allowed_origins": ["https://app.example.com"]}`,
+			wantMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+			wantMaxAge:  600,
+		},
+		{
+			name:        "explicit values kept",
+			cors:        `{"allowed_origin_patterns": ["https://[a-z]+\\.example\\.com"], "allowed_methods": ["GET"], "allow_credentials": true, "max_age": 0}`,
+			wantMethods: []string{"GET"},
+			wantCreds:   true,
+		},
+		{
+			name:        "wildcard origin without credentials",
+			cors:        `{"allowed_origins": ["*"], "allow_credentials": false}`,
+			wantMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+			wantMaxAge:  600,
+		},
+		{name: "no origins", cors: `{"allowed_methods": ["GET"]}`, wantPanic: "requires allowed_origins"},
+		{name: "credentials with wildcard origin", cors: `{"allowed_origins": ["*"], "allow_credentials": true}`, wantPanic: "cannot contain \"*\""},
+		{name: "invalid pattern", cors: `{"allowed_origin_patterns": ["https://(unclosed"]}`, wantPanic: "invalid pattern"},
+		{name: "negative max age", cors: `{"allowed_origins": ["https://a.example"], "max_age": -1}`, wantPanic: "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, panicked := parseTestConfig(t, `{"http": {"cors": `+tt.cors+`}}`)
+			if tt.wantPanic != "" {
+				if !strings.Contains(panicked, tt.wantPanic) {
+					t.Fatalf("panic %q, want one mentioning %q", panicked, tt.wantPanic)
+				}
+				return
+			}
+			if panicked != "" {
+				t.Fatalf("unexpected panic: %s", panicked)
+			}
+
+			cors := cfg.Http.CORS
+			if !slices.Equal(cors.AllowedMethods, tt.wantMethods) {
+				t.Errorf("allowed_methods %v, want %v", cors.AllowedMethods, tt.wantMethods)
+			}
+			if cors.MaxAge == nil || *cors.MaxAge != tt.wantMaxAge {
+				t.Errorf("max_age %v, want %d", cors.MaxAge, tt.wantMaxAge)
+			}
+			if cors.AllowCredentials == nil || *cors.AllowCredentials != tt.wantCreds {
+				t.Errorf("allow_credentials %v, want %v", cors.AllowCredentials, tt.wantCreds)
+			}
+		})
+	}
+}
+
+func TestNoCORSSection(t *testing.T) {
+	for _, data := range []string{`{}`, `{"http": {}}`, `{"http": {"port": 8080}}`} {
+		cfg, panicked := parseTestConfig(t, data)
+		if panicked != "" {
+			t.Fatalf("%s: unexpected panic: %s", data, panicked)
+		}
+		if cfg.Http.CORS != nil || cfg.Http.TLS != nil {
+			t.Errorf("%s: CORS %+v, TLS %+v, want both left off", data, cfg.Http.CORS, cfg.Http.TLS)
+		}
+		if *cfg.Http.MaxBodyBytes != 10<<20 || *cfg.Http.Timeouts.Shutdown != 30 {
+			t.Errorf("%s: other http defaults not applied", data)
+		}
+	}
+}
+
+func TestDefaultTLS(t *testing.T) {
+	tests := []struct {
+		name           string
+		tls            string
+		wantPanic      string
+		wantMinVersion string
+		wantClientAuth string
+	}{
+		{name: "defaults", tls: `{"cert_file": "c.pem", "key_file": "k.pem"}`, wantMinVersion: "1.2"},
+		{name: "TLS 1.3", tls: `{"cert_file": "c.pem", "key_file": "k.pem", "min_version": "1.3"}`, wantMinVersion: "1.3"},
+		{
+			name:           "mTLS defaults to requiring a certificate",
+			tls:            `{"cert_file": "c.pem", "key_file": "k.pem", "client_ca_file": "ca.pem"}`,
+			wantMinVersion: "1.2",
+			wantClientAuth: "require_and_verify",
+		},
+		{
+			name:           "optional client certificate",
+			tls:            `{"cert_file": "c.pem", "key_file": "k.pem", "client_ca_file": "ca.pem", "client_auth": "verify_if_given"}`,
+			wantMinVersion: "1.2",
+			wantClientAuth: "verify_if_given",
+		},
+		{name: "missing key", tls: `{"cert_file": "c.pem"}`, wantPanic: "are required"},
+		{name: "unknown min version", tls: `{"cert_file": "c.pem", "key_file": "k.pem", "min_version": "1.1"}`, wantPanic: "must be 1.2 or 1.3"},
+		{name: "client auth without CA", tls: `{"cert_file": "c.pem", "key_file": "k.pem", "client_auth": "verify_if_given"}`, wantPanic: "requires http.tls.client_ca_file"},
+		{name: "unknown client auth", tls: `{"cert_file": "c.pem", "key_file": "k.pem", "client_ca_file": "ca.pem", "client_auth": "always"}`, wantPanic: "must be verify_if_given or require_and_verify"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, panicked := parseTestConfig(t, `{"http": {"tls": `+tt.tls+`}}`)
+			if tt.wantPanic != "" {
+				if !strings.Contains(panicked, tt.wantPanic) {
+					t.Fatalf("panic %q, want one mentioning %q", panicked, tt.wantPanic)
+				}
+				return
+			}
+			if panicked != "" {
+				t.Fatalf("unexpected panic: %s", panicked)
+			}
+			if got := cfg.Http.TLS.MinVersion; got != tt.wantMinVersion {
+				t.Errorf("min_version %q, want %q", got, tt.wantMinVersion)
+			}
+			if got := cfg.Http.TLS.ClientAuth; got != tt.wantClientAuth {
+				t.Errorf("client_auth %q, want %q", got, tt.wantClientAuth)
+			}
+		})
+	}
+}
+
+func TestDefaultProtocols(t *testing.T) {
+	tests := []struct {
+		protocols string
+		want      Protocols
+	}{
+		{protocols: `null`, want: Protocols{HTTP1: true}},
+		{protocols: `{}`, want: Protocols{HTTP1: true}},
+		{protocols: `{"http_2": true}`, want: Protocols{HTTP2: true}},
+		{protocols: `{"http_1": true, "http_2": true}`, want: Protocols{HTTP1: true, HTTP2: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.protocols, func(t *testing.T) {
+			cfg, panicked := parseTestConfig(t, `{"http": {"protocols": `+tt.protocols+`}}`)
+			if panicked != "" {
+				t.Fatalf("unexpected panic: %s", panicked)
+			}
+			if *cfg.Http.Protocols != tt.want {
+				t.Fatalf("protocols %+v, want %+v", *cfg.Http.Protocols, tt.want)
+			}
+		})
+	}
+}
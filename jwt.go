@@ -0,0 +1,518 @@
+package gogi
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+
+	jwksDefaultRefresh  = 10 * time.Minute
+	jwksMinRefetch      = time.Minute
+	jwksFetchTimeout    = 10 * time.Second
+	jwksMaxResponseSize = 1 << 20
+	jwtMinRSABits       = 2048
+)
+
+var (
+	errTokenMalformed = errors.New("token is malformed")
+	errTokenSignature = errors.New("token signature is invalid")
+	errTokenExpired   = errors.New("token has expired")
+)
+
+// JWTConfig configures JWT verification. Tokens are checked against Secret
+// for HS256 and against the keys of JWKSFile or JWKSURL for RS256 and ES256;
+// Algorithms narrows what is accepted and defaults to whichever of them
+// have keys. A JWKS URL is refetched every JWKSRefresh (default 10m) and
+// early, at most once a minute, when a token names a key it does not have,
+// so rotated keys are picked up.
+//
+// Tokens must carry exp. When Issuer is set iss must equal it, and when
+// Audience is set aud must contain one of its entries. Leeway tolerates
+// clock skew on exp and nbf. Scopes are read from ScopeClaim (default
+// "scope", a space-separated string, falling back to "scp") and roles from
+// RolesClaim (default "roles").
+type JWTConfig struct {
+	Secret      []byte
+	JWKSFile    string
+	JWKSURL     string
+	JWKSRefresh time.Duration
+	Algorithms  []string
+	Issuer      string
+	Audience    []string
+	Leeway      time.Duration
+	ScopeClaim  string
+	RolesClaim  string
+}
+
+// JWTVerifier validates tokens against a JWTConfig.
+type JWTVerifier struct {
+	config     JWTConfig
+	algorithms []string
+	keys       *jwksCache
+}
+
+// NewJWTVerifier checks config and loads the JWKS file, if any. A JWKS URL
+// is only fetched when the first token needs it.
+func NewJWTVerifier(config JWTConfig) (*JWTVerifier, error) {
+	if config.JWKSFile != "" && config.JWKSURL != "" {
+		return nil, errors.New("jwt: set either JWKSFile or JWKSURL, not both")
+	}
+	if config.JWKSRefresh <= 0 {
+		config.JWKSRefresh = jwksDefaultRefresh
+	}
+	if config.ScopeClaim == "" {
+		config.ScopeClaim = "scope"
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+
+	verifier := &JWTVerifier{config: config}
+	hasKeys := config.JWKSFile != "" || config.JWKSURL != ""
+	if len(config.Algorithms) == 0 {
+		if len(config.Secret) > 0 {
+			verifier.algorithms = append(verifier.algorithms, HS256)
+		}
+		if hasKeys {
+			verifier.algorithms = append(verifier.algorithms, RS256, ES256)
+		}
+	} else {
+		for _, alg := range config.Algorithms {
+			switch alg {
+			case HS256:
+				if len(config.Secret) == 0 {
+					return nil, errors.New("jwt: HS256 requires Secret")
+				}
+			case RS256, ES256:
+				if !hasKeys {
+					return nil, fmt.Errorf("jwt: %s requires JWKSFile or JWKSURL", alg)
+				}
+			default:
+				return nil, fmt.Errorf("jwt: unsupported algorithm %q", alg)
+			}
+		}
+		verifier.algorithms = config.Algorithms
+	}
+	if len(verifier.algorithms) == 0 {
+		return nil, errors.New("jwt: a Secret, JWKSFile or JWKSURL is required")
+	}
+
+	if hasKeys {
+		verifier.keys = &jwksCache{url: config.JWKSURL, refresh: config.JWKSRefresh, client: &http.Client{Timeout: jwksFetchTimeout}}
+		if config.JWKSFile != "" {
+			data, err := os.ReadFile(config.JWKSFile)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: reading JWKS file: %w", err)
+			}
+			keys, err := parseJWKS(data)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: %s: %w", config.JWKSFile, err)
+			}
+			verifier.keys.keys = keys
+		}
+	}
+	return verifier, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// registeredClaims are the claims Verify interprets. Audience may be a
+// string or an array of strings.
+type registeredClaims struct {
+	Subject   string       `json:"sub"`
+	Issuer    string       `json:"iss"`
+	Audience  stringList   `json:"aud"`
+	ExpiresAt *json.Number `json:"exp"`
+	NotBefore *json.Number `json:"nbf"`
+	IssuedAt  *json.Number `json:"iat"`
+	ID        string       `json:"jti"`
+}
+
+// Verify checks the token's signature and claims and returns its claims.
+func (verifier *JWTVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errTokenMalformed
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errTokenMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errTokenMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errTokenMalformed
+	}
+
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, errTokenMalformed
+	}
+	if !slices.Contains(verifier.algorithms, header.Alg) {
+		return nil, fmt.Errorf("token algorithm %q is not accepted", header.Alg)
+	}
+	if err := verifier.verifySignature(ctx, header, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	return verifier.claims(payload)
+}
+
+func (verifier *JWTVerifier) verifySignature(ctx context.Context, header jwtHeader, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	if header.Alg == HS256 {
+		mac := hmac.New(sha256.New, verifier.config.Secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errTokenSignature
+		}
+		return nil
+	}
+
+	key, err := verifier.keys.find(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return err
+	}
+	switch header.Alg {
+	case RS256:
+		if rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) != nil {
+			return errTokenSignature
+		}
+	case ES256:
+		if len(signature) != 64 {
+			return errTokenSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key.(*ecdsa.PublicKey), digest[:], r, s) {
+			return errTokenSignature
+		}
+	}
+	return nil
+}
+
+func (verifier *JWTVerifier) claims(payload []byte) (*Claims, error) {
+	var registered registeredClaims
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&registered); err != nil {
+		return nil, errTokenMalformed
+	}
+
+	now := time.Now()
+	leeway := verifier.config.Leeway
+	claims := &Claims{
+		Subject:  registered.Subject,
+		Issuer:   registered.Issuer,
+		Audience: registered.Audience,
+		ID:       registered.ID,
+		Method:   AuthJWT,
+		raw:      payload,
+	}
+	var err error
+	if claims.ExpiresAt, err = numericDate(registered.ExpiresAt); err != nil || claims.ExpiresAt.IsZero() {
+		return nil, errors.New("token has no valid exp claim")
+	}
+	if now.After(claims.ExpiresAt.Add(leeway)) {
+		return nil, errTokenExpired
+	}
+	if claims.NotBefore, err = numericDate(registered.NotBefore); err != nil {
+		return nil, errors.New("token has an invalid nbf claim")
+	}
+	if !claims.NotBefore.IsZero() && now.Add(leeway).Before(claims.NotBefore) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if claims.IssuedAt, err = numericDate(registered.IssuedAt); err != nil {
+		return nil, errors.New("token has an invalid iat claim")
+	}
+	if verifier.config.Issuer != "" && claims.Issuer != verifier.config.Issuer {
+		return nil, errors.New("token issuer is not accepted")
+	}
+	if len(verifier.config.Audience) > 0 && !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+		return slices.Contains(verifier.config.Audience, aud)
+	}) {
+		return nil, errors.New("token audience is not accepted")
+	}
+
+	var extra map[string]json.RawMessage
+	json.Unmarshal(payload, &extra)
+	claims.Scopes = claimList(extra[verifier.config.ScopeClaim], " ")
+	if claims.Scopes == nil && verifier.config.ScopeClaim == "scope" {
+		claims.Scopes = claimList(extra["scp"], " ")
+	}
+	claims.Roles = claimList(extra[verifier.config.RolesClaim], ",")
+	return claims, nil
+}
+
+// maxNumericDate is the last second of year 9999. Later dates, which would
+// overflow nanoseconds since the epoch, are rejected rather than wrapped.
+const maxNumericDate = 253402300799
+
+// numericDate reads an RFC 7519 NumericDate, seconds since the epoch that
+// may carry a fraction.
+func numericDate(value *json.Number) (time.Time, error) {
+	if value == nil {
+		return time.Time{}, nil
+	}
+	seconds, err := value.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if math.IsNaN(seconds) || math.Abs(seconds) > maxNumericDate {
+		return time.Time{}, fmt.Errorf("numeric date %s is out of range", value)
+	}
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))), nil
+}
+
+// claimList reads a claim holding either an array of strings or a single
+// string of values separated by sep.
+func claimList(raw json.RawMessage, sep string) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return list
+	}
+	var single string
+	if json.Unmarshal(raw, &single) != nil {
+		return nil
+	}
+	for _, value := range strings.Split(single, sep) {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+type stringList []string
+
+func (list *stringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*list = stringList{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(list))
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type publicKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// parseJWKS keeps the RSA and P-256 signing keys of a JWK set and skips
+// the rest, so sets that also publish other key types still load. RSA keys
+// under 2048 bits are skipped as too weak.
+func parseJWKS(data []byte) ([]publicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	var keys []publicKey
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			if k.Alg != "" && k.Alg != RS256 {
+				continue
+			}
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+				return nil, fmt.Errorf("invalid RSA key %q", k.Kid)
+			}
+			key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+			if bits := key.N.BitLen(); bits < jwtMinRSABits {
+				GetLogger().Warn(fmt.Sprintf("[JWT] Ignoring RSA key %q: %d bits is below the %d-bit minimum", k.Kid, bits, jwtMinRSABits))
+				continue
+			}
+			keys = append(keys, publicKey{kid: k.Kid, alg: RS256, key: key})
+		case "EC":
+			if k.Crv != "P-256" || (k.Alg != "" && k.Alg != ES256) {
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+				return nil, fmt.Errorf("invalid EC key %q", k.Kid)
+			}
+			// ecdh rejects points that are not on the curve.
+			if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+				return nil, fmt.Errorf("invalid EC key %q: %w", k.Kid, err)
+			}
+			key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			keys = append(keys, publicKey{kid: k.Kid, alg: ES256, key: key})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no usable signing keys")
+	}
+	return keys, nil
+}
+
+// jwksCache holds the keys of a JWKS file or URL. Only one fetch runs at a
+// time; concurrent callers wait for it rather than fetching again.
+type jwksCache struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	fetchMu   sync.Mutex
+	keys      []publicKey
+	fetchedAt time.Time
+}
+
+func (cache *jwksCache) find(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	cache.mu.Lock()
+	keys, fetchedAt := cache.keys, cache.fetchedAt
+	cache.mu.Unlock()
+
+	if cache.url != "" && time.Since(fetchedAt) > cache.refresh {
+		if err := cache.fetch(ctx, fetchedAt); err != nil && len(keys) == 0 {
+			return nil, err
+		}
+	} else if key := matchKey(keys, kid, alg); key != nil {
+		return key, nil
+	} else if cache.url != "" && time.Since(fetchedAt) > jwksMinRefetch {
+		// An unknown kid usually means the keys were rotated.
+		cache.fetch(ctx, fetchedAt)
+	}
+
+	cache.mu.Lock()
+	keys = cache.keys
+	cache.mu.Unlock()
+	if key := matchKey(keys, kid, alg); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no %s key matches kid %q", alg, kid)
+}
+
+// fetch reloads the keys unless another caller already did since seen.
+// On failure the previous keys stay in use.
+func (cache *jwksCache) fetch(ctx context.Context, seen time.Time) error {
+	cache.fetchMu.Lock()
+	defer cache.fetchMu.Unlock()
+
+	cache.mu.Lock()
+	refreshed := cache.fetchedAt.After(seen)
+	cache.mu.Unlock()
+	if refreshed {
+		return nil
+	}
+
+	keys, err := cache.download(ctx)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	// Failed fetches also count, so an unreachable endpoint is retried on
+	// the refetch schedule rather than by every request.
+	cache.fetchedAt = time.Now()
+	if err != nil {
+		GetLogger().Warn(fmt.Sprintf("[Auth] Fetching JWKS from %s: %v", cache.url, err))
+		if len(cache.keys) > 0 {
+			cache.fetchedAt = cache.fetchedAt.Add(jwksMinRefetch - cache.refresh)
+		}
+		return err
+	}
+	cache.keys = keys
+	return nil
+}
+
+func (cache *jwksCache) download(ctx context.Context) ([]publicKey, error) {
+	// The fetch outlives a cancelled request so its result can be shared.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cache.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := cache.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+// matchKey finds the key for kid, or the only key for alg when the token
+// names none.
+func matchKey(keys []publicKey, kid, alg string) crypto.PublicKey {
+	var match crypto.PublicKey
+	for _, k := range keys {
+		if k.alg != alg {
+			continue
+		}
+		if kid != "" {
+			if k.kid == kid {
+				return k.key
+			}
+			continue
+		}
+		if match != nil {
+			return nil
+		}
+		match = k.key
+	}
+	return match
+}
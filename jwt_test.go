@@ -0,0 +1,377 @@
+package gogi
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testJWTSecret = []byte("test-secret-test-secret-test-sec")
+
+// testRSAKeys generates the RSA keys once; 2048-bit generation is slow.
+var testRSAKeys = sync.OnceValue(func() [3]*rsa.PrivateKey {
+	var keys [3]*rsa.PrivateKey
+	for i, bits := range []int{2048, 2048, 1024} {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			panic(err)
+		}
+		keys[i] = key
+	}
+	return keys
+})
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeTestJWT(header, claims map[string]any) string {
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+	return b64(headerJSON) + "." + b64(claimsJSON)
+}
+
+func signHS256(secret []byte, claims map[string]any) string {
+	signed := encodeTestJWT(map[string]any{"alg": HS256, "typ": "JWT"}, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + b64(mac.Sum(nil))
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	signed := encodeTestJWT(map[string]any{"alg": RS256, "kid": kid}, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + b64(signature)
+}
+
+func signES256(key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	signed := encodeTestJWT(map[string]any{"alg": ES256, "kid": kid}, claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signed + "." + b64(signature)
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   b64(key.N.Bytes()),
+		"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(x), "y": b64(y)}
+}
+
+func jwksJSON(keys ...map[string]string) []byte {
+	data, _ := json.Marshal(map[string]any{"keys": keys})
+	return data
+}
+
+func writeJWKSFile(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, jwksJSON(keys...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func validClaims() map[string]any {
+	return map[string]any{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func TestJWTVerifyAlgorithms(t *testing.T) {
+	rsaKey := testRSAKeys()[0]
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksFile := writeJWKSFile(t, rsaJWK("rsa", rsaKey), ecJWK("ec", ecKey))
+	rsaModulus := rsaKey.N.Bytes()
+
+	tests := []struct {
+		name    string
+		config  JWTConfig
+		token   string
+		wantErr string
+	}{
+		{name: "RS256", config: JWTConfig{JWKSFile: jwksFile, Algorithms: []string{RS256}}, token: signRS256(rsaKey, "rsa", validClaims())},
+		{name: "ES256 by default", config: JWTConfig{JWKSFile: jwksFile}, token: signES256(ecKey, "ec", validClaims())},
+		{name: "HS256 with secret", config: JWTConfig{Secret: testJWTSecret}, token: signHS256(testJWTSecret, validClaims())},
+		{name: "none", config: JWTConfig{JWKSFile: jwksFile, Secret: testJWTSecret}, token: encodeTestJWT(map[string]any{"alg": "none"}, validClaims()) + ".", wantErr: "not accepted"},
+		{name: "HS256 against RS256-only", config: JWTConfig{JWKSFile: jwksFile, Algorithms: []string{RS256}}, token: signHS256(rsaModulus, validClaims()), wantErr: "not accepted"},
+		{name: "ES256 against RS256-only", config: JWTConfig{JWKSFile: jwksFile, Algorithms: []string{RS256}}, token: signES256(ecKey, "ec", validClaims()), wantErr: "not accepted"},
+		{name: "RS256 with wrong key", config: JWTConfig{JWKSFile: jwksFile}, token: signRS256(testRSAKeys()[1], "rsa", validClaims()), wantErr: "signature"},
+		{name: "HS256 with wrong secret", config: JWTConfig{Secret: testJWTSecret}, token: signHS256([]byte("other"), validClaims()), wantErr: "signature"},
+		{name: "tampered payload", config: JWTConfig{Secret: testJWTSecret}, token: tamper(signHS256(testJWTSecret, validClaims())), wantErr: "signature"},
+		{name: "malformed", config: JWTConfig{Secret: testJWTSecret}, token: "not.a.jwt", wantErr: "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := NewJWTVerifier(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := verifier.Verify(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "user-1" || claims.Method != AuthJWT {
+				t.Fatalf("claims %+v", claims)
+			}
+		})
+	}
+}
+
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	claims := validClaims()
+	claims["sub"] = "admin"
+	data, _ := json.Marshal(claims)
+	return parts[0] + "." + b64(data) + "." + parts[2]
+}
+
+func TestNewJWTVerifierErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config JWTConfig
+	}{
+		{name: "nothing to verify with", config: JWTConfig{}},
+		{name: "HS256 without secret", config: JWTConfig{JWKSURL: "https://example.com/jwks", Algorithms: []string{HS256}}},
+		{name: "RS256 without keys", config: JWTConfig{Secret: testJWTSecret, Algorithms: []string{RS256}}},
+		{name: "unsupported algorithm", config: JWTConfig{Secret: testJWTSecret, Algorithms: []string{"none"}}},
+		{name: "file and URL", config: JWTConfig{JWKSFile: "jwks.json", JWKSURL: "https://example.com/jwks"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewJWTVerifier(tt.config); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestJWTVerifyClaims(t *testing.T) {
+	now := time.Now()
+	config := JWTConfig{
+		Secret:   testJWTSecret,
+		Issuer:   "https://issuer.example.com",
+		Audience: []string{"api", "admin"},
+		Leeway:   30 * time.Second,
+	}
+	base := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{
+			"sub": "user-1",
+			"iss": "https://issuer.example.com",
+			"aud": "api",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for key, value := range overrides {
+			if value == nil {
+				delete(claims, key)
+				continue
+			}
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		wantErr string
+		check   func(t *testing.T, claims *Claims)
+	}{
+		{name: "valid"},
+		{name: "missing exp", claims: map[string]any{"exp": nil}, wantErr: "exp"},
+		{name: "expired", claims: map[string]any{"exp": now.Add(-time.Minute).Unix()}, wantErr: errTokenExpired.Error()},
+		{name: "expired within leeway", claims: map[string]any{"exp": now.Add(-10 * time.Second).Unix()}},
+		{name: "fractional exp", claims: map[string]any{"exp": json.Number("4102444800.25")}, check: func(t *testing.T, claims *Claims) {
+			if want := time.Unix(4102444800, 250*int64(time.Millisecond)); !claims.ExpiresAt.Equal(want) {
+				t.Errorf("exp %v, want %v", claims.ExpiresAt, want)
+			}
+		}},
+		{name: "exp after year 9999", claims: map[string]any{"exp": json.Number("253402300800")}, wantErr: "no valid exp"},
+		{name: "exp beyond int64", claims: map[string]any{"exp": json.Number("1e19")}, wantErr: "no valid exp"},
+		{name: "exp beyond float64", claims: map[string]any{"exp": json.Number("1e400")}, wantErr: "no valid exp"},
+		{name: "nbf out of range", claims: map[string]any{"nbf": json.Number("-1e19")}, wantErr: "nbf"},
+		{name: "iat out of range", claims: map[string]any{"iat": json.Number("1e300")}, wantErr: "iat"},
+		{name: "not valid yet", claims: map[string]any{"nbf": now.Add(time.Minute).Unix()}, wantErr: "not valid yet"},
+		{name: "nbf within leeway", claims: map[string]any{"nbf": now.Add(10 * time.Second).Unix()}},
+		{name: "wrong issuer", claims: map[string]any{"iss": "https://evil.example.com"}, wantErr: "issuer"},
+		{name: "missing issuer", claims: map[string]any{"iss": nil}, wantErr: "issuer"},
+		{name: "audience array", claims: map[string]any{"aud": []string{"other", "admin"}}, check: func(t *testing.T, claims *Claims) {
+			if len(claims.Audience) != 2 {
+				t.Errorf("audience %v", claims.Audience)
+			}
+		}},
+		{name: "wrong audience", claims: map[string]any{"aud": "other"}, wantErr: "audience"},
+		{name: "wrong audience array", claims: map[string]any{"aud": []string{"other"}}, wantErr: "audience"},
+		{name: "missing audience", claims: map[string]any{"aud": nil}, wantErr: "audience"},
+		{name: "scope string and roles", claims: map[string]any{"scope": "read write", "roles": []string{"admin"}}, check: func(t *testing.T, claims *Claims) {
+			if !claims.HasScope("write") || !claims.HasRole("admin") {
+				t.Errorf("scopes %v roles %v", claims.Scopes, claims.Roles)
+			}
+		}},
+		{name: "scp array", claims: map[string]any{"scp": []string{"read"}}, check: func(t *testing.T, claims *Claims) {
+			if !claims.HasScope("read") {
+				t.Errorf("scopes %v", claims.Scopes)
+			}
+		}},
+	}
+	verifier, err := NewJWTVerifier(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), signHS256(testJWTSecret, base(tt.claims)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.check != nil {
+				tt.check(t, claims)
+			}
+		})
+	}
+}
+
+func TestParseJWKSRejectsWeakRSA(t *testing.T) {
+	keys := testRSAKeys()
+
+	if _, err := parseJWKS(jwksJSON(rsaJWK("weak", keys[2]))); err == nil {
+		t.Fatal("1024-bit key was accepted")
+	}
+	parsed, err := parseJWKS(jwksJSON(rsaJWK("weak", keys[2]), rsaJWK("strong", keys[0])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 1 || parsed[0].kid != "strong" {
+		t.Fatalf("parsed %d keys, want only the 2048-bit one", len(parsed))
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	keys := testRSAKeys()
+	var (
+		mu      sync.Mutex
+		current = jwksJSON(rsaJWK("k1", keys[0]))
+		fetches atomic.Int32
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(current)
+	}))
+	defer server.Close()
+
+	verifier, err := NewJWTVerifier(JWTConfig{JWKSURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(key *rsa.PrivateKey, kid string) error {
+		_, err := verifier.Verify(context.Background(), signRS256(key, kid, validClaims()))
+		return err
+	}
+
+	if err := verify(keys[0], "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(keys[0], "k1"); err != nil || fetches.Load() != 1 {
+		t.Fatalf("second verify: err %v, %d fetches, want the cached keys", err, fetches.Load())
+	}
+
+	mu.Lock()
+	current = jwksJSON(rsaJWK("k2", keys[1]))
+	mu.Unlock()
+
+	// Within a minute of the last fetch an unknown kid does not refetch.
+	if err := verify(keys[1], "k2"); err == nil || fetches.Load() != 1 {
+		t.Fatalf("unknown kid: err %v, %d fetches, want a throttled failure", err, fetches.Load())
+	}
+
+	verifier.keys.mu.Lock()
+	verifier.keys.fetchedAt = time.Now().Add(-jwksMinRefetch - time.Second)
+	verifier.keys.mu.Unlock()
+	if err := verify(keys[1], "k2"); err != nil || fetches.Load() != 2 {
+		t.Fatalf("rotated kid: err %v, %d fetches, want a refetch", err, fetches.Load())
+	}
+	if err := verify(keys[0], "k1"); err == nil || fetches.Load() != 2 {
+		t.Fatalf("retired kid: err %v, %d fetches, want a throttled failure", err, fetches.Load())
+	}
+}
+
+func TestJWKSFetchFailureKeepsKeys(t *testing.T) {
+	keys := testRSAKeys()
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Write(jwksJSON(rsaJWK("k1", keys[0])))
+	}))
+	defer server.Close()
+
+	verifier, err := NewJWTVerifier(JWTConfig{JWKSURL: server.URL, JWKSRefresh: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signRS256(keys[0], "k1", validClaims())
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+
+	failing.Store(true)
+	verifier.keys.mu.Lock()
+	verifier.keys.fetchedAt = time.Now().Add(-2 * time.Minute)
+	verifier.keys.mu.Unlock()
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Fatalf("verify during outage: %v", err)
+	}
+}
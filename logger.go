@@ -11,7 +11,10 @@ import (
 	"github.com/dejaniskra/go-gi/internal/config"
 )
 
-var defaultLogger *Logger
+var (
+	defaultLogger     *Logger
+	defaultLoggerOnce sync.Once
+)
 
 type Logger struct {
 	mu     sync.Mutex
@@ -21,10 +24,10 @@ type Logger struct {
 }
 
 func GetLogger() *Logger {
-	if defaultLogger == nil {
+	defaultLoggerOnce.Do(func() {
 		config := config.GetConfig()
 		defaultLogger = newLogger(config.Log.Level, config.Log.Format)
-	}
+	})
 	return defaultLogger
 }
 
@@ -0,0 +1,456 @@
+package gogi
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	metricsDefaultPath    = "/metrics"
+	metricsUnmatchedRoute = "<unmatched>"
+	metricsContentType    = "text/plain; version=0.0.4; charset=utf-8"
+	metricsLabelDivider   = "\xff"
+)
+
+// DefaultBuckets are the histogram buckets, in seconds, used when
+// NewHistogram is given none.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// MetricsConfig configures EnableMetrics. Path defaults to /metrics.
+type MetricsConfig struct {
+	Path string
+}
+
+// EnableMetrics serves every metric in the Prometheus text format and starts
+// recording request count and latency for each route, labelled with the
+// route pattern rather than the raw path. Requests no route matches, such
+// as 404s, 405s and automatic OPTIONS answers, share route="<unmatched>".
+// Client, job, queue and pub/sub metrics are recorded whether or not the
+// endpoint is enabled.
+func (application *Application) EnableMetrics(config MetricsConfig) {
+	httpServer := getServer()
+	if application.httpServer == nil {
+		application.httpServer = httpServer
+	} // TODO: revisit this
+
+	if config.Path == "" {
+		config.Path = metricsDefaultPath
+	}
+	httpServer.metricsEnabled = true
+	httpServer.addRoute(nil, HTTP_GET, config.Path, httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		var buf bytes.Buffer
+		if err := WriteMetrics(&buf); err != nil {
+			WriteProblem(req, res, err)
+			return
+		}
+		res.Headers["Content-Type"] = metricsContentType
+		res.Body = &buf
+	}), WithOpenAPI(OpenAPIOperation{Hidden: true}))
+}
+
+// WriteMetrics writes every registered metric in the Prometheus text format.
+func WriteMetrics(w io.Writer) error {
+	return defaultMetrics.write(w)
+}
+
+// Counter is a value that only goes up, with one series per combination of
+// label values.
+type Counter struct{ family *metricFamily }
+
+// Gauge is a value that can go up and down.
+type Gauge struct{ family *metricFamily }
+
+// Histogram counts observations, typically durations in seconds, into
+// buckets.
+type Histogram struct{ family *metricFamily }
+
+// NewCounter registers a counter. Label values are passed to Inc and Add in
+// the order of labels. Registering a name twice panics.
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{family: defaultMetrics.register(name, help, "counter", labels, nil)}
+}
+
+// NewGauge registers a gauge.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{family: defaultMetrics.register(name, help, "gauge", labels, nil)}
+}
+
+// NewHistogram registers a histogram with the given upper bounds, or
+// DefaultBuckets when buckets is nil.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{family: defaultMetrics.register(name, help, "histogram", labels, buckets)}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter; negative values are ignored.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	addFloat(&c.family.with(labelValues).value, value)
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.family.with(labelValues).value.Store(math.Float64bits(value))
+}
+
+func (g *Gauge) Add(value float64, labelValues ...string) {
+	addFloat(&g.family.with(labelValues).value, value)
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	series := h.family.with(labelValues)
+	i := sort.SearchFloat64s(h.family.buckets, value)
+	if i < len(series.buckets) {
+		series.buckets[i].Add(1)
+	}
+	addFloat(&series.value, value)
+	series.count.Add(1)
+}
+
+// ObserveSince records the seconds elapsed since start.
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func addFloat(bits *atomic.Uint64, delta float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+type metricsRegistry struct {
+	mu       sync.Mutex
+	families []*metricFamily
+}
+
+var defaultMetrics = &metricsRegistry{}
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.RWMutex
+	series map[string]*metricSeries
+}
+
+// metricSeries holds a counter or gauge value, or a histogram sum, as float
+// bits. Histogram buckets are not cumulative; they are summed when written.
+type metricSeries struct {
+	labelValues []string
+	value       atomic.Uint64
+	count       atomic.Uint64
+	buckets     []atomic.Uint64
+}
+
+func (registry *metricsRegistry) register(name, help, kind string, labels []string, buckets []float64) *metricFamily {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	for _, family := range registry.families {
+		if family.name == name {
+			panic(fmt.Sprintf("metric %s is already registered", name))
+		}
+	}
+	family := &metricFamily{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*metricSeries),
+	}
+	registry.families = append(registry.families, family)
+	return family
+}
+
+func (family *metricFamily) with(labelValues []string) *metricSeries {
+	if len(labelValues) != len(family.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", family.name, len(family.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, metricsLabelDivider)
+
+	family.mu.RLock()
+	series, ok := family.series[key]
+	family.mu.RUnlock()
+	if ok {
+		return series
+	}
+
+	family.mu.Lock()
+	defer family.mu.Unlock()
+	if series, ok := family.series[key]; ok {
+		return series
+	}
+	series = &metricSeries{
+		labelValues: append([]string(nil), labelValues...),
+		buckets:     make([]atomic.Uint64, len(family.buckets)),
+	}
+	family.series[key] = series
+	return series
+}
+
+func (registry *metricsRegistry) write(w io.Writer) error {
+	registry.mu.Lock()
+	families := append([]*metricFamily(nil), registry.families...)
+	registry.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		family.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (family *metricFamily) write(w *bufio.Writer) {
+	family.mu.RLock()
+	keys := make([]string, 0, len(family.series))
+	for key := range family.series {
+		keys = append(keys, key)
+	}
+	family.mu.RUnlock()
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "# HELP %s %s\n", family.name, escapeMetricHelp(family.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", family.name, family.kind)
+
+	for _, key := range keys {
+		family.mu.RLock()
+		series := family.series[key]
+		family.mu.RUnlock()
+
+		if family.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", family.name, family.labelString(series.labelValues, ""), formatMetricValue(math.Float64frombits(series.value.Load())))
+			continue
+		}
+
+		// Read the count first so buckets observed meanwhile cannot push
+		// a bucket above it.
+		count := series.count.Load()
+		var cumulative uint64
+		for i, bound := range family.buckets {
+			cumulative += series.buckets[i].Load()
+			fmt.Fprintf(w, "%s_bucket%s %d\n", family.name, family.labelString(series.labelValues, formatMetricValue(bound)), min(cumulative, count))
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", family.name, family.labelString(series.labelValues, "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", family.name, family.labelString(series.labelValues, ""), formatMetricValue(math.Float64frombits(series.value.Load())))
+		fmt.Fprintf(w, "%s_count%s %d\n", family.name, family.labelString(series.labelValues, ""), count)
+	}
+}
+
+func (family *metricFamily) labelString(values []string, le string) string {
+	if len(values) == 0 && le == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range family.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeMetricLabel(values[i]))
+		b.WriteByte('"')
+	}
+	if le != "" {
+		if len(values) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(`le="`)
+		b.WriteString(le)
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	metricHelpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	metricLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeMetricHelp(s string) string {
+	return metricHelpEscaper.Replace(s)
+}
+
+func escapeMetricLabel(s string) string {
+	return metricLabelEscaper.Replace(s)
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Built-in metrics.
+var (
+	httpRequestsTotal   = NewCounter("gogi_http_requests_total", "HTTP requests by route pattern and status.", "method", "route", "status")
+	httpRequestDuration = NewHistogram("gogi_http_request_duration_seconds", "HTTP request latency by route pattern.", nil, "method", "route")
+	httpRequestsActive  = NewGauge("gogi_http_requests_in_flight", "HTTP requests currently being served.")
+
+	dbCallDuration = NewHistogram("gogi_db_call_duration_seconds", "Database call latency by client, role and operation.", nil, "client", "role", "operation")
+	dbCallErrors   = NewCounter("gogi_db_call_errors_total", "Failed database calls by client, role and operation.", "client", "role", "operation")
+
+	redisCallDuration = NewHistogram("gogi_redis_call_duration_seconds", "Redis command latency by role and command.", nil, "role", "command")
+	redisCallErrors   = NewCounter("gogi_redis_call_errors_total", "Failed Redis commands by role and command.", "role", "command")
+
+	jobRunsTotal     = NewCounter("gogi_job_runs_total", "Scheduled job runs.", "job")
+	jobFailuresTotal = NewCounter("gogi_job_failures_total", "Scheduled job runs that panicked.", "job")
+	jobRunDuration   = NewHistogram("gogi_job_run_duration_seconds", "Scheduled job run time.", nil, "job")
+
+	queueSentTotal     = NewCounter("gogi_queue_jobs_sent_total", "Jobs sent to a queue.", "backend", "queue")
+	queueReceivedTotal = NewCounter("gogi_queue_jobs_received_total", "Jobs received from a queue.", "backend", "queue")
+	queueErrorsTotal   = NewCounter("gogi_queue_errors_total", "Queue send, receive and handler errors.", "backend", "queue", "operation")
+
+	pubsubPublishedTotal = NewCounter("gogi_pubsub_events_published_total", "Events published.", "backend", "topic")
+	pubsubReceivedTotal  = NewCounter("gogi_pubsub_events_received_total", "Events delivered to a subscriber.", "backend", "topic")
+	pubsubErrorsTotal    = NewCounter("gogi_pubsub_errors_total", "Publish, receive and handler errors.", "backend", "topic", "operation")
+)
+
+func isNoRows(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
+
+// observeQueueSend is deferred by SendJob with a pointer to its named error
+// result.
+func observeQueueSend(backend, queue string, err *error) {
+	if *err != nil {
+		queueErrorsTotal.Inc(backend, queue, "send")
+		return
+	}
+	queueSentTotal.Inc(backend, queue)
+}
+
+// observeQueueError counts a failed receive or handler call; nil is ignored.
+func observeQueueError(backend, queue, operation string, err error) {
+	if err != nil {
+		queueErrorsTotal.Inc(backend, queue, operation)
+	}
+}
+
+func observePublish(backend, topic string, err *error) {
+	if *err != nil {
+		pubsubErrorsTotal.Inc(backend, topic, "publish")
+		return
+	}
+	pubsubPublishedTotal.Inc(backend, topic)
+}
+
+func observePubSubError(backend, topic, operation string, err error) {
+	if err != nil {
+		pubsubErrorsTotal.Inc(backend, topic, operation)
+	}
+}
+
+// statusRecorder captures the status code for request metrics and spans.
+// Unwrap keeps http.ResponseController working for flushing and hijacking.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if recorder.status == 0 && (status < 100 || status > 199 || status == http.StatusSwitchingProtocols) {
+		recorder.status = status
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Write(p []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	return recorder.ResponseWriter.Write(p)
+}
+
+func (recorder *statusRecorder) Flush() {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	http.NewResponseController(recorder.ResponseWriter).Flush()
+}
+
+func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+// result reports the status sent to the client. A handler that panicked
+// counts as a 500 and a hijacked WebSocket as a 101.
+func (recorder *statusRecorder) result(completed, webSocket bool) int {
+	switch {
+	case !completed:
+		return http.StatusInternalServerError
+	case recorder.status == 0 && webSocket:
+		return http.StatusSwitchingProtocols
+	case recorder.status == 0:
+		return http.StatusOK
+	}
+	return recorder.status
+}
+
+// serveInstrumented records a request against the route pattern.
+func (route *route) serveInstrumented(w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+	observeRequest(w, r, route.Method, route.Path, isWebSocketRoute(route), serve)
+}
+
+func observeRequest(w http.ResponseWriter, r *http.Request, method, pattern string, webSocket bool, serve func(http.ResponseWriter, *http.Request)) {
+	recorder := &statusRecorder{ResponseWriter: w}
+	start := time.Now()
+	httpRequestsActive.Add(1)
+
+	completed := false
+	defer func() {
+		httpRequestsActive.Add(-1)
+		status := recorder.result(completed, webSocket)
+		httpRequestsTotal.Inc(method, pattern, strconv.Itoa(status))
+		httpRequestDuration.ObserveSince(start, method, pattern)
+	}()
+
+	serve(recorder, r)
+	completed = true
+}
+
+// metricsMethod bounds the method label of unmatched requests, which may
+// carry any token a client sends.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
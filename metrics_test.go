@@ -0,0 +1,199 @@
+package gogi
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestRegistry(t *testing.T, registry *metricsRegistry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := registry.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestMetricsWriteCounterAndGauge(t *testing.T) {
+	registry := &metricsRegistry{}
+	counter := &Counter{family: registry.register("test_requests_total", "Requests.\nBy path \\ method.", "counter", []string{"method", "path"}, nil)}
+	gauge := &Gauge{family: registry.register("test_in_flight", "In flight.", "gauge", nil, nil)}
+
+	counter.Inc("POST", "/b")
+	counter.Add(2, "GET", "/a")
+	counter.Add(-5, "GET", "/a")
+	gauge.Set(3)
+	gauge.Add(-0.5)
+
+	want := `# HELP test_requests_total Requests.\nBy path \\ method.
+# TYPE test_requests_total counter
+test_requests_total{method="GET",path="/a"} 2
+test_requests_total{method="POST",path="/b"} 1
+# HELP test_in_flight In flight.
+# TYPE test_in_flight gauge
+test_in_flight 2.5
+`
+	if got := writeTestRegistry(t, registry); got != want {
+		t.Fatalf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	registry := &metricsRegistry{}
+	counter := &Counter{family: registry.register("test_escaped_total", "Escaped.", "counter", []string{"value"}, nil)}
+	counter.Inc("a\\b\n\"c\"")
+
+	want := `test_escaped_total{value="a\\b\n\"c\""} 1`
+	if got := writeTestRegistry(t, registry); !strings.Contains(got, want+"\n") {
+		t.Fatalf("output:\n%s\nwant line %s", got, want)
+	}
+}
+
+func TestMetricsHistogramBuckets(t *testing.T) {
+	registry := &metricsRegistry{}
+	histogram := &Histogram{family: registry.register("test_duration_seconds", "Duration.", "histogram", []string{"job"}, []float64{0.1, 0.5, 1})}
+
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		histogram.Observe(v, "sync")
+	}
+
+	want := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{job="sync",le="0.1"} 2
+test_duration_seconds_bucket{job="sync",le="0.5"} 3
+test_duration_seconds_bucket{job="sync",le="1"} 4
+test_duration_seconds_bucket{job="sync",le="+Inf"} 5
+test_duration_seconds_sum{job="sync"} 3.15
+test_duration_seconds_count{job="sync"} 5
+`
+	if got := writeTestRegistry(t, registry); got != want {
+		t.Fatalf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMetricsRegistrationErrors(t *testing.T) {
+	registry := &metricsRegistry{}
+	family := registry.register("test_total", "Test.", "counter", []string{"a"}, nil)
+
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{name: "duplicate name", fn: func() { registry.register("test_total", "Again.", "counter", nil, nil) }},
+		{name: "label count", fn: func() { family.with([]string{"x", "y"}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			tt.fn()
+		})
+	}
+}
+
+func TestFormatMetricValue(t *testing.T) {
+	tests := map[float64]string{0.25: "0.25", 3: "3", 1e-9: "1e-09"}
+	for v, want := range tests {
+		if got := formatMetricValue(v); got != want {
+			t.Errorf("formatMetricValue(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestStatusRecorderResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		completed bool
+		webSocket bool
+		want      int
+	}{
+		{name: "panicked", status: http.StatusOK, want: http.StatusInternalServerError},
+		{name: "nothing written", completed: true, want: http.StatusOK},
+		{name: "hijacked websocket", completed: true, webSocket: true, want: http.StatusSwitchingProtocols},
+		{name: "rejected websocket", status: http.StatusBadRequest, completed: true, webSocket: true, want: http.StatusBadRequest},
+		{name: "written status", status: http.StatusCreated, completed: true, want: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &statusRecorder{status: tt.status}
+			if got := recorder.result(tt.completed, tt.webSocket); got != tt.want {
+				t.Fatalf("result %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsWebSocketRouteRecords101(t *testing.T) {
+	server := &HttpServer{router: newRouter(), metricsEnabled: true}
+	server.addWebSocket(nil, "/test-metrics-ws", func(req *HTTPServerRequest, conn *WebSocketConn) {})
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	dialTestWebSocket(t, ts, "/test-metrics-ws")
+
+	want := `gogi_http_requests_total{method="GET",route="/test-metrics-ws",status="101"} 1`
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var buf bytes.Buffer
+		WriteMetrics(&buf)
+		if strings.Contains(buf.String(), want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("metrics lack %s:\n%s", want, buf.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func counterValue(c *Counter, labelValues ...string) float64 {
+	return math.Float64frombits(c.family.with(labelValues).value.Load())
+}
+
+func TestMetricsRecordUnmatchedRequests(t *testing.T) {
+	server := &HttpServer{router: newRouter(), metricsEnabled: true}
+	server.addRoute(nil, HTTP_GET, "/test-unmatched/items", httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {}))
+	handler := server.handler()
+
+	tests := []struct {
+		method      string
+		path        string
+		labelMethod string
+		status      string
+	}{
+		{method: http.MethodGet, path: "/test-unmatched/missing", labelMethod: "GET", status: "404"},
+		{method: http.MethodDelete, path: "/test-unmatched/items", labelMethod: "DELETE", status: "405"},
+		{method: http.MethodOptions, path: "/test-unmatched/items", labelMethod: "OPTIONS", status: "204"},
+		{method: "PROPFIND", path: "/test-unmatched/items", labelMethod: "OTHER", status: "405"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			before := counterValue(httpRequestsTotal, tt.labelMethod, metricsUnmatchedRoute, tt.status)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if got := strconv.Itoa(w.Code); got != tt.status {
+				t.Fatalf("status %s, want %s", got, tt.status)
+			}
+			if got := counterValue(httpRequestsTotal, tt.labelMethod, metricsUnmatchedRoute, tt.status) - before; got != 1 {
+				t.Fatalf("recorded %v requests under route=%q, want 1", got, metricsUnmatchedRoute)
+			}
+			var buf bytes.Buffer
+			if err := WriteMetrics(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(buf.String(), `route="`+tt.path+`"`) {
+				t.Fatalf("recorded the raw path %s as a route", tt.path)
+			}
+		})
+	}
+}
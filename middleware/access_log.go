@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// AccessLogEntry describes a finished request.
+type AccessLogEntry struct {
+	Time      time.Time
+	Method    string
+	Path      string
+	Status    int
+	Bytes     int64
+	Duration  time.Duration
+	ClientIP  string
+	UserAgent string
+	RequestID string
+}
+
+// AccessLogConfig configures AccessLog. Logger defaults to the standard log
+// package and Format to a single line per request. Requests for which Skip
+// returns true, such as health probes, are not logged.
+type AccessLogConfig struct {
+	Logger Logger
+	Format func(AccessLogEntry) string
+	Skip   func(*http.Request) bool
+}
+
+// AccessLog logs every request once it has been served. Place it inside
+// RequestID and RealIP so the entry carries their results.
+func AccessLog(config AccessLogConfig) func(http.Handler) http.Handler {
+	logger := loggerOrDefault(config.Logger)
+	if config.Format == nil {
+		config.Format = formatAccessLog
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Skip != nil && config.Skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			logger.Info(config.Format(AccessLogEntry{
+				Time:      start,
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Status:    status,
+				Bytes:     rec.bytes,
+				Duration:  time.Since(start),
+				ClientIP:  ClientIP(r),
+				UserAgent: r.UserAgent(),
+				RequestID: GetRequestID(r.Context()),
+			}))
+		})
+	}
+}
+
+func formatAccessLog(entry AccessLogEntry) string {
+	line := fmt.Sprintf("[HTTP] %s %s %d %dB %s ip=%s ua=%q",
+		entry.Method, entry.Path, entry.Status, entry.Bytes, entry.Duration.Round(time.Microsecond), entry.ClientIP, entry.UserAgent)
+	if entry.RequestID != "" {
+		line += " request_id=" + entry.RequestID
+	}
+	return line
+}
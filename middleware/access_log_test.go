@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogEntry(t *testing.T) {
+	var entries []AccessLogEntry
+	handler := Chain(
+		RequestID(RequestIDConfig{Generate: func() string { return "req-1" }}),
+		AccessLog(AccessLogConfig{Logger: &captureLogger{}, Format: func(entry AccessLogEntry) string {
+			entries = append(entries, entry)
+			return ""
+		}}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/items?page=2", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("User-Agent", "test-agent")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	entry := entries[0]
+	if entry.Method != http.MethodPost || entry.Path != "/items?page=2" || entry.Status != http.StatusCreated ||
+		entry.Bytes != 5 || entry.ClientIP != "192.0.2.1" || entry.UserAgent != "test-agent" || entry.RequestID != "req-1" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if entry.Time.IsZero() || entry.Duration < 0 {
+		t.Fatalf("unexpected timing %v %v", entry.Time, entry.Duration)
+	}
+}
+
+func TestAccessLogDefaultFormatAndSkip(t *testing.T) {
+	logger := &captureLogger{}
+	handler := AccessLog(AccessLogConfig{
+		Logger: logger,
+		Skip:   func(r *http.Request) bool { return r.URL.Path == "/healthz" },
+	})(okHandler)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	lines := logger.lines()
+	if len(lines) != 1 {
+		t.Fatalf("logged %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "[HTTP] GET /items 200 2B ") || !strings.Contains(lines[0], "ip=192.0.2.1") {
+		t.Fatalf("unexpected line %q", lines[0])
+	}
+}
+
+func TestFormatAccessLog(t *testing.T) {
+	line := formatAccessLog(AccessLogEntry{
+		Method: "GET", Path: "/", Status: 404, Bytes: 9, Duration: 1500 * time.Microsecond,
+		ClientIP: "10.0.0.1", UserAgent: `a "quoted" agent`, RequestID: "r1",
+	})
+	want := `[HTTP] GET / 404 9B 1.5ms ip=10.0.0.1 ua="a \"quoted\" agent" request_id=r1`
+	if line != want {
+		t.Fatalf("got  %s\nwant %s", line, want)
+	}
+}
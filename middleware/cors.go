@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures CORS. AllowedOrigins entries are exact origins,
+// "*" for any origin, or a wildcard such as "https://*.example.com" that
+// matches subdomains only. AllowedOriginPatterns are regular expressions
+// that must match the whole origin. AllowOriginFunc, when set, is consulted
+// for origins nothing else matches. AllowedMethods defaults to the simple
+// methods plus PUT, PATCH and DELETE; AllowedHeaders defaults to echoing
+// whatever the preflight asks for. With AllowCredentials the matching
+// origin is echoed instead of "*", as browsers require.
+type CORSConfig struct {
+	AllowedOrigins        []string
+	AllowedOriginPatterns []string
+	AllowOriginFunc       func(origin string) bool
+	AllowedMethods        []string
+	AllowedHeaders        []string
+	ExposedHeaders        []string
+	AllowCredentials      bool
+	MaxAge                time.Duration
+}
+
+// CORS answers preflight requests itself and adds the CORS response headers
+// to actual requests from allowed origins. Requests from other origins pass
+// through without CORS headers, so the browser blocks them.
+func CORS(config CORSConfig) func(http.Handler) http.Handler {
+	policy := NewCORSPolicy(config)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if policy.Handle(w, r) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CORSPolicy applies a CORSConfig. It is exported for servers that must
+// answer preflights before routing rather than as middleware.
+type CORSPolicy struct {
+	config         CORSConfig
+	anyOrigin      bool
+	origins        map[string]bool
+	wildcards      [][2]string
+	patterns       []*regexp.Regexp
+	allowedMethods string
+	allowedHeaders map[string]bool
+	headerList     string
+	exposedHeaders string
+	maxAge         string
+}
+
+// NewCORSPolicy returns the policy CORS applies for config. It panics if an
+// AllowedOriginPatterns entry is not a valid regular expression.
+func NewCORSPolicy(config CORSConfig) *CORSPolicy {
+	policy := &CORSPolicy{config: config, origins: make(map[string]bool)}
+	for _, pattern := range config.AllowedOriginPatterns {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			panic(fmt.Sprintf("middleware: invalid origin pattern %q: %v", pattern, err))
+		}
+		policy.patterns = append(policy.patterns, re)
+	}
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			policy.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "*")
+			policy.wildcards = append(policy.wildcards, [2]string{scheme, host})
+		case origin != "":
+			policy.origins[origin] = true
+		}
+	}
+
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	}
+	policy.allowedMethods = strings.ToUpper(strings.Join(methods, ", "))
+
+	if len(config.AllowedHeaders) > 0 {
+		policy.allowedHeaders = make(map[string]bool)
+		for _, header := range config.AllowedHeaders {
+			policy.allowedHeaders[http.CanonicalHeaderKey(strings.TrimSpace(header))] = true
+		}
+		policy.headerList = strings.Join(config.AllowedHeaders, ", ")
+	}
+	policy.exposedHeaders = strings.Join(config.ExposedHeaders, ", ")
+	if config.MaxAge > 0 {
+		policy.maxAge = strconv.Itoa(int(config.MaxAge.Seconds()))
+	}
+	return policy
+}
+
+// Handle adds the CORS headers for r and reports whether it answered a
+// preflight request, in which case nothing else should be written.
+func (policy *CORSPolicy) Handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	header := w.Header()
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	addVary(header, "Origin")
+	if preflight {
+		addVary(header, "Access-Control-Request-Method")
+		addVary(header, "Access-Control-Request-Headers")
+	}
+	if origin == "" {
+		return false
+	}
+	if !policy.allowOrigin(origin) {
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return true
+		}
+		return false
+	}
+
+	if policy.anyOrigin && !policy.config.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if policy.config.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if policy.exposedHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", policy.exposedHeaders)
+		}
+		return false
+	}
+
+	if !policy.allowMethod(r.Header.Get("Access-Control-Request-Method")) {
+		header.Del("Access-Control-Allow-Origin")
+		header.Del("Access-Control-Allow-Credentials")
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	header.Set("Access-Control-Allow-Methods", policy.allowedMethods)
+
+	if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		if policy.allowedHeaders == nil {
+			header.Set("Access-Control-Allow-Headers", requested)
+		} else if policy.allowHeaders(requested) {
+			header.Set("Access-Control-Allow-Headers", policy.headerList)
+		} else {
+			header.Del("Access-Control-Allow-Origin")
+			header.Del("Access-Control-Allow-Credentials")
+			header.Del("Access-Control-Allow-Methods")
+		}
+	}
+	if policy.maxAge != "" {
+		header.Set("Access-Control-Max-Age", policy.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func (policy *CORSPolicy) allowOrigin(origin string) bool {
+	lower := strings.ToLower(origin)
+	if policy.anyOrigin || policy.origins[lower] {
+		return true
+	}
+	for _, wildcard := range policy.wildcards {
+		scheme, suffix := wildcard[0], wildcard[1]
+		if strings.HasPrefix(lower, scheme) && strings.HasSuffix(lower, suffix) && len(lower) > len(scheme)+len(suffix) {
+			return true
+		}
+	}
+	for _, pattern := range policy.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return policy.config.AllowOriginFunc != nil && policy.config.AllowOriginFunc(origin)
+}
+
+func (policy *CORSPolicy) allowMethod(method string) bool {
+	method = strings.ToUpper(method)
+	for _, allowed := range strings.Split(policy.allowedMethods, ", ") {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (policy *CORSPolicy) allowHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+		if header != "" && !policy.allowedHeaders[header] {
+			return false
+		}
+	}
+	return true
+}
+
+func addVary(header http.Header, value string) {
+	for _, existing := range header.Values("Vary") {
+		for _, field := range strings.Split(existing, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
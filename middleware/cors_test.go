@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func corsRequest(method, origin string, headers map[string]string) *http.Request {
+	r := httptest.NewRequest(method, "/items", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	reached := false
+	handler := CORS(CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         10 * time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+
+	tests := []struct {
+		name         string
+		origin       string
+		method       string
+		headers      string
+		allowOrigin  string
+		allowHeaders string
+	}{
+		{name: "allowed", origin: "https://app.example.com", method: "PUT", headers: "content-type", allowOrigin: "https://app.example.com", allowHeaders: "Content-Type, Authorization"},
+		{name: "unknown origin", origin: "https://evil.example.org", method: "PUT"},
+		{name: "method not allowed", origin: "https://app.example.com", method: "TRACE"},
+		{name: "header not allowed", origin: "https://app.example.com", method: "GET", headers: "X-Secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"Access-Control-Request-Method": tt.method}
+			if tt.headers != "" {
+				headers["Access-Control-Request-Headers"] = tt.headers
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, corsRequest(http.MethodOptions, tt.origin, headers))
+
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("status %d, want 204", w.Code)
+			}
+			if reached {
+				t.Fatal("preflight reached the handler")
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Fatalf("allow origin %q, want %q", got, tt.allowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Headers"); got != tt.allowHeaders {
+				t.Fatalf("allow headers %q, want %q", got, tt.allowHeaders)
+			}
+			if tt.allowOrigin != "" && w.Header().Get("Access-Control-Max-Age") != "600" {
+				t.Fatalf("max age %q", w.Header().Get("Access-Control-Max-Age"))
+			}
+			if vary := w.Header().Values("Vary"); len(vary) != 3 {
+				t.Fatalf("vary %q", vary)
+			}
+		})
+	}
+}
+
+func TestCORSOriginMatching(t *testing.T) {
+	policy := NewCORSPolicy(CORSConfig{
+		AllowedOrigins:        []string{"https://*.example.com", "https://exact.test"},
+		AllowedOriginPatterns: []string{`https://pr-\d+\.preview\.dev`},
+		AllowOriginFunc:       func(origin string) bool { return origin == "https://func.test" },
+	})
+	tests := map[string]bool{
+		"https://a.example.com":           true,
+		"https://a.b.example.com":         true,
+		"https://A.Example.com":           true,
+		"https://example.com":             false,
+		"http://a.example.com":            false,
+		"https://a.example.com.evil.test": false,
+		"https://exact.test":              true,
+		"https://pr-42.preview.dev":       true,
+		"https://pr-42.preview.dev.evil":  false,
+		"https://func.test":               true,
+		"https://other.test":              false,
+	}
+	for origin, want := range tests {
+		if got := policy.allowOrigin(origin); got != want {
+			t.Errorf("%s: got %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      CORSConfig
+		allowOrigin string
+		credentials string
+	}{
+		{name: "any origin", config: CORSConfig{AllowedOrigins: []string{"*"}}, allowOrigin: "*"},
+		{name: "any origin with credentials", config: CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, allowOrigin: "https://app.test", credentials: "true"},
+		{name: "exact with credentials", config: CORSConfig{AllowedOrigins: []string{"https://app.test"}, AllowCredentials: true}, allowOrigin: "https://app.test", credentials: "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.ExposedHeaders = []string{"X-Total"}
+			w := httptest.NewRecorder()
+			CORS(tt.config)(okHandler).ServeHTTP(w, corsRequest(http.MethodGet, "https://app.test", nil))
+
+			if w.Code != http.StatusOK || w.Body.String() != "ok" {
+				t.Fatalf("actual request not served: %d", w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Fatalf("allow origin %q, want %q", got, tt.allowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Fatalf("credentials %q, want %q", got, tt.credentials)
+			}
+			if got := w.Header().Get("Access-Control-Expose-Headers"); got != "X-Total" {
+				t.Fatalf("expose headers %q", got)
+			}
+			if got := w.Header().Get("Vary"); got != "Origin" {
+				t.Fatalf("vary %q", got)
+			}
+		})
+	}
+}
+
+func TestCORSWithoutOrigin(t *testing.T) {
+	w := httptest.NewRecorder()
+	CORS(CORSConfig{AllowedOrigins: []string{"*"}})(okHandler).ServeHTTP(w, corsRequest(http.MethodGet, "", nil))
+
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || w.Body.String() != "ok" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSInvalidPatternPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	NewCORSPolicy(CORSConfig{AllowedOriginPatterns: []string{"("}})
+}
@@ -0,0 +1,106 @@
+// Package middleware provides net/http middleware for request IDs, panic
+// recovery, access logging, CORS, security headers, client IP resolution
+// and request timeouts. It depends only on the standard library, so it can
+// be used with gogi's AddMiddleware, route groups and WithMiddleware, or
+// with any other router.
+package middleware
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+type contextKey string
+
+// Logger receives the messages logged by the middleware. *gogi.Logger
+// satisfies it.
+type Logger interface {
+	Info(msg string)
+	Error(msg string)
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Info(msg string)  { log.Print(msg) }
+func (stdLogger) Error(msg string) { log.Print(msg) }
+
+func loggerOrDefault(logger Logger) Logger {
+	if logger == nil {
+		return stdLogger{}
+	}
+	return logger
+}
+
+// Chain composes middlewares so the first one is outermost.
+func Chain(mws ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// responseRecorder tracks what has been written through it. Unwrap keeps
+// http.ResponseController working for flushing and hijacking.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 && (status < 100 || status > 199 || status == http.StatusSwitchingProtocols) {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *responseRecorder) Flush() {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// problem mirrors gogi's RFC 9457 error body, so middleware errors look like
+// handler errors to clients.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	data, _ := json.Marshal(problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: GetRequestID(r.Context()),
+	})
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(status)
+	w.Write(data)
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+)
+
+// captureLogger records what the middleware logs.
+type captureLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *captureLogger) Info(msg string)  { l.add(msg) }
+func (l *captureLogger) Error(msg string) { l.add(msg) }
+
+func (l *captureLogger) add(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, msg)
+}
+
+func (l *captureLogger) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.messages...)
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const clientIPKey contextKey = "clientIP"
+
+// RealIPConfig configures RealIP. TrustedProxies lists the addresses or
+// CIDR ranges of the proxies in front of the server; forwarding headers
+// from anyone else are ignored, since clients can set them freely. Headers
+// are consulted in order and default to X-Forwarded-For, then X-Real-IP.
+type RealIPConfig struct {
+	TrustedProxies []string
+	Headers        []string
+}
+
+// RealIP resolves the client address of requests that came through a
+// trusted proxy. X-Forwarded-For is read right to left, skipping trusted
+// proxies, so a client cannot spoof its address by prepending entries. The
+// result is available from ClientIP and replaces the host in r.RemoteAddr.
+// It panics if a TrustedProxies entry is not a valid address or range.
+func RealIP(config RealIPConfig) func(http.Handler) http.Handler {
+	trusted := make([]netip.Prefix, 0, len(config.TrustedProxies))
+	for _, proxy := range config.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			panic(fmt.Sprintf("middleware: invalid trusted proxy %q: %v", proxy, err))
+		}
+		trusted = append(trusted, prefix)
+	}
+	headers := config.Headers
+	if len(headers) == 0 {
+		headers = []string{"X-Forwarded-For", "X-Real-IP"}
+	}
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, port, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			remote, err := netip.ParseAddr(host)
+			if err != nil || !isTrusted(remote) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := forwardedIP(r.Header, headers, isTrusted)
+			if ip == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), clientIPKey, ip))
+			if port != "" {
+				r.RemoteAddr = net.JoinHostPort(ip, port)
+			} else {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP returns the address RealIP resolved for r, or the host part of
+// r.RemoteAddr.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func forwardedIP(header http.Header, names []string, isTrusted func(netip.Addr) bool) string {
+	for _, name := range names {
+		values := header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		// Repeated headers are equivalent to one comma-joined list.
+		hops := strings.Split(strings.Join(values, ","), ",")
+		var last string
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			last = addr.Unmap().String()
+			if !isTrusted(addr) {
+				return last
+			}
+		}
+		// Every valid hop was a trusted proxy; the leftmost one is the
+		// closest thing to a client address.
+		if last != "" {
+			return last
+		}
+	}
+	return ""
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		if addr := prefix.Addr(); addr.Is4In6() && prefix.Bits() >= 96 {
+			return netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96).Masked(), nil
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	handler := RealIP(RealIPConfig{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10", "2001:db8::/32"}})
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+		remote     string
+	}{
+		{name: "untrusted peer ignores headers", remoteAddr: "203.0.113.5:4000", headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}}, want: "203.0.113.5", remote: "203.0.113.5:4000"},
+		{name: "single hop", remoteAddr: "10.1.2.3:4000", headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}}, want: "198.51.100.7", remote: "198.51.100.7:4000"},
+		{name: "spoofed prefix is skipped", remoteAddr: "10.1.2.3:4000", headers: map[string][]string{"X-Forwarded-For": {"6.6.6.6, 198.51.100.7, 10.0.0.2, 192.0.2.10"}}, want: "198.51.100.7", remote: "198.51.100.7:4000"},
+		{name: "repeated headers", remoteAddr: "10.1.2.3:4000", headers: map[string][]string{"X-Forwarded-For": {"6.6.6.6", "198.51.100.7, 10.0.0.2"}}, want: "198.51.100.7", remote: "198.51.100.7:4000"},
+		{name: "all trusted uses leftmost", remoteAddr: "10.1.2.3:4000", headers: map[string][]string{"X-Forwarded-For": {"10.0.0.9, 10.0.0.2"}}, want: "10.0.0.9", remote: "10.0.0.9:4000"},
+		{name: "falls back to X-Real-IP", remoteAddr: "10.1.2.3:4000", headers: map[string][]string{"X-Real-IP": {"198.51.100.8"}}, want: "198.51.100.8", remote: "198.51.100.8:4000"},
+		{name: "invalid hop stops the walk", remoteAddr: "10.1.2.3:4000", headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7, garbage"}, "X-Real-IP": {"198.51.100.8"}}, want: "198.51.100.8", remote: "198.51.100.8:4000"},
+		{name: "ipv6 proxy", remoteAddr: "[2001:db8::1]:4000", headers: map[string][]string{"X-Forwarded-For": {"2001:db9::5"}}, want: "2001:db9::5", remote: "[2001:db9::5]:4000"},
+		{name: "mapped ipv4 peer", remoteAddr: "[::ffff:10.1.2.3]:4000", headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}}, want: "198.51.100.7", remote: "198.51.100.7:4000"},
+		{name: "no headers", remoteAddr: "10.1.2.3:4000", want: "10.1.2.3", remote: "10.1.2.3:4000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ip, remote string
+			h := handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip, remote = ClientIP(r), r.RemoteAddr
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.headers {
+				for _, value := range values {
+					r.Header.Add(name, value)
+				}
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if ip != tt.want || remote != tt.remote {
+				t.Fatalf("got %s (%s), want %s (%s)", ip, remote, tt.want, tt.remote)
+			}
+		})
+	}
+}
+
+func TestRealIPInvalidProxyPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	RealIP(RealIPConfig{TrustedProxies: []string{"not-an-ip"}})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// RecoverConfig configures Recover. Logger defaults to the standard log
+// package; set Stack to include the goroutine stack in the log.
+type RecoverConfig struct {
+	Logger Logger
+	Stack  bool
+}
+
+// Recover turns a panic into a logged 500 with a JSON problem body carrying
+// the request ID. If the handler had already started the response, the
+// connection is aborted instead, since the status can no longer change.
+// http.ErrAbortHandler is passed through untouched.
+func Recover(config RecoverConfig) func(http.Handler) http.Handler {
+	logger := loggerOrDefault(config.Logger)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &responseRecorder{ResponseWriter: w}
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				msg := fmt.Sprintf("[Recover] %s %s: panic: %v", r.Method, r.URL.Path, p)
+				if id := GetRequestID(r.Context()); id != "" {
+					msg += " request_id=" + id
+				}
+				if config.Stack {
+					msg += "\n" + string(debug.Stack())
+				}
+				logger.Error(msg)
+
+				if rec.status != 0 {
+					panic(http.ErrAbortHandler)
+				}
+				writeProblem(w, r, http.StatusInternalServerError, "internal_error", "")
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoverWritesProblem(t *testing.T) {
+	logger := &captureLogger{}
+	handler := Chain(
+		RequestID(RequestIDConfig{Generate: func() string { return "req-1" }}),
+		Recover(RecoverConfig{Logger: logger}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Fatalf("content type %q", got)
+	}
+	if got := w.Header().Get("Content-Length"); got != "" {
+		t.Fatalf("stale Content-Length %q", got)
+	}
+	var body problem
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := problem{Type: "about:blank", Title: "Internal Server Error", Status: 500, Instance: "/items", Code: "internal_error", RequestID: "req-1"}
+	if body != want {
+		t.Fatalf("got %+v, want %+v", body, want)
+	}
+
+	lines := logger.lines()
+	if len(lines) != 1 || !strings.Contains(lines[0], "panic: boom") || !strings.Contains(lines[0], "request_id=req-1") {
+		t.Fatalf("logged %q", lines)
+	}
+}
+
+func TestRecoverAbortsStartedResponse(t *testing.T) {
+	handler := Recover(RecoverConfig{Logger: &captureLogger{}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+		if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+			t.Fatalf("response was rewritten: %d %q", w.Code, w.Body.String())
+		}
+	}()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestRecoverPassesAbortHandler(t *testing.T) {
+	logger := &captureLogger{}
+	handler := Recover(RecoverConfig{Logger: logger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+		if lines := logger.lines(); len(lines) != 0 {
+			t.Fatalf("logged %q", lines)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+const (
+	requestIDKey           contextKey = "requestID"
+	requestIDDefaultHeader            = "X-Request-ID"
+	requestIDMaxLength                = 128
+)
+
+// RequestIDConfig configures RequestID. Header defaults to X-Request-ID and
+// Generate to a random UUID. An incoming ID is reused unless IgnoreIncoming
+// is set; IDs longer than 128 bytes or containing anything but printable
+// ASCII are replaced.
+type RequestIDConfig struct {
+	Header         string
+	Generate       func() string
+	IgnoreIncoming bool
+}
+
+// RequestID gives every request an ID, stored in the context for
+// GetRequestID and echoed in the response header.
+func RequestID(config RequestIDConfig) func(http.Handler) http.Handler {
+	if config.Header == "" {
+		config.Header = requestIDDefaultHeader
+	}
+	if config.Generate == nil {
+		config.Generate = newUUID
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(config.Header)
+			if config.IgnoreIncoming || !validRequestID(id) {
+				id = config.Generate()
+			}
+
+			w.Header().Set(config.Header, id)
+			ctx := context.WithValue(r.Context(), requestIDKey, id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetRequestID returns the ID RequestID assigned, or "".
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > requestIDMaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		config   RequestIDConfig
+		reused   bool
+	}{
+		{name: "generated", incoming: ""},
+		{name: "valid incoming", incoming: "abc-123", reused: true},
+		{name: "control characters", incoming: "abc\x01"},
+		{name: "spaces", incoming: "abc def"},
+		{name: "too long", incoming: strings.Repeat("a", requestIDMaxLength+1)},
+		{name: "max length", incoming: strings.Repeat("a", requestIDMaxLength), reused: true},
+		{name: "ignored", incoming: "abc-123", config: RequestIDConfig{IgnoreIncoming: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestID(tt.config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = GetRequestID(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				r.Header.Set("X-Request-ID", tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("X-Request-ID"); got != seen {
+				t.Fatalf("response header %q, context %q", got, seen)
+			}
+			if tt.reused {
+				if seen != tt.incoming {
+					t.Fatalf("got %q, want incoming %q", seen, tt.incoming)
+				}
+			} else if !uuidPattern.MatchString(seen) {
+				t.Fatalf("got %q, want a generated UUID", seen)
+			}
+		})
+	}
+}
+
+func TestRequestIDCustomHeaderAndGenerator(t *testing.T) {
+	handler := RequestID(RequestIDConfig{Header: "X-Trace", Generate: func() string { return "fixed" }})(okHandler)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := w.Header().Get("X-Trace"); got != "fixed" {
+		t.Fatalf("got %q, want fixed", got)
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeadersDefaults(t *testing.T) {
+	w := httptest.NewRecorder()
+	SecurityHeaders(SecurityHeadersConfig{})(okHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := map[string]string{
+		"Content-Security-Policy":   "default-src 'self'",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Content-Type-Options":    "nosniff",
+		"Strict-Transport-Security": "",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s: got %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestSecurityHeadersHSTSOnlyOverHTTPS(t *testing.T) {
+	handler := SecurityHeaders(SecurityHeadersConfig{HSTSMaxAge: time.Hour, HSTSIncludeSubdomains: true, HSTSPreload: true})(okHandler)
+	tests := []struct {
+		name    string
+		prepare func(*http.Request)
+		want    string
+	}{
+		{name: "plain http", prepare: func(r *http.Request) {}},
+		{name: "tls", prepare: func(r *http.Request) { r.TLS = &tls.ConnectionState{} }, want: "max-age=3600; includeSubDomains; preload"},
+		{name: "forwarded https", prepare: func(r *http.Request) { r.Header.Set("X-Forwarded-Proto", "HTTPS, http") }, want: "max-age=3600; includeSubDomains; preload"},
+		{name: "forwarded http", prepare: func(r *http.Request) { r.Header.Set("X-Forwarded-Proto", "http") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.prepare(r)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Strict-Transport-Security"); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersDisableAndOverride(t *testing.T) {
+	handler := SecurityHeaders(SecurityHeadersConfig{HSTSMaxAge: -1, FrameOptions: "-", ReferrerPolicy: "no-referrer"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Security-Policy", "default-src *")
+		}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("HSTS %q, want disabled", got)
+	}
+	if got := w.Header().Get("X-Frame-Options"); got != "" {
+		t.Errorf("frame options %q, want disabled", got)
+	}
+	if got := w.Header().Get("Referrer-Policy"); got != "no-referrer" {
+		t.Errorf("referrer policy %q", got)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != "default-src *" {
+		t.Errorf("handler override lost: %q", got)
+	}
+}
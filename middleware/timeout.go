@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutConfig configures Timeout. Requests for which Skip returns true,
+// such as streams and WebSocket upgrades, run without a deadline.
+type TimeoutConfig struct {
+	Timeout time.Duration
+	Skip    func(*http.Request) bool
+}
+
+// Timeout gives each request a context deadline. If the handler has not
+// returned when it passes, the client gets a 503 problem with code
+// "timeout" and whatever the handler writes afterwards is discarded.
+//
+// Like http.TimeoutHandler the response is buffered until the handler
+// returns, so handlers that flush, hijack or stream large bodies should be
+// skipped, or given gogi.WithTimeout instead, which streams but cannot
+// answer before a handler that ignores its context returns. The handler
+// keeps running after the deadline and should honour r.Context(). A panic
+// in the handler is re-raised on the serving goroutine, where Recover can
+// handle it.
+func Timeout(config TimeoutConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if config.Timeout <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Skip != nil && config.Skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), config.Timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{header: w.Header().Clone()}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				clear(dst)
+				for key, values := range tw.header {
+					dst[key] = values
+				}
+				if tw.status == 0 {
+					tw.status = http.StatusOK
+				}
+				w.WriteHeader(tw.status)
+				w.Write(tw.body.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				if ctx.Err() == context.DeadlineExceeded {
+					writeProblem(w, r, http.StatusServiceUnavailable, "timeout", "The request took too long to process.")
+				}
+			}
+		})
+	}
+}
+
+// timeoutWriter buffers the handler's response so it can be dropped if the
+// deadline passes first.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.status != 0 || (status >= 100 && status <= 199) {
+		return
+	}
+	tw.status = status
+}
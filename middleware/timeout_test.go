@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutPassesFastResponses(t *testing.T) {
+	handler := Timeout(TimeoutConfig{Timeout: time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("request has no deadline")
+		}
+		w.Header().Set("X-Handler", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	}))
+
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Outer", "1")
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusCreated || w.Body.String() != "done" {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Handler") != "1" || w.Header().Get("X-Outer") != "1" {
+		t.Fatalf("headers %v", w.Header())
+	}
+}
+
+func TestTimeoutAnswers503AndDiscardsLateWrites(t *testing.T) {
+	lateWrite := make(chan error, 1)
+	handler := Timeout(TimeoutConfig{Timeout: 20 * time.Millisecond})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "1")
+		<-r.Context().Done()
+		time.Sleep(5 * time.Millisecond)
+		_, err := w.Write([]byte("late"))
+		lateWrite <- err
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", w.Code)
+	}
+	var body problem
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != "timeout" || body.Instance != "/slow" {
+		t.Fatalf("unexpected body %+v", body)
+	}
+	if w.Header().Get("X-Handler") != "" {
+		t.Fatal("handler header leaked into the timeout response")
+	}
+
+	select {
+	case err := <-lateWrite:
+		if !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Fatalf("late write returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler did not finish")
+	}
+	if strings.Contains(w.Body.String(), "late") {
+		t.Fatalf("late write reached the client: %q", w.Body.String())
+	}
+}
+
+func TestTimeoutRepanics(t *testing.T) {
+	handler := Timeout(TimeoutConfig{Timeout: time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Fatalf("recovered %v", p)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestTimeoutSkip(t *testing.T) {
+	handler := Timeout(TimeoutConfig{Timeout: time.Second, Skip: func(r *http.Request) bool { return true }})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("skipped request has a deadline")
+		}
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("skipped request lost its Flusher")
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
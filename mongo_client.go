@@ -2,7 +2,9 @@ package gogi
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/dejaniskra/go-gi/internal/config"
@@ -16,11 +18,18 @@ const defaultMongoTimeout = 10 * time.Second
 type MongoClient struct {
 	Writer *mongo.Client
 	Reader *mongo.Client
+	role   string
 }
 
-var mongoClients = make(map[string]*MongoClient)
+var (
+	mongoClients   = make(map[string]*MongoClient)
+	mongoClientsMu sync.Mutex
+)
 
 func GetMongoClient(role string) (*MongoClient, error) {
+	mongoClientsMu.Lock()
+	defer mongoClientsMu.Unlock()
+
 	if client, exists := mongoClients[role]; exists {
 		return client, nil
 	}
@@ -35,10 +44,25 @@ func GetMongoClient(role string) (*MongoClient, error) {
 		return nil, err
 	}
 
+	client.role = role
 	mongoClients[role] = client
 	return client, nil
 }
 
+func closeMongoClients(ctx context.Context) error {
+	mongoClientsMu.Lock()
+	defer mongoClientsMu.Unlock()
+
+	var errs []error
+	for role, client := range mongoClients {
+		if err := client.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("mongo %s: %w", role, err))
+		}
+		delete(mongoClients, role)
+	}
+	return errors.Join(errs...)
+}
+
 func newMongoClient(cfg *config.MongoRoleConfig) (*MongoClient, error) {
 	writer, err := newMongoConnection(cfg.Writer, "primary")
 	if err != nil {
@@ -126,9 +150,11 @@ func (c *MongoClient) FindOne(
 	filter any,
 	dest any,
 	opts ...*options.FindOneOptions,
-) error {
+) (err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "find_one", collectionAttributes(db, coll)...)
+	defer done(&err)
 	GetLogger().Debug(fmt.Sprintf("[Mongo] FindOne: %s.%s | filter=%v", db, coll, filter))
-	err := c.Reader.Database(db).Collection(coll).FindOne(ctx, filter, opts...).Decode(dest)
+	err = c.Reader.Database(db).Collection(coll).FindOne(ctx, filter, opts...).Decode(dest)
 	if err == mongo.ErrNoDocuments {
 		return nil
 	}
@@ -141,7 +167,9 @@ func (c *MongoClient) FindMany(
 	filter any,
 	handle func(*mongo.Cursor) error,
 	opts ...*options.FindOptions,
-) error {
+) (err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "find_many", collectionAttributes(db, coll)...)
+	defer done(&err)
 	GetLogger().Debug(fmt.Sprintf("[Mongo] FindMany: %s.%s | filter=%v", db, coll, filter))
 	cur, err := c.Reader.Database(db).Collection(coll).Find(ctx, filter, opts...)
 	if err != nil {
@@ -156,7 +184,9 @@ func (c *MongoClient) InsertOne(
 	db, coll string,
 	doc any,
 	opts ...*options.InsertOneOptions,
-) (*mongo.InsertOneResult, error) {
+) (result *mongo.InsertOneResult, err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "insert_one", collectionAttributes(db, coll)...)
+	defer done(&err)
 	GetLogger().Debug(fmt.Sprintf("[Mongo] InsertOne: %s.%s", db, coll))
 	return c.Writer.Database(db).Collection(coll).InsertOne(ctx, doc, opts...)
 }
@@ -166,7 +196,9 @@ func (c *MongoClient) InsertMany(
 	db, coll string,
 	docs []any,
 	opts ...*options.InsertManyOptions,
-) (*mongo.InsertManyResult, error) {
+) (result *mongo.InsertManyResult, err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "insert_many", collectionAttributes(db, coll)...)
+	defer done(&err)
 	GetLogger().Debug(fmt.Sprintf("[Mongo] InsertMany: %s.%s", db, coll))
 	return c.Writer.Database(db).Collection(coll).InsertMany(ctx, docs, opts...)
 }
@@ -176,7 +208,9 @@ func (c *MongoClient) UpdateOne(
 	db, coll string,
 	filter, update any,
 	opts ...*options.UpdateOptions,
-) (*mongo.UpdateResult, error) {
+) (result *mongo.UpdateResult, err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "update_one", collectionAttributes(db, coll)...)
+	defer done(&err)
 	GetLogger().Debug(fmt.Sprintf("[Mongo] UpdateOne: %s.%s | filter=%v", db, coll, filter))
 	return c.Writer.Database(db).Collection(coll).UpdateOne(ctx, filter, update, opts...)
 }
@@ -186,7 +220,9 @@ func (c *MongoClient) UpdateMany(
 	db, coll string,
 	filter, update any,
 	opts ...*options.UpdateOptions,
-) (*mongo.UpdateResult, error) {
+) (result *mongo.UpdateResult, err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "update_many", collectionAttributes(db, coll)...)
+	defer done(&err)
 	GetLogger().Debug(fmt.Sprintf("[Mongo] UpdateMany: %s.%s | filter=%v", db, coll, filter))
 	return c.Writer.Database(db).Collection(coll).UpdateMany(ctx, filter, update, opts...)
 }
@@ -196,7 +232,9 @@ func (c *MongoClient) DeleteOne(
 	db, coll string,
 	filter any,
 	opts ...*options.DeleteOptions,
-) (*mongo.DeleteResult, error) {
+) (result *mongo.DeleteResult, err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "delete_one", collectionAttributes(db, coll)...)
+	defer done(&err)
 	GetLogger().Debug(fmt.Sprintf("[Mongo] DeleteOne: %s.%s | filter=%v", db, coll, filter))
 	return c.Writer.Database(db).Collection(coll).DeleteOne(ctx, filter, opts...)
 }
@@ -206,7 +244,9 @@ func (c *MongoClient) DeleteMany(
 	db, coll string,
 	filter any,
 	opts ...*options.DeleteOptions,
-) (*mongo.DeleteResult, error) {
+) (result *mongo.DeleteResult, err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "delete_many", collectionAttributes(db, coll)...)
+	defer done(&err)
 	GetLogger().Debug(fmt.Sprintf("[Mongo] DeleteMany: %s.%s | filter=%v", db, coll, filter))
 	return c.Writer.Database(db).Collection(coll).DeleteMany(ctx, filter, opts...)
 }
@@ -214,7 +254,9 @@ func (c *MongoClient) DeleteMany(
 func (c *MongoClient) WithTransaction(
 	ctx context.Context,
 	fn func(mongo.SessionContext) error,
-) error {
+) (err error) {
+	ctx, done := startDBCall(ctx, "mongo", c.role, "transaction")
+	defer done(&err)
 	session, err := c.Writer.StartSession()
 	if err != nil {
 		return err
@@ -0,0 +1,443 @@
+package gogi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	multipartConfigKey contextKey = "multipartConfig"
+
+	multipartDefaultMaxRequestSize = 32 << 20
+	multipartDefaultMaxFileSize    = 10 << 20
+	multipartDefaultMaxFiles       = 10
+	multipartDefaultMaxFieldSize   = 1 << 20
+	multipartSniffLen              = 512
+)
+
+// MultipartConfig limits multipart/form-data parsing. Zero values use the
+// defaults: 32 MiB per request, 10 MiB per file, 10 files and 1 MiB per
+// plain field. AllowedTypes restricts files by their sniffed content type
+// and accepts wildcards such as "image/*". Files go to Sink, or to
+// temporary files that are removed once the handler returns.
+type MultipartConfig struct {
+	MaxRequestSize int64
+	MaxFileSize    int64
+	MaxFiles       int
+	MaxFieldSize   int64
+	AllowedTypes   []string
+	Sink           FileSink
+}
+
+// WithMultipartConfig sets the multipart limits used by the route.
+func WithMultipartConfig(config MultipartConfig) RouteOption {
+	return func(route *route) {
+		route.multipart = &config
+	}
+}
+
+func (config MultipartConfig) withDefaults() MultipartConfig {
+	if config.MaxRequestSize <= 0 {
+		config.MaxRequestSize = multipartDefaultMaxRequestSize
+	}
+	if config.MaxFileSize <= 0 {
+		config.MaxFileSize = multipartDefaultMaxFileSize
+	}
+	if config.MaxFiles <= 0 {
+		config.MaxFiles = multipartDefaultMaxFiles
+	}
+	if config.MaxFieldSize <= 0 {
+		config.MaxFieldSize = multipartDefaultMaxFieldSize
+	}
+	return config
+}
+
+// FileSink stores uploaded files as they stream in, so they never have to
+// fit in memory. Store must consume content and returns where the file
+// ended up. A sink may also implement Open(location) (io.ReadCloser, error)
+// and Remove(location) error for UploadedFile.Open and MultipartForm.RemoveAll.
+type FileSink interface {
+	Store(ctx context.Context, file *UploadedFile, content io.Reader) (string, error)
+}
+
+type fileOpener interface {
+	Open(location string) (io.ReadCloser, error)
+}
+
+type fileRemover interface {
+	Remove(location string) error
+}
+
+// DiskSink writes each file to a new file in Dir, or the system temporary
+// directory when Dir is empty.
+type DiskSink struct {
+	Dir string
+}
+
+func (sink DiskSink) Store(ctx context.Context, file *UploadedFile, content io.Reader) (string, error) {
+	f, err := os.CreateTemp(sink.Dir, "upload-*"+filepath.Ext(file.Filename))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (DiskSink) Open(location string) (io.ReadCloser, error) {
+	return os.Open(location)
+}
+
+func (DiskSink) Remove(location string) error {
+	return os.Remove(location)
+}
+
+// UploadedFile describes a stored file part. DeclaredType is what the
+// client sent; ContentType is sniffed from the first bytes.
+type UploadedFile struct {
+	Field        string
+	Filename     string
+	Header       textproto.MIMEHeader
+	DeclaredType string
+	ContentType  string
+	Size         int64
+	Location     string
+	sink         FileSink
+}
+
+// Open reads the stored file back, if the sink supports it.
+func (file *UploadedFile) Open() (io.ReadCloser, error) {
+	opener, ok := file.sink.(fileOpener)
+	if !ok {
+		return nil, fmt.Errorf("file sink %T cannot open stored files", file.sink)
+	}
+	return opener.Open(file.Location)
+}
+
+type MultipartForm struct {
+	Values map[string][]string
+	Files  map[string][]*UploadedFile
+}
+
+func (form *MultipartForm) Value(name string) string {
+	if values := form.Values[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (form *MultipartForm) File(name string) (*UploadedFile, bool) {
+	if files := form.Files[name]; len(files) > 0 {
+		return files[0], true
+	}
+	return nil, false
+}
+
+// RemoveAll deletes every stored file whose sink supports removal.
+func (form *MultipartForm) RemoveAll() error {
+	var errs []error
+	for _, files := range form.Files {
+		for _, file := range files {
+			if remover, ok := file.sink.(fileRemover); ok {
+				if err := remover.Remove(file.Location); err != nil && !errors.Is(err, os.ErrNotExist) {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var errMultipartTooLarge = errors.New("multipart request too large")
+
+// Multipart parses a multipart/form-data body using the route's
+// MultipartConfig. The result is cached, so it may be called repeatedly.
+// Errors are ready for WriteProblem: 415 when the body is not multipart or
+// a file type is not allowed, 413 when a limit (including the route's body
+// limit) is exceeded and 400 when the body is malformed.
+func (req *HTTPServerRequest) Multipart() (*MultipartForm, error) {
+	if req.multipart != nil || req.multipartErr != nil {
+		return req.multipart, req.multipartErr
+	}
+
+	var config MultipartConfig
+	if req.Context != nil {
+		if routeConfig, ok := req.Context.Value(multipartConfigKey).(*MultipartConfig); ok {
+			config = *routeConfig
+		}
+	}
+	temporary := config.Sink == nil
+	config = config.withDefaults()
+	if temporary {
+		config.Sink = DiskSink{}
+	}
+
+	form, err := parseMultipart(req, config)
+	if err != nil {
+		req.multipartErr = err
+		return nil, err
+	}
+	req.multipart = form
+	req.multipartTemporary = temporary
+	return form, nil
+}
+
+// cleanupMultipart removes temporary upload files once the handler is done.
+func (req *HTTPServerRequest) cleanupMultipart() {
+	if req.multipart == nil || !req.multipartTemporary {
+		return
+	}
+	if err := req.multipart.RemoveAll(); err != nil {
+		GetLogger().Warn(fmt.Sprintf("[HTTP] Removing uploaded files: %v", err))
+	}
+}
+
+func parseMultipart(req *HTTPServerRequest, config MultipartConfig) (*MultipartForm, error) {
+	mediaType, params, err := mime.ParseMediaType(req.Headers["Content-Type"])
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, &HTTPError{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    "unsupported_media_type",
+			Message: "expected a multipart/form-data body",
+		}
+	}
+	if req.Body == nil {
+		return nil, &HTTPError{Status: http.StatusBadRequest, Code: "invalid_multipart", Message: "empty body"}
+	}
+
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	body := &limitedReader{r: req.Body, remaining: config.MaxRequestSize}
+	reader := multipart.NewReader(body, params["boundary"])
+	form := &MultipartForm{
+		Values: make(map[string][]string),
+		Files:  make(map[string][]*UploadedFile),
+	}
+	fileCount := 0
+
+	fail := func(err error) (*MultipartForm, error) {
+		form.RemoveAll()
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return nil, httpErr
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, err
+		}
+		if errors.Is(err, errMultipartTooLarge) {
+			return nil, &HTTPError{
+				Status:  http.StatusRequestEntityTooLarge,
+				Code:    "request_too_large",
+				Message: fmt.Sprintf("request body exceeds %d bytes", config.MaxRequestSize),
+				Err:     err,
+			}
+		}
+		return nil, &HTTPError{Status: http.StatusBadRequest, Code: "invalid_multipart", Message: err.Error(), Err: err}
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return form, nil
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, config.MaxFieldSize+1))
+			part.Close()
+			if err != nil {
+				return fail(err)
+			}
+			if int64(len(value)) > config.MaxFieldSize {
+				return fail(&HTTPError{
+					Status:  http.StatusRequestEntityTooLarge,
+					Code:    "field_too_large",
+					Message: fmt.Sprintf("field %q exceeds %d bytes", name, config.MaxFieldSize),
+				})
+			}
+			form.Values[name] = append(form.Values[name], string(value))
+			continue
+		}
+
+		fileCount++
+		if fileCount > config.MaxFiles {
+			part.Close()
+			return fail(&HTTPError{
+				Status:  http.StatusRequestEntityTooLarge,
+				Code:    "too_many_files",
+				Message: fmt.Sprintf("at most %d files may be uploaded", config.MaxFiles),
+			})
+		}
+
+		file, err := storeFile(ctx, part, config)
+		part.Close()
+		if file != nil {
+			form.Files[name] = append(form.Files[name], file)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+}
+
+func storeFile(ctx context.Context, part *multipart.Part, config MultipartConfig) (*UploadedFile, error) {
+	file := &UploadedFile{
+		Field:        part.FormName(),
+		Filename:     filepath.Base(part.FileName()),
+		Header:       part.Header,
+		DeclaredType: part.Header.Get("Content-Type"),
+		sink:         config.Sink,
+	}
+
+	head := make([]byte, multipartSniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+	file.ContentType = http.DetectContentType(head)
+
+	if !contentTypeAllowed(file.ContentType, config.AllowedTypes) {
+		return nil, &HTTPError{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    "file_type_not_allowed",
+			Message: fmt.Sprintf("file %q has disallowed type %s", file.Filename, file.ContentType),
+			Details: map[string][]string{"allowed": config.AllowedTypes},
+		}
+	}
+
+	content := &limitedReader{r: io.MultiReader(bytes.NewReader(head), part), remaining: config.MaxFileSize}
+	location, err := config.Sink.Store(ctx, file, content)
+	if err != nil {
+		if errors.Is(err, errMultipartTooLarge) && content.remaining < 0 {
+			return nil, &HTTPError{
+				Status:  http.StatusRequestEntityTooLarge,
+				Code:    "file_too_large",
+				Message: fmt.Sprintf("file %q exceeds %d bytes", file.Filename, config.MaxFileSize),
+				Err:     err,
+			}
+		}
+		return nil, err
+	}
+	file.Location = location
+	file.Size = content.read
+	return file, nil
+}
+
+func contentTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, pattern := range allowed {
+		if pattern == mediaType || pattern == major+"/*" || pattern == "*/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedReader fails with errMultipartTooLarge once more than remaining
+// bytes have been read, instead of silently truncating like io.LimitReader.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	read      int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errMultipartTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errMultipartTooLarge
+	}
+	return n, err
+}
+
+var (
+	uploadedFileType      = reflect.TypeOf((*UploadedFile)(nil))
+	uploadedFileSliceType = reflect.TypeOf([]*UploadedFile(nil))
+)
+
+func decodeMultipart(req *HTTPServerRequest, dest any) error {
+	form, err := req.Multipart()
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(dest).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs []FieldError
+	for _, sf := range reflect.VisibleFields(v.Type()) {
+		name := formFieldName(sf)
+		if name == "" {
+			continue
+		}
+		field := v.FieldByIndex(sf.Index)
+		switch sf.Type {
+		case uploadedFileType:
+			if file, ok := form.File(name); ok {
+				field.Set(reflect.ValueOf(file))
+			}
+		case uploadedFileSliceType:
+			field.Set(reflect.ValueOf(form.Files[name]))
+		default:
+			values := form.Values[name]
+			if len(values) == 0 {
+				continue
+			}
+			if err := setFieldValues(field, values); err != nil {
+				if errors.Is(err, errUnsupportedFieldType) {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				errs = append(errs, FieldError{Field: name, Source: "body", Rule: "type", Message: err.Error()})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &BindError{StatusCode: http.StatusBadRequest, Errors: errs}
+	}
+	return nil
+}
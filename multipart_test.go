@@ -0,0 +1,291 @@
+package gogi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var pngTestContent = "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 24)
+
+type uploadInput struct {
+	Title  string          `form:"title" validate:"required"`
+	Tags   []string        `form:"tag"`
+	Avatar *UploadedFile   `form:"avatar"`
+	Docs   []*UploadedFile `form:"doc"`
+}
+
+type uploadOutput struct {
+	Title        string   `json:"title"`
+	Tags         []string `json:"tags"`
+	Avatar       []byte   `json:"avatar"`
+	AvatarType   string   `json:"avatar_type"`
+	AvatarStored bool     `json:"avatar_stored"`
+	Docs         int      `json:"docs"`
+}
+
+type testMultipartPart struct {
+	field    string
+	filename string // empty for a plain field
+	content  string
+}
+
+func newTestMultipartRequest(t *testing.T, parts ...testMultipartPart) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range parts {
+		var (
+			w   io.Writer
+			err error
+		)
+		if part.filename != "" {
+			w, err = mw.CreateFormFile(part.field, part.filename)
+		} else {
+			w, err = mw.CreateFormField(part.field)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, part.content)
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func newTestUploadHandler(config MultipartConfig) http.Handler {
+	server := &HttpServer{router: newRouter()}
+	server.addRoute(nil, HTTP_POST, "/upload", httpHandler(Handle(func(ctx context.Context, in uploadInput) (uploadOutput, error) {
+		out := uploadOutput{Title: in.Title, Tags: in.Tags, Docs: len(in.Docs)}
+		if in.Avatar != nil {
+			f, err := in.Avatar.Open()
+			if err != nil {
+				return out, err
+			}
+			defer f.Close()
+			data, _ := io.ReadAll(f)
+			out.Avatar = data
+			out.AvatarType = in.Avatar.ContentType
+			_, err = os.Stat(in.Avatar.Location)
+			out.AvatarStored = err == nil
+		}
+		return out, nil
+	})), WithMultipartConfig(config))
+	return server.handler()
+}
+
+// memorySink keeps files in memory and records what was removed.
+type memorySink struct {
+	mu      sync.Mutex
+	files   map[string]string
+	removed []string
+}
+
+func (sink *memorySink) Store(ctx context.Context, file *UploadedFile, content io.Reader) (string, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	location := fmt.Sprintf("mem-%d", len(sink.files)+len(sink.removed))
+	sink.files[location] = string(data)
+	return location, nil
+}
+
+func (sink *memorySink) Open(location string) (io.ReadCloser, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	data, ok := sink.files[location]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func (sink *memorySink) Remove(location string) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if _, ok := sink.files[location]; !ok {
+		return os.ErrNotExist
+	}
+	delete(sink.files, location)
+	sink.removed = append(sink.removed, location)
+	return nil
+}
+
+func TestMultipartBindsFieldsAndFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	handler := newTestUploadHandler(MultipartConfig{})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newTestMultipartRequest(t,
+		testMultipartPart{field: "title", content: "holiday"},
+		testMultipartPart{field: "tag", content: "beach"},
+		testMultipartPart{field: "avatar", filename: "me.png", content: pngTestContent},
+		testMultipartPart{field: "tag", content: "sun"},
+		testMultipartPart{field: "doc", filename: "a.txt", content: "first"},
+		testMultipartPart{field: "doc", filename: "b.txt", content: "second"},
+	))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	var out uploadOutput
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Title != "holiday" || strings.Join(out.Tags, ",") != "beach,sun" || out.Docs != 2 {
+		t.Errorf("bound %+v, want the fields next to the files", out)
+	}
+	if string(out.Avatar) != pngTestContent || out.AvatarType != "image/png" || !out.AvatarStored {
+		t.Errorf("avatar %q of type %q (stored %v), want the uploaded PNG on disk", out.Avatar, out.AvatarType, out.AvatarStored)
+	}
+	assertEmptyDir(t, dir)
+}
+
+func TestMultipartErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MultipartConfig
+		parts  []testMultipartPart
+		status int
+		code   string
+	}{
+		{
+			name:   "file too large",
+			config: MultipartConfig{MaxFileSize: 16},
+			parts: []testMultipartPart{
+				{field: "title", content: "x"},
+				{field: "doc", filename: "small.txt", content: "ok"},
+				{field: "avatar", filename: "big.txt", content: strings.Repeat("a", 17)},
+			},
+			status: http.StatusRequestEntityTooLarge,
+			code:   "file_too_large",
+		},
+		{
+			name:   "request too large",
+			config: MultipartConfig{MaxRequestSize: 512},
+			parts: []testMultipartPart{
+				{field: "doc", filename: "a.txt", content: strings.Repeat("a", 200)},
+				{field: "doc", filename: "b.txt", content: strings.Repeat("b", 400)},
+			},
+			status: http.StatusRequestEntityTooLarge,
+			code:   "request_too_large",
+		},
+		{
+			name:   "field too large",
+			config: MultipartConfig{MaxFieldSize: 4},
+			parts:  []testMultipartPart{{field: "title", content: "too long"}},
+			status: http.StatusRequestEntityTooLarge,
+			code:   "field_too_large",
+		},
+		{
+			name:   "too many files",
+			config: MultipartConfig{MaxFiles: 1},
+			parts: []testMultipartPart{
+				{field: "doc", filename: "a.txt", content: "a"},
+				{field: "doc", filename: "b.txt", content: "b"},
+			},
+			status: http.StatusRequestEntityTooLarge,
+			code:   "too_many_files",
+		},
+		{
+			name:   "type not allowed",
+			config: MultipartConfig{AllowedTypes: []string{"image/*"}},
+			parts: []testMultipartPart{
+				{field: "avatar", filename: "me.png", content: pngTestContent},
+				{field: "doc", filename: "a.txt", content: "plain text"},
+			},
+			status: http.StatusUnsupportedMediaType,
+			code:   "file_type_not_allowed",
+		},
+		{
+			name:   "validation fails after files were stored",
+			parts:  []testMultipartPart{{field: "avatar", filename: "me.png", content: pngTestContent}},
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, custom := range []bool{false, true} {
+				dir := t.TempDir()
+				t.Setenv("TMPDIR", dir)
+				sink := &memorySink{files: map[string]string{}}
+				config := tt.config
+				if custom {
+					config.Sink = sink
+				}
+
+				w := httptest.NewRecorder()
+				newTestUploadHandler(config).ServeHTTP(w, newTestMultipartRequest(t, tt.parts...))
+				if w.Code != tt.status {
+					t.Fatalf("custom sink %v: status %d, want %d: %s", custom, w.Code, tt.status, w.Body.String())
+				}
+				if got := w.Header().Get("Content-Type"); got != problemContentType {
+					t.Errorf("Content-Type %q, want problem+json", got)
+				}
+				if tt.code != "" {
+					var problem struct {
+						Code string `json:"code"`
+					}
+					json.Unmarshal(w.Body.Bytes(), &problem)
+					if problem.Code != tt.code {
+						t.Errorf("code %q, want %q", problem.Code, tt.code)
+					}
+				}
+
+				assertEmptyDir(t, dir)
+				if len(sink.files) != 0 {
+					t.Errorf("sink kept %d files after the request failed", len(sink.files))
+				}
+			}
+		})
+	}
+}
+
+func TestMultipartAllowedTypes(t *testing.T) {
+	tests := []struct {
+		contentType string
+		allowed     []string
+		want        bool
+	}{
+		{contentType: "image/png", want: true},
+		{contentType: "image/png", allowed: []string{"image/png"}, want: true},
+		{contentType: "image/png", allowed: []string{"image/*"}, want: true},
+		{contentType: "text/plain; charset=utf-8", allowed: []string{"text/plain"}, want: true},
+		{contentType: "text/plain; charset=utf-8", allowed: []string{"image/*", "application/pdf"}},
+		{contentType: "application/pdf", allowed: []string{"application/*"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType+" in "+strings.Join(tt.allowed, ","), func(t *testing.T) {
+			if got := contentTypeAllowed(tt.contentType, tt.allowed); got != tt.want {
+				t.Fatalf("contentTypeAllowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("temporary file %s left behind", entry.Name())
+	}
+}
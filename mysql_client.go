@@ -3,7 +3,9 @@ package gogi
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 
 	"time"
 
@@ -20,11 +22,18 @@ const (
 type MySQLClient struct {
 	Writer *sql.DB
 	Reader *sql.DB
+	role   string
 }
 
-var mysqlClients = make(map[string]*MySQLClient)
+var (
+	mysqlClients   = make(map[string]*MySQLClient)
+	mysqlClientsMu sync.Mutex
+)
 
 func GetMySQLClient(role string) (*MySQLClient, error) {
+	mysqlClientsMu.Lock()
+	defer mysqlClientsMu.Unlock()
+
 	if client, exists := mysqlClients[role]; exists {
 		return client, nil
 	}
@@ -39,10 +48,25 @@ func GetMySQLClient(role string) (*MySQLClient, error) {
 		return nil, err
 	}
 
+	client.role = role
 	mysqlClients[role] = client
 	return client, nil
 }
 
+func closeMySQLClients() error {
+	mysqlClientsMu.Lock()
+	defer mysqlClientsMu.Unlock()
+
+	var errs []error
+	for role, client := range mysqlClients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("mysql %s: %w", role, err))
+		}
+		delete(mysqlClients, role)
+	}
+	return errors.Join(errs...)
+}
+
 func newMySQLClient(dbRoleConfig *config.DBRoleConfig) (*MySQLClient, error) {
 	writer, err := newDbConnection(&dbRoleConfig.Writer)
 	if err != nil {
@@ -128,14 +152,18 @@ func (c *MySQLClient) Close() error {
 	return nil
 }
 
-func (c *MySQLClient) FindOne(ctx context.Context, query string, args []any, dest ...any) error {
+func (c *MySQLClient) FindOne(ctx context.Context, query string, args []any, dest ...any) (err error) {
+	ctx, done := startDBCall(ctx, "mysql", c.role, "find_one", queryAttribute(query))
+	defer done(&err)
 	message := fmt.Sprintf("[MySQL] FindOne: %s | args=%v", query, args)
 	GetLogger().Debug(message)
 	row := c.Reader.QueryRowContext(ctx, query, args...)
 	return row.Scan(dest...)
 }
 
-func (c *MySQLClient) FindMany(ctx context.Context, query string, args []any, scanFunc func(*sql.Rows) error) error {
+func (c *MySQLClient) FindMany(ctx context.Context, query string, args []any, scanFunc func(*sql.Rows) error) (err error) {
+	ctx, done := startDBCall(ctx, "mysql", c.role, "find_many", queryAttribute(query))
+	defer done(&err)
 	message := fmt.Sprintf("[MySQL] FindMany: %s | args=%v", query, args)
 	GetLogger().Debug(message)
 	rows, err := c.Reader.QueryContext(ctx, query, args...)
@@ -152,14 +180,18 @@ func (c *MySQLClient) FindMany(ctx context.Context, query string, args []any, sc
 	return rows.Err()
 }
 
-func (c *MySQLClient) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+func (c *MySQLClient) Exec(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	ctx, done := startDBCall(ctx, "mysql", c.role, "exec", queryAttribute(query))
+	defer done(&err)
 	message := fmt.Sprintf("[MySQL] Exec: %s | args=%v", query, args)
 	GetLogger().Debug(message)
 
 	return c.Writer.ExecContext(ctx, query, args...)
 }
 
-func (c *MySQLClient) WithTx(ctx context.Context, fn func(*sql.Tx) error) error {
+func (c *MySQLClient) WithTx(ctx context.Context, fn func(*sql.Tx) error) (err error) {
+	ctx, done := startDBCall(ctx, "mysql", c.role, "tx")
+	defer done(&err)
 	tx, err := c.Writer.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -0,0 +1,764 @@
+package gogi
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	openAPIDefaultPath   = "/openapi.json"
+	openAPIDefaultUIPath = "/docs"
+)
+
+// OpenAPIConfig describes the generated document and where it is served.
+// Path defaults to /openapi.json. UI may be "swagger" or "redoc" to also
+// serve a documentation page at UIPath (default /docs).
+//
+// The page loads pinned versions of the viewer's assets from jsDelivr, so
+// browsers opening it need access to that CDN. Set UIScript and
+// UIStylesheet to load them from elsewhere, for example from copies served
+// with Static, and give their Integrity to have browsers verify them.
+type OpenAPIConfig struct {
+	Title        string
+	Version      string
+	Description  string
+	Servers      []string
+	Path         string
+	UI           string
+	UIPath       string
+	UIScript     OpenAPIAsset
+	UIStylesheet OpenAPIAsset
+}
+
+// OpenAPIAsset is a script or stylesheet the documentation page loads.
+// Integrity is a Subresource Integrity hash such as "sha384-...".
+type OpenAPIAsset struct {
+	URL       string
+	Integrity string
+}
+
+var openAPIUIAssets = map[string][2]OpenAPIAsset{ // script, stylesheet
+	"swagger": {
+		{URL: "https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14/swagger-ui-bundle.js"},
+		{URL: "https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14/swagger-ui.css"},
+	},
+	"redoc": {
+		{URL: "https://cdn.jsdelivr.net/npm/redoc@2.1.5/bundles/redoc.standalone.js"},
+	},
+}
+
+// OpenAPIOperation documents a route. Hidden leaves it out of the document.
+type OpenAPIOperation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	Hidden      bool
+}
+
+type routeDoc struct {
+	OpenAPIOperation
+	request   reflect.Type
+	responses map[int]reflect.Type // a nil type means no body
+}
+
+func (route *route) doc() *routeDoc {
+	if route.openAPI == nil {
+		route.openAPI = &routeDoc{}
+	}
+	return route.openAPI
+}
+
+func WithOpenAPI(operation OpenAPIOperation) RouteOption {
+	return func(route *route) {
+		route.doc().OpenAPIOperation = operation
+	}
+}
+
+// WithRequest documents the type the route binds with Bind: its path, query
+// and header fields become parameters and the rest the request body.
+func WithRequest[T any]() RouteOption {
+	return func(route *route) {
+		route.doc().request = reflect.TypeFor[T]()
+	}
+}
+
+// WithResponse documents a response body of type T for status.
+func WithResponse[T any](status int) RouteOption {
+	return func(route *route) {
+		doc := route.doc()
+		if doc.responses == nil {
+			doc.responses = make(map[int]reflect.Type)
+		}
+		doc.responses[status] = reflect.TypeFor[T]()
+	}
+}
+
+// WithTypes documents a route served by Handle with the same In and Out,
+// using the status Handle would answer with.
+func WithTypes[In, Out any]() RouteOption {
+	return func(route *route) {
+		WithRequest[In]()(route)
+		out := reflect.TypeFor[Out]()
+		status := successStatus(out)
+		if status == http.StatusNoContent {
+			out = nil
+		}
+		doc := route.doc()
+		if doc.responses == nil {
+			doc.responses = make(map[int]reflect.Type)
+		}
+		doc.responses[status] = out
+	}
+}
+
+func successStatus(t reflect.Type) int {
+	v := reflect.New(t).Elem()
+	if t.Kind() == reflect.Pointer {
+		v = reflect.New(t.Elem())
+	}
+	if coder, ok := v.Interface().(StatusCoder); ok {
+		return coder.StatusCode()
+	}
+	return http.StatusOK
+}
+
+// EnableOpenAPI serves an OpenAPI 3.1 document describing every registered
+// route, built on first request. Routes registered with WithTypes,
+// WithRequest or WithResponse get parameter and body schemas derived from
+// their Go types and validate tags.
+func (application *Application) EnableOpenAPI(config OpenAPIConfig) {
+	httpServer := getServer()
+	if application.httpServer == nil {
+		application.httpServer = httpServer
+	} // TODO: revisit this
+
+	config = config.withDefaults()
+	httpServer.openAPIConfig = &config
+	hidden := WithOpenAPI(OpenAPIOperation{Hidden: true})
+
+	var (
+		once sync.Once
+		spec []byte
+		err  error
+	)
+	httpServer.addRoute(nil, HTTP_GET, config.Path, httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		once.Do(func() {
+			spec, err = httpServer.openAPISpec(config)
+		})
+		if err != nil {
+			WriteProblem(req, res, err)
+			return
+		}
+		res.Headers["Content-Type"] = "application/json"
+		res.Body = bytes.NewReader(spec)
+	}), hidden)
+
+	if config.UI == "" {
+		return
+	}
+	page, err := openAPIPage(config)
+	if err != nil {
+		panic(err)
+	}
+	httpServer.addRoute(nil, HTTP_GET, config.UIPath, httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		res.Headers["Content-Type"] = "text/html; charset=utf-8"
+		res.Body = strings.NewReader(page)
+	}), hidden)
+}
+
+// OpenAPISpec returns the document EnableOpenAPI serves, for example to
+// write it to a file in CI.
+func (application *Application) OpenAPISpec() ([]byte, error) {
+	if application.httpServer == nil {
+		return nil, fmt.Errorf("no routes registered")
+	}
+	config := OpenAPIConfig{}.withDefaults()
+	if application.httpServer.openAPIConfig != nil {
+		config = *application.httpServer.openAPIConfig
+	}
+	return application.httpServer.openAPISpec(config)
+}
+
+func (config OpenAPIConfig) withDefaults() OpenAPIConfig {
+	if config.Title == "" {
+		config.Title = "API"
+	}
+	if config.Version == "" {
+		config.Version = "1.0.0"
+	}
+	if config.Path == "" {
+		config.Path = openAPIDefaultPath
+	}
+	if config.UIPath == "" {
+		config.UIPath = openAPIDefaultUIPath
+	}
+	return config
+}
+
+func openAPIPage(config OpenAPIConfig) (string, error) {
+	specURL, err := json.Marshal(config.Path)
+	if err != nil {
+		return "", err
+	}
+	title := html.EscapeString(config.Title)
+
+	assets, ok := openAPIUIAssets[config.UI]
+	if !ok {
+		return "", fmt.Errorf("unknown OpenAPI UI %q, expected swagger or redoc", config.UI)
+	}
+	script, stylesheet := assets[0], assets[1]
+	if config.UIScript.URL != "" {
+		script = config.UIScript
+	}
+	if config.UIStylesheet.URL != "" {
+		stylesheet = config.UIStylesheet
+	}
+
+	var page strings.Builder
+	page.WriteString("<!doctype html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>" + title + "</title>\n")
+	if stylesheet.URL != "" {
+		page.WriteString(`<link rel="stylesheet" href="` + html.EscapeString(stylesheet.URL) + `"` + integrityAttrs(stylesheet) + ">\n")
+	}
+	page.WriteString("</head>\n<body>\n")
+	switch config.UI {
+	case "swagger":
+		page.WriteString(`<div id="swagger-ui"></div>` + "\n")
+		page.WriteString(`<script src="` + html.EscapeString(script.URL) + `"` + integrityAttrs(script) + "></script>\n")
+		page.WriteString(`<script>SwaggerUIBundle({url: ` + string(specURL) + `, dom_id: "#swagger-ui"});</script>` + "\n")
+	case "redoc":
+		page.WriteString(`<div id="redoc"></div>` + "\n")
+		page.WriteString(`<script src="` + html.EscapeString(script.URL) + `"` + integrityAttrs(script) + "></script>\n")
+		page.WriteString(`<script>Redoc.init(` + string(specURL) + `, {}, document.getElementById("redoc"));</script>` + "\n")
+	}
+	page.WriteString("</body>\n</html>\n")
+	return page.String(), nil
+}
+
+func integrityAttrs(asset OpenAPIAsset) string {
+	if asset.Integrity == "" {
+		return ""
+	}
+	return ` integrity="` + html.EscapeString(asset.Integrity) + `" crossorigin="anonymous"`
+}
+
+type jsonSchema = map[string]any
+
+type openAPIDocument struct {
+	OpenAPI    string                                        `json:"openapi"`
+	Info       openAPIInfo                                   `json:"info"`
+	Servers    []openAPIServer                               `json:"servers,omitempty"`
+	Paths      map[string]map[string]*openAPIOperationObject `json:"paths"`
+	Components openAPIComponents                             `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]jsonSchema `json:"schemas,omitempty"`
+}
+
+type openAPIOperationObject struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Deprecated  bool                       `json:"deprecated,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string     `json:"name"`
+	In          string     `json:"in"`
+	Description string     `json:"description,omitempty"`
+	Required    bool       `json:"required,omitempty"`
+	Schema      jsonSchema `json:"schema"`
+	CatchAll    bool       `json:"x-catch-all,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema jsonSchema `json:"schema"`
+}
+
+func (httpServer *HttpServer) openAPISpec(config OpenAPIConfig) ([]byte, error) {
+	builder := newSchemaBuilder()
+	doc := openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    openAPIInfo{Title: config.Title, Version: config.Version, Description: config.Description},
+		Paths:   make(map[string]map[string]*openAPIOperationObject),
+	}
+	for _, server := range config.Servers {
+		doc.Servers = append(doc.Servers, openAPIServer{URL: server})
+	}
+
+	explicit := make(map[string]bool)
+	for _, route := range httpServer.routes {
+		if _, ok := route.endpoint.(*staticEndpoint); ok {
+			continue
+		}
+		if route.openAPI != nil && route.openAPI.Hidden {
+			continue
+		}
+
+		segments, err := parsePattern(route.Path)
+		if err != nil {
+			return nil, err
+		}
+		method := strings.ToLower(route.Method)
+		for i, variant := range patternVariants(segments) {
+			path, params := openAPIPath(variant)
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*openAPIOperationObject)
+			}
+			// Like the router, a route registered for exactly this path wins
+			// over one that only matches it with trailing segments absent.
+			key := route.Method + " " + path
+			if i > 0 && explicit[key] {
+				continue
+			}
+			operation := builder.operation(route, params)
+			if i > 0 {
+				operation.OperationID = "" // must stay unique
+			} else {
+				explicit[key] = true
+			}
+			doc.Paths[path][method] = operation
+		}
+	}
+
+	doc.Components.Schemas = builder.components
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// patternVariants mirrors router.add for optional trailing parameters and
+// catch-alls, yielding one path per prefix since OpenAPI path parameters
+// are always required.
+func patternVariants(segments []routeSegment) [][]routeSegment {
+	required := len(segments)
+	for required > 0 && (segments[required-1].optional || segments[required-1].kind == nodeCatchAll) {
+		required--
+	}
+	variants := make([][]routeSegment, 0, len(segments)-required+1)
+	for end := len(segments); end >= required; end-- {
+		variants = append(variants, segments[:end])
+	}
+	return variants
+}
+
+func openAPIPath(segments []routeSegment) (string, []routeSegment) {
+	if len(segments) == 0 {
+		return "/", nil
+	}
+	var path strings.Builder
+	var params []routeSegment
+	for _, segment := range segments {
+		path.WriteByte('/')
+		if segment.kind == nodeStatic {
+			path.WriteString(segment.value)
+			continue
+		}
+		path.WriteString("{" + segment.value + "}")
+		params = append(params, segment)
+	}
+	return path.String(), params
+}
+
+type schemaBuilder struct {
+	components map[string]jsonSchema
+	names      map[reflect.Type]string
+	taken      map[string]bool
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		components: make(map[string]jsonSchema),
+		names:      make(map[reflect.Type]string),
+		taken:      make(map[string]bool),
+	}
+}
+
+func (builder *schemaBuilder) operation(route *route, pathParams []routeSegment) *openAPIOperationObject {
+	doc := route.openAPI
+	if doc == nil {
+		doc = &routeDoc{}
+	}
+
+	operation := &openAPIOperationObject{
+		OperationID: route.Name,
+		Summary:     doc.Summary,
+		Description: doc.Description,
+		Tags:        doc.Tags,
+		Deprecated:  doc.Deprecated,
+		Responses:   make(map[string]openAPIResponse),
+	}
+
+	var plan *bindPlan
+	var request reflect.Type
+	if doc.request != nil {
+		request = derefType(doc.request)
+		if request.Kind() == reflect.Struct {
+			plan = bindPlanFor(request)
+		}
+	}
+
+	for _, segment := range pathParams {
+		schema := constraintSchema(segment)
+		if field, ok := plan.field("path", segment.value); ok {
+			schema = builder.paramSchema(request.FieldByIndex(field.index).Type, field.rules)
+		}
+		param := openAPIParameter{
+			Name:     segment.value,
+			In:       "path",
+			Required: true,
+			Schema:   schema,
+		}
+		if segment.kind == nodeCatchAll {
+			// OpenAPI has no multi-segment path parameters; say so for
+			// readers and mark it for tools that understand the extension.
+			param.Description = `The rest of the path, which may contain "/".`
+			param.CatchAll = true
+		}
+		operation.Parameters = append(operation.Parameters, param)
+	}
+
+	if plan != nil {
+		for _, source := range []string{"query", "header"} {
+			for _, field := range plan.fields {
+				if field.source != source {
+					continue
+				}
+				operation.Parameters = append(operation.Parameters, openAPIParameter{
+					Name:     field.key,
+					In:       source,
+					Required: hasRule(field.rules, "required"),
+					Schema:   builder.paramSchema(request.FieldByIndex(field.index).Type, field.rules),
+				})
+			}
+		}
+		operation.RequestBody = builder.requestBody(request, plan)
+	}
+
+	for status, t := range doc.responses {
+		response := openAPIResponse{Description: http.StatusText(status)}
+		if t != nil {
+			response.Content = map[string]openAPIMediaType{
+				"application/json": {Schema: builder.schema(t)},
+			}
+		}
+		operation.Responses[strconv.Itoa(status)] = response
+	}
+
+	switch {
+	case isWebSocketRoute(route):
+		operation.Responses["101"] = openAPIResponse{Description: http.StatusText(http.StatusSwitchingProtocols)}
+	case len(operation.Responses) == 0:
+		operation.Responses["200"] = openAPIResponse{Description: http.StatusText(http.StatusOK)}
+	}
+	if doc.request != nil || len(doc.responses) > 0 {
+		operation.Responses["default"] = openAPIResponse{
+			Description: "Error",
+			Content: map[string]openAPIMediaType{
+				problemContentType: {Schema: builder.schema(reflect.TypeFor[Problem]())},
+			},
+		}
+	}
+	return operation
+}
+
+func isWebSocketRoute(route *route) bool {
+	_, ok := route.endpoint.(*webSocketEndpoint)
+	return ok
+}
+
+func (plan *bindPlan) field(source, key string) (bindField, bool) {
+	if plan == nil {
+		return bindField{}, false
+	}
+	for _, field := range plan.fields {
+		if field.source == source && field.key == key {
+			return field, true
+		}
+	}
+	return bindField{}, false
+}
+
+func hasRule(rules []validationRule, name string) bool {
+	for _, rule := range rules {
+		if rule.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// requestBody describes the body fields of a bound struct, as JSON or, when
+// it has file fields, as multipart/form-data.
+func (builder *schemaBuilder) requestBody(t reflect.Type, plan *bindPlan) *openAPIRequestBody {
+	multipart := false
+	onlyBody := true
+	for _, field := range plan.fields {
+		if field.source != "" {
+			onlyBody = false
+			continue
+		}
+		if sf := t.FieldByIndex(field.index); sf.Type == uploadedFileType || sf.Type == uploadedFileSliceType {
+			multipart = true
+		}
+	}
+
+	properties := make(map[string]any)
+	var required []string
+	for _, field := range plan.fields {
+		if field.source != "" {
+			continue
+		}
+		sf := t.FieldByIndex(field.index)
+		name := field.name
+		if multipart {
+			if name = formFieldName(sf); name == "" {
+				continue
+			}
+		}
+		properties[name] = builder.fieldSchema(sf.Type, field.rules)
+		if hasRule(field.rules, "required") {
+			required = append(required, name)
+		}
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+
+	contentType := "application/json"
+	schema := jsonSchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if multipart {
+		contentType = "multipart/form-data"
+	} else if onlyBody {
+		schema = builder.schema(t)
+	}
+
+	return &openAPIRequestBody{
+		Required: len(required) > 0,
+		Content:  map[string]openAPIMediaType{contentType: {Schema: schema}},
+	}
+}
+
+func (builder *schemaBuilder) paramSchema(t reflect.Type, rules []validationRule) jsonSchema {
+	if derefType(t) == durationType {
+		schema := jsonSchema{"type": "string", "examples": []string{"1m30s"}}
+		applyRules(schema, rules)
+		return schema
+	}
+	return builder.fieldSchema(t, rules)
+}
+
+func (builder *schemaBuilder) fieldSchema(t reflect.Type, rules []validationRule) jsonSchema {
+	schema := builder.schema(t)
+	if _, isRef := schema["$ref"]; isRef && len(rules) > 0 {
+		schema = jsonSchema{"allOf": []jsonSchema{schema}}
+	}
+	applyRules(schema, rules)
+	return schema
+}
+
+var (
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+)
+
+// schema returns the JSON Schema of t, registering named structs as
+// components and referring to them by $ref.
+func (builder *schemaBuilder) schema(t reflect.Type) jsonSchema {
+	switch t {
+	case uploadedFileType:
+		return jsonSchema{"type": "string", "format": "binary"}
+	case timeType:
+		return jsonSchema{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return jsonSchema{}
+	}
+
+	if t.Kind() == reflect.Pointer {
+		return builder.schema(t.Elem())
+	}
+	if t.Kind() != reflect.Struct && t.Implements(textMarshalerType) {
+		return jsonSchema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return jsonSchema{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return jsonSchema{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64:
+		return jsonSchema{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return jsonSchema{"type": "integer", "minimum": 0}
+	case reflect.Float32:
+		return jsonSchema{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return jsonSchema{"type": "number", "format": "double"}
+	case reflect.String:
+		return jsonSchema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return jsonSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return jsonSchema{"type": "array", "items": builder.schema(t.Elem())}
+	case reflect.Map:
+		return jsonSchema{"type": "object", "additionalProperties": builder.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return builder.structSchema(t)
+		}
+		name, ok := builder.names[t]
+		if !ok {
+			name = builder.componentName(t)
+			builder.names[t] = name
+			builder.components[name] = jsonSchema{} // placeholder for recursive types
+			builder.components[name] = builder.structSchema(t)
+		}
+		return jsonSchema{"$ref": "#/components/schemas/" + name}
+	}
+	return jsonSchema{}
+}
+
+func (builder *schemaBuilder) structSchema(t reflect.Type) jsonSchema {
+	properties := make(map[string]any)
+	var required []string
+	for _, field := range bindPlanFor(t).fields {
+		if field.source != "" {
+			continue
+		}
+		properties[field.name] = builder.fieldSchema(t.FieldByIndex(field.index).Type, field.rules)
+		if hasRule(field.rules, "required") {
+			required = append(required, field.name)
+		}
+	}
+
+	schema := jsonSchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var packagePathPattern = regexp.MustCompile(`[\w./-]*\.`)
+
+// componentName turns a type name such as Page[example.com/app.User] into
+// Page_User, numbering names that collide across packages.
+func (builder *schemaBuilder) componentName(t reflect.Type) string {
+	name := packagePathPattern.ReplaceAllString(t.Name(), "")
+	name = strings.NewReplacer("[", "_", "]", "", ",", "_", " ", "", "*", "").Replace(name)
+	candidate := name
+	for i := 2; builder.taken[candidate]; i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	builder.taken[candidate] = true
+	return candidate
+}
+
+func constraintSchema(segment routeSegment) jsonSchema {
+	switch segment.constraint {
+	case "":
+		return jsonSchema{"type": "string"}
+	case "int":
+		return jsonSchema{"type": "integer"}
+	case "uint":
+		return jsonSchema{"type": "integer", "minimum": 0}
+	case "uuid":
+		return jsonSchema{"type": "string", "format": "uuid"}
+	}
+	expr, ok := paramConstraints[segment.constraint]
+	if !ok {
+		expr = segment.constraint
+	}
+	return jsonSchema{"type": "string", "pattern": "^(?:" + expr + ")$"}
+}
+
+// applyRules adds the JSON Schema keywords matching validate rules. Sizes
+// apply to the length of strings, arrays and maps and to numbers otherwise.
+func applyRules(schema jsonSchema, rules []validationRule) {
+	kind, _ := schema["type"].(string)
+	for _, rule := range rules {
+		switch rule.name {
+		case "min", "max", "len":
+			limit := rule.limit
+			var keywords []string
+			switch kind {
+			case "string":
+				keywords = []string{"minLength", "maxLength"}
+			case "array":
+				keywords = []string{"minItems", "maxItems"}
+			case "object":
+				keywords = []string{"minProperties", "maxProperties"}
+			default:
+				keywords = []string{"minimum", "maximum"}
+			}
+			switch rule.name {
+			case "min":
+				schema[keywords[0]] = limit
+			case "max":
+				schema[keywords[1]] = limit
+			case "len":
+				schema[keywords[0]] = limit
+				schema[keywords[1]] = limit
+			}
+		case "oneof":
+			options := strings.Fields(rule.param)
+			enum := make([]any, len(options))
+			for i, option := range options {
+				enum[i] = option
+				if kind == "integer" || kind == "number" {
+					if number, err := strconv.ParseFloat(option, 64); err == nil {
+						enum[i] = number
+					}
+				}
+			}
+			schema["enum"] = enum
+		case "email":
+			schema["format"] = "email"
+		case "url":
+			schema["format"] = "uri"
+		case "uuid":
+			schema["format"] = "uuid"
+		case "alpha":
+			schema["pattern"] = alphaPattern.String()
+		case "alphanum":
+			schema["pattern"] = alphanumPattern.String()
+		case "numeric":
+			schema["pattern"] = numericPattern.String()
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package gogi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type openAPIUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type openAPICreated struct {
+	ID int `json:"id"`
+}
+
+func (openAPICreated) StatusCode() int {
+	return http.StatusCreated
+}
+
+type openAPISpecPaths map[string]map[string]struct {
+	OperationID string             `json:"operationId"`
+	Parameters  []openAPIParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]any `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Schema map[string]any `json:"schema"`
+		} `json:"content"`
+	} `json:"responses"`
+}
+
+func testOpenAPIPaths(t *testing.T, server *HttpServer) openAPISpecPaths {
+	t.Helper()
+	spec, err := server.openAPISpec(OpenAPIConfig{Title: "test", Version: "1"}.withDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Paths openAPISpecPaths `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc.Paths
+}
+
+func TestOpenAPIWithTypes(t *testing.T) {
+	getUser := Handle(func(ctx context.Context, in bindValidInput) (openAPIUser, error) {
+		return openAPIUser{}, nil
+	})
+	createUser := Handle(func(ctx context.Context, in bindValidInput) (openAPICreated, error) {
+		return openAPICreated{}, nil
+	})
+	plain := HTTPHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {})
+
+	server := &HttpServer{router: newRouter()}
+	server.addRoute(nil, HTTP_GET, "/users/:id", httpHandler(getUser), WithTypes[bindValidInput, openAPIUser]())
+	server.addRoute(nil, HTTP_POST, "/users/:id", httpHandler(createUser), WithTypes[bindValidInput, openAPICreated](), WithResponse[openAPIUser](http.StatusCreated))
+	server.addRoute(nil, HTTP_GET, "/plain", httpHandler(plain))
+	paths := testOpenAPIPaths(t, server)
+
+	get := paths["/users/{id}"]["get"]
+	if get.RequestBody == nil {
+		t.Error("GET lacks the request body from In")
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].Schema["minimum"] != 1.0 {
+		t.Errorf("GET parameters %+v, want id with the validate rule", get.Parameters)
+	}
+	if ref := get.Responses["200"].Content["application/json"].Schema["$ref"]; ref != "#/components/schemas/openAPIUser" {
+		t.Errorf("GET 200 schema %v, want openAPIUser", ref)
+	}
+
+	post := paths["/users/{id}"]["post"]
+	if ref := post.Responses["201"].Content["application/json"].Schema["$ref"]; ref != "#/components/schemas/openAPIUser" {
+		t.Errorf("POST 201 schema %v, want the explicit WithResponse to win", ref)
+	}
+
+	if _, ok := paths["/plain"]["get"].Responses["200"].Content["application/json"]; ok {
+		t.Error("plain handler documented a body")
+	}
+}
+
+func TestOpenAPICatchAll(t *testing.T) {
+	server := &HttpServer{router: newRouter()}
+	files := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	server.addRoute(nil, HTTP_GET, "/files/*path", files, WithName("files"))
+	server.addRoute(nil, HTTP_GET, "/docs/*path", files)
+	server.addRoute(nil, HTTP_GET, "/docs", files, WithName("docs-index"))
+	paths := testOpenAPIPaths(t, server)
+
+	full := paths["/files/{path}"]["get"]
+	if len(full.Parameters) != 1 || !full.Parameters[0].CatchAll || full.Parameters[0].Description == "" {
+		t.Errorf("catch-all parameter %+v, want it marked and described", full.Parameters)
+	}
+	absent, ok := paths["/files"]["get"]
+	if !ok {
+		t.Fatal("missing the variant without the catch-all")
+	}
+	if absent.OperationID != "" || len(absent.Parameters) != 0 {
+		t.Errorf("absent variant %+v, want no operationId or parameters", absent)
+	}
+	if got := paths["/docs"]["get"].OperationID; got != "docs-index" {
+		t.Errorf("/docs operationId %q, want the explicit route to win", got)
+	}
+}
+
+func TestOpenAPIPage(t *testing.T) {
+	self := OpenAPIAsset{URL: "/assets/swagger-ui-bundle.js", Integrity: "sha384-abc"}
+	tests := []struct {
+		name    string
+		config  OpenAPIConfig
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "swagger defaults",
+			config:  OpenAPIConfig{UI: "swagger", Title: "<Pets>"},
+			want:    []string{"swagger-ui-dist@5.17.14/swagger-ui-bundle.js", "swagger-ui-dist@5.17.14/swagger-ui.css", "&lt;Pets&gt;", `url: "/openapi.json"`},
+			notWant: []string{"integrity="},
+		},
+		{
+			name:   "redoc defaults",
+			config: OpenAPIConfig{UI: "redoc"},
+			want:   []string{"redoc@2.1.5/bundles/redoc.standalone.js", "Redoc.init("},
+		},
+		{
+			name:    "own assets",
+			config:  OpenAPIConfig{UI: "swagger", UIScript: self, UIStylesheet: OpenAPIAsset{URL: "/assets/swagger-ui.css"}},
+			want:    []string{`<script src="/assets/swagger-ui-bundle.js" integrity="sha384-abc" crossorigin="anonymous"></script>`, `<link rel="stylesheet" href="/assets/swagger-ui.css">`},
+			notWant: []string{"cdn.jsdelivr.net"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := openAPIPage(tt.config.withDefaults())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(page, want) {
+					t.Errorf("page lacks %s:\n%s", want, page)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(page, notWant) {
+					t.Errorf("page has %s:\n%s", notWant, page)
+				}
+			}
+		})
+	}
+
+	if _, err := openAPIPage(OpenAPIConfig{UI: "rapidoc"}); err == nil {
+		t.Error("unknown UI accepted")
+	}
+}
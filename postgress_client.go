@@ -3,7 +3,9 @@ package gogi
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/dejaniskra/go-gi/internal/config"
@@ -19,11 +21,18 @@ const (
 type PostgresClient struct {
 	Writer *sql.DB
 	Reader *sql.DB
+	role   string
 }
 
-var postgresClients = make(map[string]*PostgresClient)
+var (
+	postgresClients   = make(map[string]*PostgresClient)
+	postgresClientsMu sync.Mutex
+)
 
 func GetPostgresClient(role string) (*PostgresClient, error) {
+	postgresClientsMu.Lock()
+	defer postgresClientsMu.Unlock()
+
 	if client, exists := postgresClients[role]; exists {
 		return client, nil
 	}
@@ -38,10 +47,25 @@ func GetPostgresClient(role string) (*PostgresClient, error) {
 		return nil, err
 	}
 
+	client.role = role
 	postgresClients[role] = client
 	return client, nil
 }
 
+func closePostgresClients() error {
+	postgresClientsMu.Lock()
+	defer postgresClientsMu.Unlock()
+
+	var errs []error
+	for role, client := range postgresClients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("postgres %s: %w", role, err))
+		}
+		delete(postgresClients, role)
+	}
+	return errors.Join(errs...)
+}
+
 func newPostgresClient(dbRoleConfig *config.DBRoleConfig) (*PostgresClient, error) {
 	writer, err := newPgConnection(&dbRoleConfig.Writer)
 	if err != nil {
@@ -129,14 +153,18 @@ func (c *PostgresClient) Close() error {
 	return nil
 }
 
-func (c *PostgresClient) FindOne(ctx context.Context, query string, args []any, dest ...any) error {
+func (c *PostgresClient) FindOne(ctx context.Context, query string, args []any, dest ...any) (err error) {
+	ctx, done := startDBCall(ctx, "postgres", c.role, "find_one", queryAttribute(query))
+	defer done(&err)
 	message := fmt.Sprintf("[Postgres] FindOne: %s | args=%v", query, args)
 	GetLogger().Debug(message)
 	row := c.Reader.QueryRowContext(ctx, query, args...)
 	return row.Scan(dest...)
 }
 
-func (c *PostgresClient) FindMany(ctx context.Context, query string, args []any, scanFunc func(*sql.Rows) error) error {
+func (c *PostgresClient) FindMany(ctx context.Context, query string, args []any, scanFunc func(*sql.Rows) error) (err error) {
+	ctx, done := startDBCall(ctx, "postgres", c.role, "find_many", queryAttribute(query))
+	defer done(&err)
 	message := fmt.Sprintf("[Postgres] FindMany: %s | args=%v", query, args)
 	GetLogger().Debug(message)
 	rows, err := c.Reader.QueryContext(ctx, query, args...)
@@ -153,14 +181,18 @@ func (c *PostgresClient) FindMany(ctx context.Context, query string, args []any,
 	return rows.Err()
 }
 
-func (c *PostgresClient) Exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+func (c *PostgresClient) Exec(ctx context.Context, query string, args ...any) (result sql.Result, err error) {
+	ctx, done := startDBCall(ctx, "postgres", c.role, "exec", queryAttribute(query))
+	defer done(&err)
 	message := fmt.Sprintf("[Postgres] Exec: %s | args=%v", query, args)
 	GetLogger().Debug(message)
 
 	return c.Writer.ExecContext(ctx, query, args...)
 }
 
-func (c *PostgresClient) WithTx(ctx context.Context, fn func(*sql.Tx) error) error {
+func (c *PostgresClient) WithTx(ctx context.Context, fn func(*sql.Tx) error) (err error) {
+	ctx, done := startDBCall(ctx, "postgres", c.role, "tx")
+	defer done(&err)
 	tx, err := c.Writer.BeginTx(ctx, nil)
 	if err != nil {
 		return err
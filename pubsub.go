@@ -3,8 +3,20 @@ package gogi
 import "context"
 
 type Event struct {
-	Topic   string
-	Payload []byte
+	Topic        string
+	Payload      []byte
+	TraceContext map[string]string `json:",omitempty"` // Set by Publish
+
+	ctx context.Context
+}
+
+// Context returns the context a delivered event is handled under, which
+// continues the publisher's trace.
+func (event *Event) Context() context.Context {
+	if event.ctx == nil {
+		return context.Background()
+	}
+	return event.ctx
 }
 
 type PubSub interface {
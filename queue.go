@@ -6,9 +6,21 @@ import (
 
 // Job represents a unit of work.
 type Job struct {
-	ID   string                 // Optional ID (can be generated)
-	Type string                 // e.g., "email", "image_processing"
-	Data map[string]interface{} // Arbitrary payload
+	ID           string                 // Optional ID (can be generated)
+	Type         string                 // e.g., "email", "image_processing"
+	Data         map[string]interface{} // Arbitrary payload
+	TraceContext map[string]string      `json:",omitempty"` // Set by SendJob
+
+	ctx context.Context
+}
+
+// Context returns the context a received job is processed under, which
+// continues the sender's trace.
+func (job *Job) Context() context.Context {
+	if job.ctx == nil {
+		return context.Background()
+	}
+	return job.ctx
 }
 
 // JobQueue defines the interface all queue backends must implement.
@@ -0,0 +1,424 @@
+package gogi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dejaniskra/go-gi/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	TokenBucket   RateLimitAlgorithm = "token_bucket"
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+
+	rateLimitDefaultName   = "default"
+	rateLimitRedisPrefix   = "gogi:ratelimit:"
+	rateLimitSweepInterval = time.Minute
+
+	subjectKey contextKey = "subject"
+)
+
+type RateLimitAlgorithm string
+
+// RateLimitPolicy allows Limit requests per Window. With TokenBucket, the
+// default, the bucket holds Burst tokens (default Limit) and refills evenly
+// over the window. SlidingWindow weighs the previous fixed window by how much
+// of it still overlaps the sliding one, which approximates a true sliding
+// window with constant memory per key.
+type RateLimitPolicy struct {
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+	Burst     int
+}
+
+// RateLimitResult is the outcome of taking one request from a key's budget.
+// ResetAfter is how long until the budget is fully restored and RetryAfter,
+// for denied requests, how long until one more request would be allowed.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the per-key state. Take must be atomic per key, since
+// it is called concurrently and, for shared stores, from several replicas.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// RateLimitKeyFunc picks the key a request is counted against. Returning
+// false exempts the request from this limiter.
+type RateLimitKeyFunc func(r *http.Request) (string, bool)
+
+// RateLimitConfig configures RateLimit. Name separates limiters sharing a
+// store (default "default"). Key defaults to RateLimitByIP and Store to a
+// new in-process store. If the store fails, requests are let through and the
+// error logged, unless FailClosed is set, in which case they get a 503.
+type RateLimitConfig struct {
+	RateLimitPolicy
+	Name       string
+	Key        RateLimitKeyFunc
+	Store      RateLimitStore
+	FailClosed bool
+}
+
+// RateLimit returns a middleware that answers 429 with Retry-After once a key
+// exceeds its policy. Every limited response carries RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers. It
+// panics if Limit is not positive, Window is under a millisecond or the
+// algorithm is unknown.
+func RateLimit(config RateLimitConfig) MiddlewareHandler {
+	if config.Algorithm == "" {
+		config.Algorithm = TokenBucket
+	}
+	if config.Algorithm != TokenBucket && config.Algorithm != SlidingWindow {
+		panic("unsupported rate limit algorithm " + strconv.Quote(string(config.Algorithm)))
+	}
+	if config.Limit <= 0 || config.Window < time.Millisecond {
+		panic("rate limit requires a positive Limit and a Window of at least 1ms")
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	if config.Name == "" {
+		config.Name = rateLimitDefaultName
+	}
+	if config.Key == nil {
+		config.Key = RateLimitByIP()
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+	policyHeader := fmt.Sprintf("%d;w=%d", config.Limit, ceilSeconds(config.Window))
+	if config.Algorithm == TokenBucket && config.Burst != config.Limit {
+		policyHeader += fmt.Sprintf(";burst=%d", config.Burst)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := config.Key(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := config.Store.Take(r.Context(), config.Name+":"+key, config.RateLimitPolicy)
+			if err != nil {
+				GetLogger().Warn(fmt.Sprintf("[RateLimit] %s: %v", config.Name, err))
+				if config.FailClosed {
+					writeHTTPProblem(w, r, NewHTTPError(http.StatusServiceUnavailable, "rate_limit_unavailable", "The rate limiter is unavailable."))
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+			header.Set("RateLimit-Policy", policyHeader)
+			if !result.Allowed {
+				header.Set("Retry-After", strconv.FormatInt(max(ceilSeconds(result.RetryAfter), 1), 10))
+				writeHTTPProblem(w, r, NewHTTPError(http.StatusTooManyRequests, "rate_limited", "Too many requests, retry later."))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimitByIP keys requests by client address, as resolved by
+// middleware.RealIP when it runs first.
+func RateLimitByIP() RateLimitKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return "ip:" + middleware.ClientIP(r), true
+	}
+}
+
+// RateLimitByHeader keys requests by a header such as X-API-Key. Requests
+// without it are not limited; combine with RateLimitFirst to fall back.
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value := r.Header.Get(name)
+		return "header:" + value, value != ""
+	}
+}
+
+// RateLimitByPathParam keys requests by a path parameter. Path parameters
+// are only known after routing, so use it in group or route middleware.
+func RateLimitByPathParam(name string) RateLimitKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		params, _ := r.Context().Value(pathParamsKey).(map[string]string)
+		value, ok := params[name]
+		return "param:" + value, ok && value != ""
+	}
+}
+
+// RateLimitBySubject keys requests by the authenticated subject stored with
+// ContextWithSubject. Anonymous requests are not limited.
+func RateLimitBySubject() RateLimitKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		subject, ok := SubjectFromContext(r.Context())
+		return "subject:" + subject, ok
+	}
+}
+
+// RateLimitFirst uses the first extractor that yields a key, e.g. the API
+// key header, then the client IP.
+func RateLimitFirst(keys ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) (string, bool) {
+		for _, key := range keys {
+			if value, ok := key(r); ok {
+				return value, true
+			}
+		}
+		return "", false
+	}
+}
+
+// ContextWithSubject records who the request is authenticated as.
+func ContextWithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey, subject)
+}
+
+// SubjectFromContext returns the subject stored with ContextWithSubject.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	subject, ok := ctx.Value(subjectKey).(string)
+	return subject, ok && subject != ""
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore keeps the state in process, so every replica has its
+// own budget. Idle keys are swept once their state is back to full.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type rateLimitEntry struct {
+	tokens    float64
+	last      time.Time
+	start     time.Time
+	previous  int
+	current   int
+	expiresAt time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: make(map[string]*rateLimitEntry), lastSweep: time.Now(), now: time.Now}
+}
+
+func (store *MemoryRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	now := store.now()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if now.Sub(store.lastSweep) > rateLimitSweepInterval {
+		for k, entry := range store.entries {
+			if now.After(entry.expiresAt) {
+				delete(store.entries, k)
+			}
+		}
+		store.lastSweep = now
+	}
+
+	entry, exists := store.entries[key]
+	if !exists {
+		entry = &rateLimitEntry{tokens: float64(burst(policy)), last: now}
+		store.entries[key] = entry
+	}
+	if policy.Algorithm == SlidingWindow {
+		return entry.slidingWindow(policy, now), nil
+	}
+	return entry.tokenBucket(policy, now), nil
+}
+
+func burst(policy RateLimitPolicy) int {
+	if policy.Burst > 0 {
+		return policy.Burst
+	}
+	return policy.Limit
+}
+
+func (entry *rateLimitEntry) tokenBucket(policy RateLimitPolicy, now time.Time) RateLimitResult {
+	capacity := float64(burst(policy))
+	perSecond := float64(policy.Limit) / policy.Window.Seconds()
+
+	entry.tokens = math.Min(capacity, entry.tokens+now.Sub(entry.last).Seconds()*perSecond)
+	entry.last = now
+
+	result := RateLimitResult{Limit: int(capacity)}
+	if entry.tokens >= 1 {
+		entry.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - entry.tokens) / perSecond)
+	}
+	result.Remaining = int(entry.tokens)
+	result.ResetAfter = secondsDuration((capacity - entry.tokens) / perSecond)
+	entry.expiresAt = now.Add(result.ResetAfter)
+	return result
+}
+
+func (entry *rateLimitEntry) slidingWindow(policy RateLimitPolicy, now time.Time) RateLimitResult {
+	start := now.Truncate(policy.Window)
+	switch {
+	case entry.start.Equal(start):
+	case entry.start.Equal(start.Add(-policy.Window)):
+		entry.previous, entry.current = entry.current, 0
+	default:
+		entry.previous, entry.current = 0, 0
+	}
+	entry.start = start
+
+	elapsed := now.Sub(start)
+	untilNext := policy.Window - elapsed
+	weight := float64(untilNext) / float64(policy.Window)
+	count := float64(entry.previous)*weight + float64(entry.current)
+
+	result := RateLimitResult{Limit: policy.Limit, ResetAfter: untilNext}
+	if count+1 <= float64(policy.Limit) {
+		entry.current++
+		result.Allowed = true
+		result.Remaining = int(float64(policy.Limit) - count - 1)
+	} else {
+		result.RetryAfter = untilNext
+		if entry.previous > 0 && entry.current < policy.Limit {
+			// Wait until enough of the previous window has slid out.
+			excess := count + 1 - float64(policy.Limit)
+			result.RetryAfter = min(time.Duration(excess/float64(entry.previous)*float64(policy.Window)), untilNext)
+		}
+	}
+	entry.expiresAt = start.Add(2 * policy.Window)
+	return result
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// RedisRateLimitStore shares the state between replicas through a Redis
+// writer. Each Take is a single Lua script, so it is atomic, uses the Redis
+// clock rather than the replicas' and touches one key per limiter key.
+type RedisRateLimitStore struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimitStore(client *RedisClient) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client.Writer}
+}
+
+// tokenBucketScript stores the bucket as a hash of its tokens and the time
+// they were counted, in milliseconds. It returns allowed, remaining,
+// retry-after and reset-after, the last two in milliseconds.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+// slidingWindowScript stores the current window's start and the counts of
+// the current and previous windows in one hash.
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local start = now - (now % window)
+
+local state = redis.call('HMGET', KEYS[1], 'start', 'prev', 'curr')
+local stored = tonumber(state[1])
+local prev, curr = 0, 0
+if stored == start then
+	prev, curr = tonumber(state[2]) or 0, tonumber(state[3]) or 0
+elseif stored == start - window then
+	prev = tonumber(state[3]) or 0
+end
+
+local untilNext = window - (now - start)
+local count = prev * untilNext / window + curr
+
+local allowed, remaining, retry = 0, 0, 0
+if count + 1 <= limit then
+	curr = curr + 1
+	allowed = 1
+	remaining = math.floor(limit - count - 1)
+else
+	retry = untilNext
+	if prev > 0 and curr < limit then
+		retry = math.min(math.ceil((count + 1 - limit) / prev * window), untilNext)
+	end
+end
+
+redis.call('HSET', KEYS[1], 'start', start, 'prev', prev, 'curr', curr)
+redis.call('PEXPIRE', KEYS[1], 2 * window)
+return {allowed, remaining, retry, untilNext}
+`)
+
+func (store *RedisRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	var (
+		values []int64
+		err    error
+	)
+	key = rateLimitRedisPrefix + key
+	window := policy.Window.Milliseconds()
+	if policy.Algorithm == SlidingWindow {
+		values, err = slidingWindowScript.Run(ctx, store.client, []string{key}, policy.Limit, window).Int64Slice()
+	} else {
+		rate := float64(policy.Limit) / float64(window)
+		values, err = tokenBucketScript.Run(ctx, store.client, []string{key}, burst(policy), strconv.FormatFloat(rate, 'g', -1, 64)).Int64Slice()
+	}
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(values) != 4 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+
+	limit := policy.Limit
+	if policy.Algorithm != SlidingWindow {
+		limit = burst(policy)
+	}
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,151 @@
+//go:build integration
+
+package gogi
+
+// These tests run the rate limit Lua scripts against a real Redis:
+//
+//	REDIS_ADDR=localhost:6379 go test -tags integration -run Redis .
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisRateLimitStore(t *testing.T) (*RedisRateLimitStore, *redis.Client) {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("connecting to Redis at %s: %v", addr, err)
+	}
+	return NewRedisRateLimitStore(&RedisClient{Writer: client, Reader: client}), client
+}
+
+func testRedisKey(t *testing.T) string {
+	return fmt.Sprintf("test:%s:%d", t.Name(), time.Now().UnixNano())
+}
+
+func TestRedisTokenBucket(t *testing.T) {
+	store, client := newTestRedisRateLimitStore(t)
+	ctx := context.Background()
+	key := testRedisKey(t)
+	policy := RateLimitPolicy{Algorithm: TokenBucket, Limit: 3, Window: time.Hour}
+
+	for want := 2; want >= 0; want-- {
+		result, err := store.Take(ctx, key, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != want || result.Limit != 3 {
+			t.Fatalf("got %+v, want allowed with %d remaining", result, want)
+		}
+	}
+
+	result, err := store.Take(ctx, key, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Fatal("fourth request was allowed")
+	}
+	// One token refills every 20 minutes and the bucket in about an hour.
+	if result.RetryAfter < 19*time.Minute || result.RetryAfter > 20*time.Minute {
+		t.Errorf("RetryAfter %v, want about 20m", result.RetryAfter)
+	}
+	if result.ResetAfter < 59*time.Minute || result.ResetAfter > time.Hour {
+		t.Errorf("ResetAfter %v, want about 1h", result.ResetAfter)
+	}
+
+	ttl, err := client.PTTL(ctx, rateLimitRedisPrefix+key).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl <= 0 || ttl > time.Hour {
+		t.Errorf("key TTL %v, want until the bucket is full", ttl)
+	}
+}
+
+func TestRedisTokenBucketBurst(t *testing.T) {
+	store, _ := newTestRedisRateLimitStore(t)
+	ctx := context.Background()
+	key := testRedisKey(t)
+	policy := RateLimitPolicy{Algorithm: TokenBucket, Limit: 1, Window: time.Hour, Burst: 2}
+
+	for i, allowed := range []bool{true, true, false} {
+		result, err := store.Take(ctx, key, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Allowed != allowed || result.Limit != 2 {
+			t.Fatalf("request %d: got %+v, want allowed %v with limit 2", i, result, allowed)
+		}
+	}
+}
+
+func TestRedisSlidingWindow(t *testing.T) {
+	store, client := newTestRedisRateLimitStore(t)
+	ctx := context.Background()
+	key := testRedisKey(t)
+	policy := RateLimitPolicy{Algorithm: SlidingWindow, Limit: 3, Window: time.Hour}
+
+	for want := 2; want >= 0; want-- {
+		result, err := store.Take(ctx, key, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != want {
+			t.Fatalf("got %+v, want allowed with %d remaining", result, want)
+		}
+	}
+
+	result, err := store.Take(ctx, key, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("got %+v, want denied", result)
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter != result.ResetAfter || result.ResetAfter > time.Hour {
+		t.Errorf("RetryAfter %v ResetAfter %v, want both until the next window", result.RetryAfter, result.ResetAfter)
+	}
+
+	curr, err := client.HGet(ctx, rateLimitRedisPrefix+key, "curr").Int()
+	if err != nil || curr != 3 {
+		t.Errorf("stored count %d (%v), want 3: denied requests must not count", curr, err)
+	}
+}
+
+func TestRedisSlidingWindowCarriesPreviousWindow(t *testing.T) {
+	store, client := newTestRedisRateLimitStore(t)
+	ctx := context.Background()
+	key := testRedisKey(t)
+	window := time.Hour
+	policy := RateLimitPolicy{Algorithm: SlidingWindow, Limit: 4, Window: window}
+
+	// Pretend 4 requests arrived in the previous window.
+	now, err := client.Time(ctx).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := now.UnixMilli() - now.UnixMilli()%window.Milliseconds()
+	client.HSet(ctx, rateLimitRedisPrefix+key, "start", start-window.Milliseconds(), "prev", 0, "curr", 4)
+
+	result, err := store.Take(ctx, key, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elapsed := float64(now.UnixMilli()-start) / float64(window.Milliseconds())
+	carried := 4 * (1 - elapsed)
+	if allowed := carried+1 <= 4; result.Allowed != allowed {
+		t.Fatalf("got %+v with %.2f carried over, want allowed %v", result, carried, allowed)
+	}
+}
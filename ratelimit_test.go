@@ -0,0 +1,336 @@
+package gogi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (clock *fakeClock) now() time.Time {
+	return clock.t
+}
+
+func (clock *fakeClock) advance(d time.Duration) {
+	clock.t = clock.t.Add(d)
+}
+
+// newTestRateLimitStore returns a memory store on a fake clock that starts
+// on a 10s boundary, so sliding windows line up with it.
+func newTestRateLimitStore() (*MemoryRateLimitStore, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.now
+	store.lastSweep = clock.t
+	return store, clock
+}
+
+type rateLimitStep struct {
+	advance    time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	resetAfter time.Duration
+}
+
+func runRateLimitSteps(t *testing.T, policy RateLimitPolicy, steps []rateLimitStep) {
+	t.Helper()
+	store, clock := newTestRateLimitStore()
+	for i, step := range steps {
+		clock.advance(step.advance)
+		result, err := store.Take(context.Background(), "key", policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := RateLimitResult{
+			Allowed:    step.allowed,
+			Limit:      result.Limit,
+			Remaining:  step.remaining,
+			RetryAfter: step.retryAfter,
+			ResetAfter: step.resetAfter,
+		}
+		if step.resetAfter == 0 {
+			want.ResetAfter = result.ResetAfter
+		}
+		if result != want {
+			t.Fatalf("step %d: got %+v, want %+v", i, result, want)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	policy := RateLimitPolicy{Algorithm: TokenBucket, Limit: 10, Window: 10 * time.Second}
+	var steps []rateLimitStep
+	for i := 9; i >= 0; i-- {
+		steps = append(steps, rateLimitStep{allowed: true, remaining: i})
+	}
+	steps = append(steps,
+		rateLimitStep{allowed: false, remaining: 0, retryAfter: time.Second, resetAfter: 10 * time.Second},
+		rateLimitStep{advance: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond, resetAfter: 9500 * time.Millisecond},
+		rateLimitStep{advance: 500 * time.Millisecond, allowed: true, remaining: 0, resetAfter: 10 * time.Second},
+		rateLimitStep{advance: time.Minute, allowed: true, remaining: 9, resetAfter: time.Second},
+	)
+	runRateLimitSteps(t, policy, steps)
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	policy := RateLimitPolicy{Algorithm: TokenBucket, Limit: 10, Window: 10 * time.Second, Burst: 3}
+	runRateLimitSteps(t, policy, []rateLimitStep{
+		{allowed: true, remaining: 2},
+		{allowed: true, remaining: 1},
+		{allowed: true, remaining: 0, resetAfter: 3 * time.Second},
+		{allowed: false, remaining: 0, retryAfter: time.Second},
+		{advance: 2 * time.Second, allowed: true, remaining: 1},
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	policy := RateLimitPolicy{Algorithm: SlidingWindow, Limit: 4, Window: 10 * time.Second}
+	runRateLimitSteps(t, policy, []rateLimitStep{
+		{allowed: true, remaining: 3, resetAfter: 10 * time.Second},
+		{allowed: true, remaining: 2},
+		{allowed: true, remaining: 1},
+		{allowed: true, remaining: 0},
+		// No previous window to slide out: wait for the next one.
+		{advance: 4 * time.Second, allowed: false, retryAfter: 6 * time.Second, resetAfter: 6 * time.Second},
+		// 2.5s into the next window, 75% of the previous 4 still count.
+		{advance: 8500 * time.Millisecond, allowed: true, remaining: 0, resetAfter: 7500 * time.Millisecond},
+		{allowed: false, retryAfter: 2500 * time.Millisecond, resetAfter: 7500 * time.Millisecond},
+		{advance: 2500 * time.Millisecond, allowed: true, remaining: 0, resetAfter: 5 * time.Second},
+		// Two windows later nothing carries over.
+		{advance: 25 * time.Second, allowed: true, remaining: 3},
+	})
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	store, clock := newTestRateLimitStore()
+	ctx := context.Background()
+	short := RateLimitPolicy{Limit: 10, Window: 10 * time.Second}
+	long := RateLimitPolicy{Limit: 1, Window: time.Hour}
+
+	store.Take(ctx, "short", short)
+	store.Take(ctx, "long", long)
+	clock.advance(rateLimitSweepInterval / 2)
+	store.Take(ctx, "other", short)
+	if len(store.entries) != 3 {
+		t.Fatalf("%d entries before the sweep interval, want 3", len(store.entries))
+	}
+
+	clock.advance(rateLimitSweepInterval)
+	store.Take(ctx, "new", short)
+	if _, ok := store.entries["short"]; ok {
+		t.Error("refilled key was not swept")
+	}
+	if _, ok := store.entries["other"]; ok {
+		t.Error("refilled key was not swept")
+	}
+	if _, ok := store.entries["long"]; !ok {
+		t.Error("key still refilling was swept")
+	}
+	if len(store.entries) != 2 {
+		t.Fatalf("%d entries after the sweep, want 2", len(store.entries))
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("store down")
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	store, _ := newTestRateLimitStore()
+	limited := RateLimit(RateLimitConfig{
+		RateLimitPolicy: RateLimitPolicy{Limit: 2, Window: time.Minute},
+		Key:             RateLimitByHeader("X-Client"),
+		Store:           store,
+	})(okTestHandler)
+
+	type response struct {
+		status     int
+		remaining  string
+		retryAfter string
+	}
+	want := []response{
+		{status: http.StatusOK, remaining: "1"},
+		{status: http.StatusOK, remaining: "0"},
+		{status: http.StatusTooManyRequests, remaining: "0", retryAfter: "30"},
+	}
+	for i, want := range want {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Client", "a")
+		w := httptest.NewRecorder()
+		limited.ServeHTTP(w, r)
+
+		header := w.Header()
+		got := response{status: w.Code, remaining: header.Get("RateLimit-Remaining"), retryAfter: header.Get("Retry-After")}
+		if got != want {
+			t.Fatalf("request %d: got %+v, want %+v", i, got, want)
+		}
+		if header.Get("RateLimit-Limit") != "2" || header.Get("RateLimit-Policy") != "2;w=60" || header.Get("RateLimit-Reset") == "" {
+			t.Fatalf("request %d: headers %v", i, header)
+		}
+		if w.Code == http.StatusTooManyRequests {
+			var problem Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != "rate_limited" {
+				t.Fatalf("body %s, want a rate_limited problem", w.Body.String())
+			}
+			if header.Get("RateLimit-Reset") != "60" {
+				t.Errorf("RateLimit-Reset %q, want 60", header.Get("RateLimit-Reset"))
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	limited.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("request without a key: status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitPolicyHeaderBurst(t *testing.T) {
+	handler := RateLimit(RateLimitConfig{RateLimitPolicy: RateLimitPolicy{Limit: 2, Window: time.Minute, Burst: 5}})(okTestHandler)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := w.Header().Get("RateLimit-Policy"); got != "2;w=60;burst=5" {
+		t.Fatalf("RateLimit-Policy %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Limit"); got != "5" {
+		t.Fatalf("RateLimit-Limit %q, want the burst", got)
+	}
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	tests := []struct {
+		failClosed bool
+		status     int
+	}{
+		{failClosed: false, status: http.StatusOK},
+		{failClosed: true, status: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		handler := RateLimit(RateLimitConfig{
+			RateLimitPolicy: RateLimitPolicy{Limit: 1, Window: time.Second},
+			Store:           failingRateLimitStore{},
+			FailClosed:      tt.failClosed,
+		})(okTestHandler)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != tt.status {
+			t.Errorf("FailClosed %v: status %d, want %d", tt.failClosed, w.Code, tt.status)
+		}
+	}
+}
+
+func TestRateLimitConfigErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RateLimitPolicy
+	}{
+		{name: "no limit", policy: RateLimitPolicy{Window: time.Second}},
+		{name: "no window", policy: RateLimitPolicy{Limit: 1}},
+		{name: "sub-millisecond window", policy: RateLimitPolicy{Limit: 1, Window: time.Microsecond}},
+		{name: "unknown algorithm", policy: RateLimitPolicy{Algorithm: "leaky", Limit: 1, Window: time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			RateLimit(RateLimitConfig{RateLimitPolicy: tt.policy})
+		})
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	request := func(setup func(r *http.Request) *http.Request) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:4321"
+		if setup != nil {
+			r = setup(r)
+		}
+		return r
+	}
+	withHeader := func(r *http.Request) *http.Request {
+		r.Header.Set("X-API-Key", "k1")
+		return r
+	}
+	withSubject := func(r *http.Request) *http.Request {
+		return r.WithContext(ContextWithSubject(r.Context(), "user-1"))
+	}
+	withParams := func(r *http.Request) *http.Request {
+		return r.WithContext(context.WithValue(r.Context(), pathParamsKey, map[string]string{"tenant": "acme"}))
+	}
+
+	tests := []struct {
+		name string
+		key  RateLimitKeyFunc
+		r    *http.Request
+		want string
+		ok   bool
+	}{
+		{name: "ip", key: RateLimitByIP(), r: request(nil), want: "ip:192.0.2.1", ok: true},
+		{name: "header", key: RateLimitByHeader("X-API-Key"), r: request(withHeader), want: "header:k1", ok: true},
+		{name: "header absent", key: RateLimitByHeader("X-API-Key"), r: request(nil)},
+		{name: "subject", key: RateLimitBySubject(), r: request(withSubject), want: "subject:user-1", ok: true},
+		{name: "anonymous", key: RateLimitBySubject(), r: request(nil)},
+		{name: "path param", key: RateLimitByPathParam("tenant"), r: request(withParams), want: "param:acme", ok: true},
+		{name: "path param outside routing", key: RateLimitByPathParam("tenant"), r: request(nil)},
+		{name: "first falls back", key: RateLimitFirst(RateLimitByHeader("X-API-Key"), RateLimitByIP()), r: request(nil), want: "ip:192.0.2.1", ok: true},
+		{name: "first prefers earlier", key: RateLimitFirst(RateLimitByHeader("X-API-Key"), RateLimitByIP()), r: request(withHeader), want: "header:k1", ok: true},
+		{name: "first with none", key: RateLimitFirst(RateLimitBySubject()), r: request(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.key(tt.r)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Fatalf("got %q %v, want %q %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRateLimitByPathParamScope(t *testing.T) {
+	byTenant := RateLimitConfig{
+		RateLimitPolicy: RateLimitPolicy{Limit: 1, Window: time.Minute},
+		Key:             RateLimitByPathParam("tenant"),
+	}
+
+	server := &HttpServer{router: newRouter()}
+	server.addRoute(nil, HTTP_GET, "/route/:tenant", okTestHandler, WithMiddleware(RateLimit(byTenant)))
+	server.addRoute(nil, HTTP_GET, "/global/:tenant", okTestHandler)
+	server.addMiddleware(RateLimit(byTenant))
+	handler := server.handler()
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{path: "/route/acme", status: http.StatusOK},
+		{path: "/route/acme", status: http.StatusTooManyRequests},
+		{path: "/route/other", status: http.StatusOK},
+		// Global middleware runs before routing, so there is no key.
+		{path: "/global/acme", status: http.StatusOK},
+		{path: "/global/acme", status: http.StatusOK},
+	}
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.status {
+			t.Fatalf("request %d to %s: status %d, want %d", i, tt.path, w.Code, tt.status)
+		}
+	}
+}
+
+var okTestHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
@@ -2,6 +2,7 @@ package gogi
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"sync"
@@ -39,10 +40,28 @@ func GetRedisClient(role string) (*RedisClient, error) {
 		return nil, err
 	}
 
+	client.Writer.AddHook(redisHook{role: role})
+	if client.Reader != client.Writer {
+		client.Reader.AddHook(redisHook{role: role})
+	}
 	redisClients[role] = client
 	return client, nil
 }
 
+func closeRedisClients() error {
+	redisClientsMu.Lock()
+	defer redisClientsMu.Unlock()
+
+	var errs []error
+	for role, client := range redisClients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("redis %s: %w", role, err))
+		}
+		delete(redisClients, role)
+	}
+	return errors.Join(errs...)
+}
+
 func newRedisClient(cfg *config.RedisRoleConfig) (*RedisClient, error) {
 	writer := redis.NewClient(&redis.Options{
 		Addr:     cfg.Writer.Addr,
@@ -83,9 +102,64 @@ func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, ex
 	return r.Writer.Set(ctx, key, value, expiration).Err()
 }
 
+func (r *RedisClient) Ping(ctx context.Context) error {
+	if err := r.Writer.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("writer redis ping failed: %w", err)
+	}
+	if r.Reader != r.Writer {
+		if err := r.Reader.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("reader redis ping failed: %w", err)
+		}
+	}
+	return nil
+}
+
 func (r *RedisClient) Close() error {
 	if r.Reader != r.Writer {
 		r.Reader.Close()
 	}
 	return r.Writer.Close()
 }
+
+// redisHook traces and times every command sent through the client,
+// including those issued directly on Writer and Reader. A missing key is not
+// an error.
+type redisHook struct {
+	role string
+}
+
+func (h redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, done := h.start(ctx, cmd.Name())
+		err := next(ctx, cmd)
+		done(err)
+		return err
+	}
+}
+
+func (h redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, done := h.start(ctx, "pipeline")
+		err := next(ctx, cmds)
+		done(err)
+		return err
+	}
+}
+
+func (h redisHook) start(ctx context.Context, command string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := startRedisSpan(ctx, h.role, command)
+	return ctx, func(err error) {
+		redisCallDuration.ObserveSince(start, h.role, command)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			redisCallErrors.Inc(h.role, command)
+			endSpan(span, err)
+			return
+		}
+		span.End()
+	}
+}
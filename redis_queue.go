@@ -19,7 +19,9 @@ func NewRedisQueue(redisClient *RedisClient, name string) *RedisJobQueue {
 	}
 }
 
-func (r *RedisJobQueue) SendJob(ctx context.Context, job *Job) error {
+func (r *RedisJobQueue) SendJob(ctx context.Context, job *Job) (err error) {
+	ctx, job, done := startJobSend(ctx, "redis", r.name, job)
+	defer done(&err)
 	data, err := json.Marshal(job)
 	if err != nil {
 		return err
@@ -33,13 +35,15 @@ func (r *RedisJobQueue) ReceiveJobs(ctx context.Context, handler func(*Job) erro
 		if err == redis.Nil {
 			return nil
 		} else if err != nil {
+			observeQueueError("redis", r.name, "receive", err)
 			return err
 		}
 		var job Job
 		if err := json.Unmarshal([]byte(result), &job); err != nil {
+			observeQueueError("redis", r.name, "receive", err)
 			return err
 		}
-		if err := handler(&job); err != nil {
+		if err := processJob(ctx, "redis", r.name, &job, handler); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,266 @@
+package gogi
+
+import (
+	"bufio"
+	"context"
+	"maps"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dejaniskra/go-gi/internal/config"
+)
+
+const routeInfoKey contextKey = "routeInfo"
+
+// RouteInfo describes a registered route. Path is the pattern the route was
+// registered with, including any group prefix. A zero MaxBodySize means
+// http.max_body_bytes applies.
+type RouteInfo struct {
+	Method      string
+	Path        string
+	Name        string
+	Timeout     time.Duration
+	MaxBodySize int64
+	Metadata    map[string]any
+}
+
+type RouteOption func(*route)
+
+// WithMiddleware wraps only this route, inside any group and global middleware.
+func WithMiddleware(mws ...func(http.Handler) http.Handler) RouteOption {
+	return func(route *route) {
+		route.middlewares = append(route.middlewares, mws...)
+	}
+}
+
+func WithName(name string) RouteOption {
+	return func(route *route) {
+		route.Name = name
+	}
+}
+
+// WithTimeout gives the request context a deadline of timeout and answers
+// 503 with a "timeout" problem if the handler has written nothing by then.
+// Responses are not buffered, so streams can flush as they go; once a
+// response has started the deadline only cancels the context. It cannot be
+// used on WebSocket routes.
+//
+// middleware.Timeout answers the same 503 but buffers the response and runs
+// the handler on its own goroutine, so it replies on time even when the
+// handler ignores its context, at the cost of streaming and hijacking.
+// WithTimeout makes the opposite trade and waits for the handler to return;
+// pick per route, or globally with middleware.Timeout and a Skip.
+func WithTimeout(timeout time.Duration) RouteOption {
+	return func(route *route) {
+		route.Timeout = timeout
+	}
+}
+
+func WithMetadata(key string, value any) RouteOption {
+	return func(route *route) {
+		route.Metadata[key] = value
+	}
+}
+
+// RouteFromContext returns a copy of the route that matched the current
+// request. Metadata is copied too, but its values are shared.
+func RouteFromContext(ctx context.Context) (*RouteInfo, bool) {
+	info, ok := ctx.Value(routeInfoKey).(*RouteInfo)
+	if !ok {
+		return nil, false
+	}
+	clone := *info
+	clone.Metadata = maps.Clone(info.Metadata)
+	return &clone, true
+}
+
+type route struct {
+	RouteInfo
+	group       *RouteGroup
+	middlewares []func(http.Handler) http.Handler
+	webSocket   *WebSocketConfig
+	multipart   *MultipartConfig
+	static      *StaticConfig
+	openAPI     *routeDoc
+	endpoint    http.Handler
+	handler     http.Handler
+	bodyLimit   int64
+	metrics     bool
+	tracing     bool
+}
+
+func (route *route) build() {
+	route.bodyLimit = route.MaxBodySize
+	if route.bodyLimit == 0 {
+		route.bodyLimit = int64(*config.GetConfig().Http.MaxBodyBytes)
+	}
+
+	handler := route.endpoint
+	for i := len(route.middlewares) - 1; i >= 0; i-- {
+		handler = route.middlewares[i](handler)
+	}
+	if route.Timeout > 0 {
+		handler = withDeadline(handler, route.Timeout)
+	}
+	for group := route.group; group != nil; group = group.parent {
+		for i := len(group.middlewares) - 1; i >= 0; i-- {
+			handler = group.middlewares[i](handler)
+		}
+	}
+	route.handler = handler
+}
+
+func (route *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serve := route.serve
+	if route.tracing {
+		next := serve
+		serve = func(w http.ResponseWriter, r *http.Request) { route.serveTraced(w, r, next) }
+	}
+	if route.metrics {
+		route.serveInstrumented(w, r, serve)
+		return
+	}
+	serve(w, r)
+}
+
+func (route *route) serve(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), routeInfoKey, &route.RouteInfo)
+	if route.multipart != nil {
+		ctx = context.WithValue(ctx, multipartConfigKey, route.multipart)
+	}
+	r = r.WithContext(ctx)
+	if err := limitRequestBody(w, r, route.bodyLimit); err != nil {
+		writeHTTPProblem(w, r, err)
+		return
+	}
+	route.handler.ServeHTTP(w, r)
+}
+
+// withDeadline runs next on the serving goroutine under a context deadline.
+// If the deadline passes before the response has started, the client gets
+// a 503 and the handler's later writes fail with http.ErrHandlerTimeout.
+func withDeadline(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		dw := &deadlineWriter{ResponseWriter: w, header: w.Header().Clone(), req: r}
+		expired := make(chan struct{})
+		stop := context.AfterFunc(ctx, func() {
+			defer close(expired)
+			dw.mu.Lock()
+			defer dw.mu.Unlock()
+			dw.checkDeadline()
+		})
+
+		next.ServeHTTP(dw, r)
+		if !stop() {
+			<-expired
+		}
+	})
+}
+
+// deadlineWriter passes writes, flushes and hijacks straight through. The
+// handler's headers are kept apart until the response starts so that a 503
+// written from the deadline's goroutine never races with them.
+type deadlineWriter struct {
+	http.ResponseWriter
+	header http.Header
+	req    *http.Request
+
+	mu      sync.Mutex
+	started bool
+	expired bool
+}
+
+func (dw *deadlineWriter) Header() http.Header {
+	return dw.header
+}
+
+func (dw *deadlineWriter) WriteHeader(status int) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.checkDeadline()
+	if dw.expired {
+		return
+	}
+	dw.start()
+	dw.ResponseWriter.WriteHeader(status)
+}
+
+func (dw *deadlineWriter) Write(p []byte) (int, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.checkDeadline()
+	if dw.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+	dw.start()
+	return dw.ResponseWriter.Write(p)
+}
+
+func (dw *deadlineWriter) Flush() {
+	dw.FlushError()
+}
+
+func (dw *deadlineWriter) FlushError() error {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.checkDeadline()
+	if dw.expired {
+		return http.ErrHandlerTimeout
+	}
+	dw.start()
+	return http.NewResponseController(dw.ResponseWriter).Flush()
+}
+
+func (dw *deadlineWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.checkDeadline()
+	if dw.expired {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	conn, rw, err := http.NewResponseController(dw.ResponseWriter).Hijack()
+	if err == nil {
+		dw.started = true
+	}
+	return conn, rw, err
+}
+
+func (dw *deadlineWriter) Unwrap() http.ResponseWriter {
+	return dw.ResponseWriter
+}
+
+// start copies the handler's headers out on the first write. The caller
+// holds mu.
+func (dw *deadlineWriter) start() {
+	if dw.started {
+		return
+	}
+	dw.started = true
+	dst := dw.ResponseWriter.Header()
+	clear(dst)
+	for key, values := range dw.header {
+		dst[key] = values
+	}
+}
+
+// checkDeadline answers 503 once the deadline has passed, unless the
+// response already started. Writes call it too, since a handler woken by
+// the deadline may write before the AfterFunc goroutine runs. The caller
+// holds mu.
+func (dw *deadlineWriter) checkDeadline() {
+	if dw.started || dw.expired || dw.req.Context().Err() != context.DeadlineExceeded {
+		return
+	}
+	dw.expired = true
+	writeHTTPProblem(dw.ResponseWriter, dw.req, NewHTTPError(http.StatusServiceUnavailable, "timeout", "The request took too long to process."))
+}
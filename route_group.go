@@ -0,0 +1,48 @@
+package gogi
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// RouteGroup registers routes under a shared path prefix. Middleware added
+// to a group wraps only the routes registered on it and its nested groups.
+type RouteGroup struct {
+	httpServer  *HttpServer
+	parent      *RouteGroup
+	prefix      string
+	middlewares []func(http.Handler) http.Handler
+}
+
+func (group *RouteGroup) Group(prefix string) *RouteGroup {
+	return &RouteGroup{
+		httpServer: group.httpServer,
+		parent:     group,
+		prefix:     group.fullPath(prefix),
+	}
+}
+
+func (group *RouteGroup) AddRoute(method HTTPMethod, path string, handler HTTPHandler, opts ...RouteOption) {
+	group.httpServer.addRoute(group, method, path, httpHandler(handler), opts...)
+}
+
+func (group *RouteGroup) AddWebSocket(path string, handler WebSocketHandler, opts ...RouteOption) {
+	group.httpServer.addWebSocket(group, path, handler, opts...)
+}
+
+// Static serves fsys under prefix within the group; see Application.Static.
+func (group *RouteGroup) Static(prefix string, fsys fs.FS, opts ...RouteOption) {
+	group.httpServer.addStatic(group, prefix, fsys, opts...)
+}
+
+func (group *RouteGroup) AddMiddleware(mw func(http.Handler) http.Handler) {
+	group.middlewares = append(group.middlewares, mw)
+}
+
+func (group *RouteGroup) fullPath(path string) string {
+	if group == nil {
+		return path
+	}
+	return strings.TrimRight(group.prefix, "/") + "/" + strings.TrimLeft(path, "/")
+}
@@ -0,0 +1,96 @@
+package gogi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// tagMiddleware records its name in the X-Chain response header.
+func tagMiddleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouteGroupPaths(t *testing.T) {
+	tests := []struct {
+		prefixes []string // outermost group first
+		path     string
+		want     string
+	}{
+		{prefixes: []string{"/api"}, path: "/users", want: "/api/users"},
+		{prefixes: []string{"/api/"}, path: "/users", want: "/api/users"},
+		{prefixes: []string{"/api"}, path: "users", want: "/api/users"},
+		{prefixes: []string{"api/"}, path: "users/", want: "/api/users"},
+		{prefixes: []string{"/api"}, path: "/", want: "/api"},
+		{prefixes: []string{"/api"}, path: "", want: "/api"},
+		{prefixes: []string{"/api", "/v1"}, path: "/users/:id", want: "/api/v1/users/:id"},
+		{prefixes: []string{"/api/", "/v1/"}, path: "/users", want: "/api/v1/users"},
+		{prefixes: []string{"/api", "v1", "admin/"}, path: "stats", want: "/api/v1/admin/stats"},
+		{prefixes: []string{"/api", ""}, path: "/users", want: "/api/users"},
+		{prefixes: []string{"", "/"}, path: "/users", want: "/users"},
+		{prefixes: []string{"/"}, path: "/", want: "/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			server := &HttpServer{router: newRouter()}
+			group := &RouteGroup{httpServer: server, prefix: tt.prefixes[0]}
+			for _, prefix := range tt.prefixes[1:] {
+				group = group.Group(prefix)
+			}
+			group.AddRoute(HTTP_GET, tt.path, func(req *HTTPServerRequest, res *HTTPServerResponse) {})
+
+			if got := server.routes[0].Path; got != tt.want {
+				t.Fatalf("groups %q with %q registered %q, want %q", tt.prefixes, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteGroupMiddlewareScope(t *testing.T) {
+	server := &HttpServer{router: newRouter()}
+	ok := func(req *HTTPServerRequest, res *HTTPServerResponse) { res.StatusCode = http.StatusOK }
+
+	api := &RouteGroup{httpServer: server, prefix: "/api"}
+	api.AddMiddleware(tagMiddleware("api"))
+	v1 := api.Group("/v1")
+	v1.AddMiddleware(tagMiddleware("v1"))
+	v2 := api.Group("/v2")
+	admin := &RouteGroup{httpServer: server, prefix: "/admin"}
+	admin.AddMiddleware(tagMiddleware("admin"))
+
+	server.addRoute(nil, HTTP_GET, "/health", httpHandler(ok))
+	api.AddRoute(HTTP_GET, "/info", ok)
+	v1.AddRoute(HTTP_GET, "/users", ok, WithMiddleware(tagMiddleware("route")))
+	v2.AddRoute(HTTP_GET, "/users", ok)
+	admin.AddRoute(HTTP_GET, "/stats", ok)
+	// Middleware added after a route still applies to it.
+	v2.AddMiddleware(tagMiddleware("v2"))
+	handler := server.handler()
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "/health"},
+		{path: "/api/info", want: []string{"api"}},
+		{path: "/api/v1/users", want: []string{"api", "v1", "route"}},
+		{path: "/api/v2/users", want: []string{"api", "v2"}},
+		{path: "/admin/stats", want: []string{"admin"}},
+		{path: "/api/missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if got := w.Header().Values("X-Chain"); !slices.Equal(got, tt.want) {
+				t.Fatalf("middleware chain %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
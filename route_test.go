@@ -0,0 +1,253 @@
+package gogi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dejaniskra/go-gi/middleware"
+)
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (recorder *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	recorder.hijacked = true
+	return nil, nil, nil
+}
+
+func TestWithDeadline(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(t *testing.T, w http.ResponseWriter, r *http.Request)
+		status  int
+		body    string
+	}{
+		{
+			name: "fast handler",
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Handler", "yes")
+				w.Write([]byte("ok"))
+			},
+			status: http.StatusOK,
+			body:   "ok",
+		},
+		{
+			name: "slow handler",
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				time.Sleep(10 * time.Millisecond)
+				w.Header().Set("X-Handler", "late")
+				if _, err := w.Write([]byte("late")); !errors.Is(err, http.ErrHandlerTimeout) {
+					t.Errorf("late write error %v, want http.ErrHandlerTimeout", err)
+				}
+			},
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			name: "stream flushed before deadline",
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("first "))
+				if err := http.NewResponseController(w).Flush(); err != nil {
+					t.Errorf("flush: %v", err)
+				}
+				<-r.Context().Done()
+				if !errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+					t.Errorf("context error %v, want deadline exceeded", r.Context().Err())
+				}
+				w.Write([]byte("last"))
+			},
+			status: http.StatusOK,
+			body:   "first last",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := withDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handler(t, w, r)
+			}), 20*time.Millisecond)
+
+			w := httptest.NewRecorder()
+			w.Header().Set("X-Outer", "kept")
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if w.Header().Get("X-Outer") != "kept" {
+				t.Error("outer header lost")
+			}
+			if tt.status == http.StatusServiceUnavailable {
+				var problem Problem
+				if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != "timeout" {
+					t.Fatalf("body %s, want a timeout problem", w.Body.String())
+				}
+				if w.Header().Get("X-Handler") != "" {
+					t.Error("late header reached the response")
+				}
+				return
+			}
+			if w.Body.String() != tt.body {
+				t.Fatalf("body %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestWithDeadlineHijack(t *testing.T) {
+	handler := withDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := http.NewResponseController(w).Hijack(); err != nil {
+			t.Errorf("hijack: %v", err)
+		}
+		<-r.Context().Done()
+	}), 10*time.Millisecond)
+
+	w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if !w.hijacked {
+		t.Fatal("hijack did not reach the underlying writer")
+	}
+	if w.Body.Len() != 0 {
+		t.Fatalf("wrote %q to a hijacked connection", w.Body.String())
+	}
+}
+
+func TestWebSocketRouteRejectsTimeout(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	server := &HttpServer{router: newRouter()}
+	server.addWebSocket(nil, "/ws", func(req *HTTPServerRequest, conn *WebSocketConn) {}, WithTimeout(time.Second))
+}
+
+func TestRouteFromContextReturnsCopy(t *testing.T) {
+	server := &HttpServer{router: newRouter()}
+	route := server.addRoute(nil, HTTP_GET, "/items", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, ok := RouteFromContext(r.Context())
+		if !ok {
+			t.Fatal("no route in context")
+		}
+		info.Name = "changed"
+		info.Metadata["owner"] = "changed"
+	}), WithName("items"), WithMetadata("owner", "team-a"))
+	handler := server.handler()
+
+	for range 2 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	}
+	if route.Name != "items" || route.Metadata["owner"] != "team-a" {
+		t.Fatalf("route changed to %q %v", route.Name, route.Metadata)
+	}
+	if _, ok := RouteFromContext(context.Background()); ok {
+		t.Fatal("found a route outside a request")
+	}
+}
+
+// TestTimeoutImplementations runs the same handlers under WithTimeout's
+// withDeadline and middleware.Timeout. They agree on handlers that finish
+// or honour their context, and differ where documented: withDeadline lets
+// a stream flush past the deadline and waits for a handler that ignores
+// its context, middleware.Timeout buffers and answers on time.
+func TestTimeoutImplementations(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	type outcome struct {
+		status int
+		body   string // "timeout" expects the timeout problem
+	}
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		deadline       outcome
+		middleware     outcome
+		middlewareFast bool // middleware.Timeout must answer before the handler returns
+	}{
+		{
+			name: "fast handler",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("created"))
+			},
+			deadline:   outcome{status: http.StatusCreated, body: "created"},
+			middleware: outcome{status: http.StatusCreated, body: "created"},
+		},
+		{
+			name: "slow handler honouring its context",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				w.Header().Set("X-Late", "yes")
+				w.Write([]byte("late"))
+			},
+			deadline:   outcome{status: http.StatusServiceUnavailable, body: "timeout"},
+			middleware: outcome{status: http.StatusServiceUnavailable, body: "timeout"},
+		},
+		{
+			name: "handler ignoring its context",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(10 * timeout)
+				w.Write([]byte("late"))
+			},
+			deadline:       outcome{status: http.StatusServiceUnavailable, body: "timeout"},
+			middleware:     outcome{status: http.StatusServiceUnavailable, body: "timeout"},
+			middlewareFast: true,
+		},
+		{
+			name: "stream flushed before the deadline",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("first "))
+				http.NewResponseController(w).Flush()
+				<-r.Context().Done()
+				w.Write([]byte("last"))
+			},
+			deadline:   outcome{status: http.StatusOK, body: "first last"},
+			middleware: outcome{status: http.StatusServiceUnavailable, body: "timeout"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			implementations := []struct {
+				name    string
+				handler http.Handler
+				want    outcome
+				fast    bool
+			}{
+				{name: "WithTimeout", handler: withDeadline(tt.handler, timeout), want: tt.deadline},
+				{name: "middleware.Timeout", handler: middleware.Timeout(middleware.TimeoutConfig{Timeout: timeout})(tt.handler), want: tt.middleware, fast: tt.middlewareFast},
+			}
+			for _, impl := range implementations {
+				w := httptest.NewRecorder()
+				start := time.Now()
+				impl.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+				elapsed := time.Since(start)
+
+				if w.Code != impl.want.status {
+					t.Fatalf("%s: status %d, want %d", impl.name, w.Code, impl.want.status)
+				}
+				if impl.want.body == "timeout" {
+					var problem Problem
+					if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != "timeout" {
+						t.Errorf("%s: body %s, want a timeout problem", impl.name, w.Body.String())
+					}
+					if w.Header().Get("Content-Type") != problemContentType || w.Header().Get("X-Late") != "" {
+						t.Errorf("%s: headers %v, want only the problem's", impl.name, w.Header())
+					}
+				} else if w.Body.String() != impl.want.body {
+					t.Errorf("%s: body %q, want %q", impl.name, w.Body.String(), impl.want.body)
+				}
+				if impl.fast && elapsed >= 10*timeout {
+					t.Errorf("%s: answered after %v, want before the handler returned", impl.name, elapsed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package gogi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type contextKey string
+
+const pathParamsKey contextKey = "pathParams"
+
+type nodeKind uint8
+
+const (
+	nodeStatic nodeKind = iota
+	nodeParam
+	nodeCatchAll
+)
+
+// paramConstraints are the named shortcuts accepted inside :name<...>.
+// Anything else between the angle brackets is compiled as a regular
+// expression that must match the whole segment.
+var paramConstraints = map[string]string{
+	"int":   `-?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"alpha": `[A-Za-z]+`,
+	"alnum": `[A-Za-z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
+// node is a vertex of a compressed radix tree. Static nodes hold a byte
+// prefix that is shared by all of their descendants, param nodes match a
+// single path segment and record it under their name, catch-all nodes
+// match the remainder of the path.
+type node struct {
+	kind       nodeKind
+	prefix     string // static text for static nodes, parameter name otherwise
+	constraint string
+	matcher    *regexp.Regexp
+	static     []*node
+	indices    []byte  // first byte of each static child, same order as static
+	params     []*node // constrained params first, then in registration order
+	catchAll   *node
+	handler    http.Handler
+	pattern    string
+	implicit   bool // registered only because optional segments may be absent
+}
+
+// routeSegment is one slash-separated piece of a route pattern:
+// "users", ":id", ":id<int>", ":page?" or "*filepath".
+type routeSegment struct {
+	kind       nodeKind
+	value      string
+	constraint string
+	matcher    *regexp.Regexp
+	optional   bool
+}
+
+type pathParam struct {
+	key   string
+	value string
+}
+
+// router keeps one radix tree per HTTP method. Lookups prefer static
+// segments over parameters and backtrack when a more specific branch
+// does not lead to a registered route, so the result never depends on
+// registration order.
+type router struct {
+	trees   map[string]*node
+	metrics bool // record unmatched requests, set by HttpServer.handler
+}
+
+func newRouter() *router {
+	return &router{
+		trees: make(map[string]*node),
+	}
+}
+
+func (rt *router) add(method, pattern string, handler http.Handler) {
+	pattern = cleanPath(pattern)
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("route %s %s: %v", method, pattern, err))
+	}
+
+	root := rt.trees[method]
+	if root == nil {
+		root = &node{}
+		rt.trees[method] = root
+	}
+
+	n := root.insertRoute(segments)
+	if n.handler != nil && !n.implicit {
+		panic(fmt.Sprintf("route %s %s: conflicts with %s", method, pattern, n.pattern))
+	}
+	n.handler, n.pattern, n.implicit = handler, pattern, false
+
+	// Optional trailing segments and catch-alls also match when absent, so
+	// the route is registered for every prefix that may end the path, unless
+	// another route claims that prefix explicitly.
+	required := len(segments)
+	for required > 0 && (segments[required-1].optional || segments[required-1].kind == nodeCatchAll) {
+		required--
+	}
+	for end := len(segments) - 1; end >= required; end-- {
+		n := root.insertRoute(segments[:end])
+		if n.handler == nil || n.implicit {
+			n.handler, n.pattern, n.implicit = handler, pattern, true
+		}
+	}
+}
+
+func parsePattern(pattern string) ([]routeSegment, error) {
+	parts := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	segments := make([]routeSegment, 0, len(parts))
+	names := make(map[string]bool)
+
+	for i, part := range parts {
+		var segment routeSegment
+		switch {
+		case strings.HasPrefix(part, ":"):
+			segment.kind = nodeParam
+			segment.value = part[1:]
+			if strings.HasSuffix(segment.value, "?") {
+				segment.optional = true
+				segment.value = strings.TrimSuffix(segment.value, "?")
+			}
+			if open := strings.IndexByte(segment.value, '<'); open >= 0 {
+				if !strings.HasSuffix(segment.value, ">") {
+					return nil, fmt.Errorf("unterminated constraint in %q", part)
+				}
+				segment.constraint = segment.value[open+1 : len(segment.value)-1]
+				segment.value = segment.value[:open]
+				if segment.constraint == "" {
+					return nil, fmt.Errorf("empty constraint in %q", part)
+				}
+				expr, ok := paramConstraints[segment.constraint]
+				if !ok {
+					expr = segment.constraint
+				}
+				matcher, err := regexp.Compile("^(?:" + expr + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("invalid constraint in %q: %w", part, err)
+				}
+				segment.matcher = matcher
+			}
+		case strings.HasPrefix(part, "*"):
+			segment.kind = nodeCatchAll
+			segment.value = part[1:]
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("catch-all %q must be the last segment", part)
+			}
+		default:
+			segment.value = part
+			if len(segments) > 0 && segments[len(segments)-1].optional {
+				return nil, fmt.Errorf("optional parameters must be trailing segments")
+			}
+			segments = append(segments, segment)
+			continue
+		}
+
+		if segment.value == "" {
+			return nil, fmt.Errorf("path parameter %q must have a name", part)
+		}
+		if names[segment.value] {
+			return nil, fmt.Errorf("duplicate path parameter %q", segment.value)
+		}
+		names[segment.value] = true
+		if !segment.optional && len(segments) > 0 && segments[len(segments)-1].optional {
+			return nil, fmt.Errorf("optional parameters must be trailing segments")
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
+
+func (n *node) insertRoute(segments []routeSegment) *node {
+	root := n
+	static := ""
+	for _, segment := range segments {
+		switch segment.kind {
+		case nodeStatic:
+			static += "/" + segment.value
+		case nodeParam:
+			n = n.insertStatic(static + "/")
+			n = n.insertParam(segment)
+			static = ""
+		case nodeCatchAll:
+			n = n.insertStatic(static + "/")
+			n = n.insertCatchAll(segment.value)
+			static = ""
+		}
+	}
+	if n == root && static == "" {
+		static = "/"
+	}
+	return n.insertStatic(static)
+}
+
+func (rt *router) find(method, path string) (*node, map[string]string) {
+	root := rt.trees[method]
+	if root == nil {
+		return nil, nil
+	}
+
+	var params []pathParam
+	n := root.match(cleanPath(path), &params)
+	if n == nil {
+		return nil, nil
+	}
+
+	values := make(map[string]string, len(params))
+	for _, p := range params {
+		values[p.key] = p.value
+	}
+	return n, values
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n, params := rt.find(r.Method, r.URL.Path)
+	if n == nil {
+		if rt.metrics {
+			observeRequest(w, r, metricsMethod(r.Method), metricsUnmatchedRoute, false, rt.serveUnmatched)
+			return
+		}
+		rt.serveUnmatched(w, r)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), pathParamsKey, params)
+	n.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// serveUnmatched answers a request no route matched: 404 when no method
+// has a route for the path, 405 with Allow otherwise, and 204 with Allow
+// for OPTIONS.
+func (rt *router) serveUnmatched(w http.ResponseWriter, r *http.Request) {
+	allowed := rt.allowed(r.URL.Path)
+	if len(allowed) == 0 {
+		writeHTTPProblem(w, r, NewHTTPError(http.StatusNotFound, "not_found", "No route matches the request path."))
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeHTTPProblem(w, r, NewHTTPError(http.StatusMethodNotAllowed, "method_not_allowed", fmt.Sprintf("%s is not allowed on this path.", r.Method)))
+}
+
+// allowed lists, in sorted order, every method with a route matching path.
+// OPTIONS is always included since the router answers it automatically
+// when no explicit OPTIONS route exists.
+func (rt *router) allowed(path string) []string {
+	path = cleanPath(path)
+
+	var methods []string
+	for method, root := range rt.trees {
+		var params []pathParam
+		if root.match(path, &params) != nil {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+
+	if !slices.Contains(methods, http.MethodOptions) {
+		methods = append(methods, http.MethodOptions)
+	}
+	slices.Sort(methods)
+	return methods
+}
+
+func (n *node) insertStatic(path string) *node {
+	for path != "" {
+		child := n.staticChild(path[0])
+		if child == nil {
+			child = &node{kind: nodeStatic, prefix: path}
+			n.static = append(n.static, child)
+			n.indices = append(n.indices, path[0])
+			return child
+		}
+
+		l := commonPrefix(path, child.prefix)
+		if l < len(child.prefix) {
+			split := *child
+			split.prefix = child.prefix[l:]
+			*child = node{
+				kind:    nodeStatic,
+				prefix:  child.prefix[:l],
+				static:  []*node{&split},
+				indices: []byte{split.prefix[0]},
+			}
+		}
+
+		path = path[l:]
+		n = child
+	}
+	return n
+}
+
+func (n *node) insertParam(segment routeSegment) *node {
+	for _, child := range n.params {
+		if child.prefix == segment.value && child.constraint == segment.constraint {
+			return child
+		}
+	}
+
+	child := &node{
+		kind:       nodeParam,
+		prefix:     segment.value,
+		constraint: segment.constraint,
+		matcher:    segment.matcher,
+	}
+
+	// Keep constrained params ahead of unconstrained ones so the more
+	// specific match wins regardless of registration order.
+	i := len(n.params)
+	if child.constraint != "" {
+		for i > 0 && n.params[i-1].constraint == "" {
+			i--
+		}
+	}
+	n.params = slices.Insert(n.params, i, child)
+	return child
+}
+
+func (n *node) insertCatchAll(name string) *node {
+	if n.catchAll == nil {
+		n.catchAll = &node{kind: nodeCatchAll, prefix: name}
+	} else if n.catchAll.prefix != name {
+		panic(fmt.Sprintf("catch-all %q conflicts with existing catch-all %q", name, n.catchAll.prefix))
+	}
+	return n.catchAll
+}
+
+func (n *node) staticChild(b byte) *node {
+	for i, c := range n.indices {
+		if c == b {
+			return n.static[i]
+		}
+	}
+	return nil
+}
+
+// match resolves path against the subtree below n, n's own prefix having
+// already been consumed.
+func (n *node) match(path string, params *[]pathParam) *node {
+	if path == "" {
+		if n.handler != nil {
+			return n
+		}
+		return nil
+	}
+
+	if child := n.staticChild(path[0]); child != nil && strings.HasPrefix(path, child.prefix) {
+		if found := child.match(path[len(child.prefix):], params); found != nil {
+			return found
+		}
+	}
+
+	if len(n.params) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			segment := path[:end]
+			for _, child := range n.params {
+				if child.matcher != nil && !child.matcher.MatchString(segment) {
+					continue
+				}
+				*params = append(*params, pathParam{key: child.prefix, value: segment})
+				if found := child.match(path[end:], params); found != nil {
+					return found
+				}
+				*params = (*params)[:len(*params)-1]
+			}
+		}
+	}
+
+	if n.catchAll != nil && n.catchAll.handler != nil {
+		*params = append(*params, pathParam{key: n.catchAll.prefix, value: path})
+		return n.catchAll
+	}
+
+	return nil
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// cleanPath normalises a pattern or request path so that leading and
+// trailing slashes do not affect matching.
+func cleanPath(path string) string {
+	trimmed := strings.Trim(path, "/")
+	if len(trimmed)+1 == len(path) && path[0] == '/' {
+		return path
+	}
+	return "/" + trimmed
+}
@@ -0,0 +1,219 @@
+package gogi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newTestRouter(patterns ...string) *router {
+	rt := newRouter()
+	for _, pattern := range patterns {
+		rt.add(http.MethodGet, pattern, http.NotFoundHandler())
+	}
+	return rt
+}
+
+func TestRouterFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     string
+		params   map[string]string
+	}{
+		{name: "static beats param", patterns: []string{"/users/:id", "/users/me"}, path: "/users/me", want: "/users/me", params: map[string]string{}},
+		{name: "param when static differs", patterns: []string{"/users/me", "/users/:id"}, path: "/users/42", want: "/users/:id", params: map[string]string{"id": "42"}},
+		{name: "static prefix is not a segment", patterns: []string{"/users/me", "/users/:id"}, path: "/users/meta", want: "/users/:id", params: map[string]string{"id": "meta"}},
+		{name: "backtrack from static", patterns: []string{"/users/me/settings", "/users/:id/posts"}, path: "/users/me/posts", want: "/users/:id/posts", params: map[string]string{"id": "me"}},
+		{name: "backtrack between params", patterns: []string{"/a/:x<int>/b", "/a/:y/c"}, path: "/a/1/c", want: "/a/:y/c", params: map[string]string{"y": "1"}},
+		{name: "constrained beats unconstrained", patterns: []string{"/items/:slug", "/items/:id<int>"}, path: "/items/42", want: "/items/:id<int>", params: map[string]string{"id": "42"}},
+		{name: "unconstrained when constraint fails", patterns: []string{"/items/:id<int>", "/items/:slug"}, path: "/items/abc", want: "/items/:slug", params: map[string]string{"slug": "abc"}},
+		{name: "constraint without fallback", patterns: []string{"/items/:id<uint>"}, path: "/items/-1"},
+		{name: "uuid constraint", patterns: []string{"/orders/:id<uuid>"}, path: "/orders/123e4567-e89b-12d3-a456-426614174000", want: "/orders/:id<uuid>", params: map[string]string{"id": "123e4567-e89b-12d3-a456-426614174000"}},
+		{name: "regex constraint", patterns: []string{"/v/:ver<v[0-9]+>"}, path: "/v/v2", want: "/v/:ver<v[0-9]+>", params: map[string]string{"ver": "v2"}},
+		{name: "static beats catch-all", patterns: []string{"/files/*path", "/files/readme"}, path: "/files/readme", want: "/files/readme", params: map[string]string{}},
+		{name: "param beats catch-all", patterns: []string{"/files/*path", "/files/:name"}, path: "/files/a", want: "/files/:name", params: map[string]string{"name": "a"}},
+		{name: "catch-all fallback", patterns: []string{"/files/*path", "/files/:name"}, path: "/files/a/b/c", want: "/files/*path", params: map[string]string{"path": "a/b/c"}},
+		{name: "catch-all absent", patterns: []string{"/files/*path"}, path: "/files", want: "/files/*path", params: map[string]string{}},
+		{name: "explicit route beats absent catch-all", patterns: []string{"/files/*path", "/files"}, path: "/files", want: "/files", params: map[string]string{}},
+		{name: "explicit route registered first", patterns: []string{"/files", "/files/*path"}, path: "/files", want: "/files", params: map[string]string{}},
+		{name: "optional present", patterns: []string{"/posts/:page?"}, path: "/posts/3", want: "/posts/:page?", params: map[string]string{"page": "3"}},
+		{name: "optional absent", patterns: []string{"/posts/:page?"}, path: "/posts", want: "/posts/:page?", params: map[string]string{}},
+		{name: "trailing slash", patterns: []string{"/users/:id"}, path: "/users/42/", want: "/users/:id", params: map[string]string{"id": "42"}},
+		{name: "pattern trailing slash", patterns: []string{"/users/"}, path: "/users", want: "/users", params: map[string]string{}},
+		{name: "extra slashes", patterns: []string{"/users"}, path: "//users//", want: "/users", params: map[string]string{}},
+		{name: "root", patterns: []string{"/", "/users"}, path: "/", want: "/", params: map[string]string{}},
+		{name: "empty segment is not a param", patterns: []string{"/a/:id/b"}, path: "/a//b"},
+		{name: "no match", patterns: []string{"/users/:id"}, path: "/accounts/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, params := newTestRouter(tt.patterns...).find(http.MethodGet, tt.path)
+			if tt.want == "" {
+				if n != nil {
+					t.Fatalf("matched %s, want no match", n.pattern)
+				}
+				return
+			}
+			if n == nil {
+				t.Fatalf("no match, want %s", tt.want)
+			}
+			if n.pattern != cleanPath(tt.want) {
+				t.Fatalf("matched %s, want %s", n.pattern, tt.want)
+			}
+			if !reflect.DeepEqual(params, tt.params) {
+				t.Fatalf("params %v, want %v", params, tt.params)
+			}
+		})
+	}
+}
+
+func TestRouterRegistrationErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+	}{
+		{name: "duplicate", patterns: []string{"/users/:id", "/users/:id"}},
+		{name: "duplicate after cleaning", patterns: []string{"/users", "/users/"}},
+		{name: "duplicate constraint", patterns: []string{"/items/:id<int>", "/items/:id<int>"}},
+		{name: "duplicate catch-all", patterns: []string{"/files/*path", "/files/*path"}},
+		{name: "conflicting catch-all", patterns: []string{"/files/*path", "/files/*name"}},
+		{name: "unnamed param", patterns: []string{"/users/:"}},
+		{name: "repeated param", patterns: []string{"/a/:id/b/:id"}},
+		{name: "catch-all not last", patterns: []string{"/a/*rest/b"}},
+		{name: "optional not trailing", patterns: []string{"/a/:b?/c"}},
+		{name: "invalid constraint", patterns: []string{"/a/:b<[>"}},
+		{name: "unterminated constraint", patterns: []string{"/a/:b<int"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected a panic")
+				}
+			}()
+			newTestRouter(tt.patterns...)
+		})
+	}
+}
+
+func TestRouterSameShapeOtherMethod(t *testing.T) {
+	rt := newTestRouter("/users/:id")
+	rt.add(http.MethodPost, "/users/:id", http.NotFoundHandler())
+
+	if n, _ := rt.find(http.MethodPost, "/users/1"); n == nil {
+		t.Fatal("POST route not found")
+	}
+}
+
+func TestRouterServeHTTP(t *testing.T) {
+	rt := newRouter()
+	rt.add(http.MethodGet, "/users/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, _ := r.Context().Value(pathParamsKey).(map[string]string)
+		w.Write([]byte(params["id"]))
+	}))
+	rt.add(http.MethodPut, "/users/:id", http.NotFoundHandler())
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		code   string // problem code expected in a problem+json body
+		allow  string
+	}{
+		{method: http.MethodGet, path: "/users/7", status: http.StatusOK, body: "7"},
+		{method: http.MethodDelete, path: "/users/7", status: http.StatusMethodNotAllowed, code: "method_not_allowed", allow: "GET, OPTIONS, PUT"},
+		{method: http.MethodOptions, path: "/users/7", status: http.StatusNoContent, allow: "GET, OPTIONS, PUT"},
+		{method: http.MethodGet, path: "/missing", status: http.StatusNotFound, code: "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Fatalf("body %q, want %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Fatalf("Allow %q, want %q", got, tt.allow)
+			}
+			if tt.code == "" {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != problemContentType {
+				t.Fatalf("Content-Type %q, want problem+json", got)
+			}
+			var problem Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Status != tt.status || problem.Code != tt.code || problem.Instance != tt.path {
+				t.Fatalf("problem %+v, want status %d, code %q and instance %s", problem, tt.status, tt.code, tt.path)
+			}
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := map[string]string{
+		"":          "/",
+		"/":         "/",
+		"//":        "/",
+		"/users":    "/users",
+		"users":     "/users",
+		"/users/":   "/users",
+		"//users//": "/users",
+		"/a/b/c/":   "/a/b/c",
+	}
+	for in, want := range tests {
+		if got := cleanPath(in); got != want {
+			t.Errorf("cleanPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// benchmarkRouter registers five routes per resource: a collection, a
+// static sub-resource, an item, a constrained nested item and a catch-all.
+func benchmarkRouter(resources int) *router {
+	rt := newRouter()
+	handler := http.NotFoundHandler()
+	for i := range resources {
+		base := fmt.Sprintf("/api/v1/resource%d", i)
+		rt.add(http.MethodGet, base, handler)
+		rt.add(http.MethodGet, base+"/search", handler)
+		rt.add(http.MethodGet, base+"/:id", handler)
+		rt.add(http.MethodGet, base+"/:id/children/:child<int>", handler)
+		rt.add(http.MethodGet, base+"/:id/files/*path", handler)
+	}
+	return rt
+}
+
+func BenchmarkRouterFind(b *testing.B) {
+	paths := []struct{ name, format string }{
+		{"static", "/api/v1/resource%d/search"},
+		{"param", "/api/v1/resource%d/abc123"},
+		{"nested", "/api/v1/resource%d/abc123/children/42"},
+		{"catchall", "/api/v1/resource%d/abc123/files/a/b/c.txt"},
+		{"miss", "/api/v1/resource%d/abc123/unknown/x"},
+	}
+	for _, resources := range []int{10, 100, 500} {
+		rt := benchmarkRouter(resources)
+		for _, p := range paths {
+			path := fmt.Sprintf(p.format, resources-1)
+			b.Run(fmt.Sprintf("routes=%d/%s", resources*5, p.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					rt.find(http.MethodGet, path)
+				}
+			})
+		}
+	}
+}
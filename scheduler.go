@@ -1,7 +1,9 @@
 package gogi
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
@@ -19,6 +21,10 @@ type job struct {
 var (
 	jobsMu sync.Mutex
 	jobs   []job
+
+	schedulerStop chan struct{}
+	schedulerDone chan struct{}
+	runningJobs   sync.WaitGroup
 )
 
 func validateDuration(value interface{}) (time.Duration, bool) {
@@ -49,21 +55,43 @@ func addJobCron(name, cronExpr string, fn func()) error {
 }
 
 func startScheduler() {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
 	if len(jobs) == 0 {
 		log.Println("No jobs registered, skipping scheduler start")
 		return
 	}
+	if schedulerStop != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	schedulerStop = stop
+	schedulerDone = done
+
 	go func() {
+		defer close(done)
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
-		for now := range ticker.C {
+		for {
+			var now time.Time
+			select {
+			case <-stop:
+				return
+			case now = <-ticker.C:
+			}
+
 			jobsMu.Lock()
 			for _, j := range jobs {
 				if j.interval > 0 {
 					if now.Unix()%int64(j.interval.Seconds()) == 0 {
+						runningJobs.Add(1)
 						go safeRun(j.name, j.fn)
 					}
 				} else if j.cron != nil && j.cron.matches(now) {
+					runningJobs.Add(1)
 					go safeRun(j.name, j.fn)
 				}
 			}
@@ -72,9 +100,42 @@ func startScheduler() {
 	}()
 }
 
+// stopScheduler stops scheduling new runs and waits for the running ones to
+// return, or for ctx to expire.
+func stopScheduler(ctx context.Context) error {
+	jobsMu.Lock()
+	stop, done := schedulerStop, schedulerDone
+	schedulerStop, schedulerDone = nil, nil
+	jobsMu.Unlock()
+
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+
+	finished := make(chan struct{})
+	go func() {
+		runningJobs.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for running jobs: %w", ctx.Err())
+	}
+}
+
 func safeRun(name string, fn func()) {
+	defer runningJobs.Done()
+	start := time.Now()
 	defer func() {
+		jobRunsTotal.Inc(name)
+		jobRunDuration.ObserveSince(start, name)
 		if r := recover(); r != nil {
+			jobFailuresTotal.Inc(name)
 			log.Printf("⛔ Job '%s' panicked: %v", name, r)
 		}
 	}()
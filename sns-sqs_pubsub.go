@@ -2,12 +2,15 @@ package gogi
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
 type SNS_SQS_PubSub struct {
@@ -26,22 +29,42 @@ func NewSNS_SQSPubSub(topicARN, queueURL string, snsClient *SNSClient, sqsClient
 	}
 }
 
-func (p *SNS_SQS_PubSub) Publish(ctx context.Context, data []byte) error {
-	_, err := p.snsClient.Client.Publish(ctx, &sns.PublishInput{
-		TopicArn: &p.topicARN,
-		Message:  aws.String(string(data)),
+// Publish sends data with the caller's trace context as message
+// attributes, which Subscribe reads whether or not the SQS subscription uses
+// raw message delivery.
+func (p *SNS_SQS_PubSub) Publish(ctx context.Context, data []byte) (err error) {
+	ctx, span := startProducerSpan(ctx, "sns", p.topicARN)
+	defer func() {
+		observePublish("sns", p.topicARN, &err)
+		endSpan(span, err)
+	}()
+
+	attributes := make(map[string]snstypes.MessageAttributeValue)
+	for key, value := range injectTraceContext(ctx) {
+		attributes[key] = snstypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+	}
+	_, err = p.snsClient.Client.Publish(ctx, &sns.PublishInput{
+		TopicArn:          &p.topicARN,
+		Message:           aws.String(string(data)),
+		MessageAttributes: attributes,
 	})
 	return err
 }
 
-func (p *SNS_SQS_PubSub) Subscribe(ctx context.Context, handler func([]byte) error) error {
+// Subscribe hands each message to handler as an Event whose Payload is the
+// published data and whose Context continues the publisher's trace. SNS
+// notification envelopes, sent when raw message delivery is off, are
+// unwrapped. Messages are deleted once handler returns nil.
+func (p *SNS_SQS_PubSub) Subscribe(ctx context.Context, handler func(*Event) error) error {
 	for {
 		output, err := p.sqsClient.Client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
-			QueueUrl:            aws.String(p.queueURL),
-			MaxNumberOfMessages: 10,
-			WaitTimeSeconds:     10,
+			QueueUrl:              aws.String(p.queueURL),
+			MaxNumberOfMessages:   10,
+			WaitTimeSeconds:       10,
+			MessageAttributeNames: []string{"All"},
 		})
 		if err != nil {
+			observePubSubError("sns", p.topicARN, "receive", err)
 			return fmt.Errorf("receive error: %w", err)
 		}
 
@@ -50,18 +73,53 @@ func (p *SNS_SQS_PubSub) Subscribe(ctx context.Context, handler func([]byte) err
 				continue
 			}
 
-			if err := handler([]byte(*msg.Body)); err != nil {
+			event := snsEvent(p.topicARN, msg)
+			if err := processEvent(ctx, "sns", event, handler); err != nil {
 				log.Printf("handler error: %v", err)
 				continue
 			}
 
-			_, err := p.sqsClient.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			_, err = p.sqsClient.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
 				QueueUrl:      aws.String(p.queueURL),
 				ReceiptHandle: msg.ReceiptHandle,
 			})
 			if err != nil {
+				observePubSubError("sns", p.topicARN, "delete", err)
 				log.Printf("delete message error: %v", err)
 			}
 		}
 	}
 }
+
+// snsNotification is the JSON envelope SNS wraps messages in when raw
+// message delivery is off; message attributes travel inside it.
+type snsNotification struct {
+	Type              string
+	TopicArn          string
+	Message           string
+	MessageAttributes map[string]struct {
+		Type  string
+		Value string
+	}
+}
+
+func snsEvent(topicARN string, msg sqstypes.Message) *Event {
+	event := &Event{Topic: topicARN, Payload: []byte(*msg.Body), TraceContext: make(map[string]string)}
+	for key, value := range msg.MessageAttributes {
+		if value.StringValue != nil {
+			event.TraceContext[key] = *value.StringValue
+		}
+	}
+
+	var notification snsNotification
+	if json.Unmarshal(event.Payload, &notification) != nil || notification.Type != "Notification" || notification.TopicArn == "" {
+		return event
+	}
+	event.Payload = []byte(notification.Message)
+	for key, value := range notification.MessageAttributes {
+		if value.Type == "String" {
+			event.TraceContext[key] = value.Value
+		}
+	}
+	return event
+}
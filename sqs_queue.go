@@ -3,21 +3,33 @@ package gogi
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 )
 
 type SQSJobQueue struct {
-	client   *SQSClient
-	queueURL string
+	client    *SQSClient
+	queueURL  string
+	queueName string
 }
 
 func NewSQSJobQueue(client *SQSClient, queueURL string) *SQSJobQueue {
-	return &SQSJobQueue{client: client, queueURL: queueURL}
+	return &SQSJobQueue{client: client, queueURL: queueURL, queueName: sqsQueueName(queueURL)}
 }
 
-func (q *SQSJobQueue) SendJob(ctx context.Context, job *Job) error {
+// sqsQueueName is the last path segment of a queue URL, such as "orders" in
+// https://sqs.us-east-1.amazonaws.com/123456789012/orders. Spans and metrics
+// use it rather than the URL, which also carries the account id.
+func sqsQueueName(queueURL string) string {
+	trimmed := strings.TrimRight(queueURL, "/")
+	return trimmed[strings.LastIndex(trimmed, "/")+1:]
+}
+
+func (q *SQSJobQueue) SendJob(ctx context.Context, job *Job) (err error) {
+	ctx, job, done := startJobSend(ctx, "sqs", q.queueName, job)
+	defer done(&err)
 	data, err := json.Marshal(job)
 	if err != nil {
 		return err
@@ -36,15 +48,17 @@ func (q *SQSJobQueue) ReceiveJobs(ctx context.Context, handler func(*Job) error)
 		WaitTimeSeconds:     5,
 	})
 	if err != nil {
+		observeQueueError("sqs", q.queueName, "receive", err)
 		return err
 	}
 
 	for _, msg := range resp.Messages {
 		var job Job
 		if err := json.Unmarshal([]byte(*msg.Body), &job); err != nil {
+			observeQueueError("sqs", q.queueName, "receive", err)
 			return err
 		}
-		if err := handler(&job); err != nil {
+		if err := processJob(ctx, "sqs", q.queueName, &job, handler); err != nil {
 			return err
 		}
 		_, err = q.client.Client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
@@ -52,6 +66,7 @@ func (q *SQSJobQueue) ReceiveJobs(ctx context.Context, handler func(*Job) error)
 			ReceiptHandle: msg.ReceiptHandle,
 		})
 		if err != nil {
+			observeQueueError("sqs", q.queueName, "delete", err)
 			return err
 		}
 	}
@@ -0,0 +1,251 @@
+package gogi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const staticPathParam = "filepath"
+
+// StaticConfig controls how Static serves files. Index is the file served
+// for directories (default index.html). Listing renders an HTML index for
+// directories without one. SPAFallback serves the root Index for missing
+// paths that have no file extension, so client-side routes resolve. MaxAge
+// sets Cache-Control on files; index and fallback responses always use
+// no-cache. Dotfiles are never served.
+type StaticConfig struct {
+	Index       string
+	Listing     bool
+	SPAFallback bool
+	MaxAge      time.Duration
+}
+
+// WithStaticConfig configures a route registered with Static.
+func WithStaticConfig(config StaticConfig) RouteOption {
+	return func(route *route) {
+		route.static = &config
+	}
+}
+
+func (config StaticConfig) withDefaults() StaticConfig {
+	if config.Index == "" {
+		config.Index = "index.html"
+	}
+	return config
+}
+
+type staticEndpoint struct {
+	fsys   fs.FS
+	config StaticConfig
+	etags  sync.Map // name -> ETag, for files without a modification time
+}
+
+func (httpServer *HttpServer) addStatic(group *RouteGroup, prefix string, fsys fs.FS, opts ...RouteOption) {
+	endpoint := &staticEndpoint{fsys: fsys}
+	pattern := strings.TrimSuffix(prefix, "/") + "/*" + staticPathParam
+
+	var config StaticConfig
+	for _, method := range []HTTPMethod{HTTP_GET, HTTP_HEAD} {
+		route := httpServer.addRoute(group, method, pattern, endpoint, opts...)
+		if route.static != nil {
+			config = *route.static
+		}
+	}
+	endpoint.config = config.withDefaults()
+}
+
+func (endpoint *staticEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params, _ := r.Context().Value(pathParamsKey).(map[string]string)
+	name := strings.TrimPrefix(path.Clean("/"+params[staticPathParam]), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) || isHidden(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	info, err := fs.Stat(endpoint.fsys, name)
+	if err != nil {
+		if endpoint.config.SPAFallback && path.Ext(name) == "" {
+			endpoint.serveFile(w, r, endpoint.config.Index, "no-cache")
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	if info.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			target := r.URL.Path + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+
+		index := path.Join(name, endpoint.config.Index)
+		if _, err := fs.Stat(endpoint.fsys, index); err == nil {
+			endpoint.serveFile(w, r, index, "no-cache")
+			return
+		}
+		if endpoint.config.Listing {
+			endpoint.serveListing(w, r, name)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	cacheControl := "no-cache"
+	if endpoint.config.MaxAge > 0 && path.Base(name) != endpoint.config.Index {
+		cacheControl = fmt.Sprintf("public, max-age=%d", int(endpoint.config.MaxAge.Seconds()))
+	}
+	endpoint.serveFile(w, r, name, cacheControl)
+}
+
+// serveFile writes name, preferring a precompressed name.br or name.gz
+// variant when the client accepts it. http.ServeContent takes care of
+// conditional requests and ranges.
+func (endpoint *staticEndpoint) serveFile(w http.ResponseWriter, r *http.Request, name, cacheControl string) {
+	header := w.Header()
+	header.Set("Cache-Control", cacheControl)
+
+	served := name
+	encoding := ""
+	var variants []string
+	for _, candidate := range []string{"br", "gzip"} {
+		if _, err := fs.Stat(endpoint.fsys, name+variantSuffix(candidate)); err == nil {
+			variants = append(variants, candidate)
+		}
+	}
+	if len(variants) > 0 {
+		addVary(header, "Accept-Encoding")
+		if encoding = negotiateEncoding(r.Header.Values("Accept-Encoding"), variants); encoding != "" {
+			served = name + variantSuffix(encoding)
+		}
+	}
+
+	f, err := endpoint.fsys.Open(served)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	etag, err := endpoint.etag(served, info, content)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	header.Set("ETag", etag)
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+		if header.Get("Content-Type") == "" && mime.TypeByExtension(path.Ext(name)) == "" {
+			// Sniffing would see the compressed bytes.
+			header.Set("Content-Type", "application/octet-stream")
+		}
+	}
+
+	// The original name keeps the Content-Type of the uncompressed file.
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// etag derives a strong validator from size and modification time, or from
+// the content itself for filesystems such as embed.FS that have no
+// modification times. Content hashes are cached since those files never
+// change.
+func (endpoint *staticEndpoint) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+	if etag, ok := endpoint.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	endpoint.etags.Store(name, etag)
+	return etag, nil
+}
+
+func (endpoint *staticEndpoint) serveListing(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(endpoint.fsys, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var buf bytes.Buffer
+	title := html.EscapeString(r.URL.Path)
+	fmt.Fprintf(&buf, "<!doctype html>\n<title>%s</title>\n<h1>%s</h1>\n<ul>\n", title, title)
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if strings.HasPrefix(entryName, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(&buf, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(link.String()), html.EscapeString(entryName))
+	}
+	buf.WriteString("</ul>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf.Bytes())
+}
+
+func variantSuffix(encoding string) string {
+	if encoding == "gzip" {
+		return ".gz"
+	}
+	return "." + encoding
+}
+
+func isHidden(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") && part != "." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package gogi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var staticTestModTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestStaticHandler(config StaticConfig) http.Handler {
+	modTime := staticTestModTime
+	fsys := fstest.MapFS{
+		"index.html":         {Data: []byte("<h1>home</h1>"), ModTime: modTime},
+		"app.js":             {Data: []byte("console.log('app')"), ModTime: modTime},
+		"app.js.gz":          {Data: []byte("gzipped app"), ModTime: modTime},
+		"app.js.br":          {Data: []byte("brotli app"), ModTime: modTime},
+		"data.txt":           {Data: []byte("0123456789"), ModTime: modTime},
+		"docs/guide.txt":     {Data: []byte("guide")},
+		"docs/api/ref.txt":   {Data: []byte("ref")},
+		"docs/.secret":       {Data: []byte("hidden")},
+		"empty/.keep":        {Data: []byte("")},
+		".env":               {Data: []byte("SECRET=1")},
+		"nested/index.html":  {Data: []byte("nested home")},
+		"nested/deeper/a.md": {Data: []byte("a")},
+	}
+	server := &HttpServer{router: newRouter()}
+	server.addStatic(nil, "/assets", fsys, WithStaticConfig(config))
+	return server.handler()
+}
+
+func TestStatic(t *testing.T) {
+	plain := newTestStaticHandler(StaticConfig{MaxAge: time.Hour})
+	listing := newTestStaticHandler(StaticConfig{Listing: true})
+	spa := newTestStaticHandler(StaticConfig{SPAFallback: true})
+
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		method         string
+		path           string
+		headers        map[string]string
+		status         int
+		body           string // exact, unless contains is set
+		contains       []string
+		notContains    []string
+		wantHeaders    map[string]string
+		wantNoEncoding bool
+	}{
+		{name: "file", handler: plain, path: "/assets/data.txt", status: http.StatusOK, body: "0123456789",
+			wantHeaders: map[string]string{"Cache-Control": "public, max-age=3600", "Content-Type": "text/plain; charset=utf-8"}},
+		{name: "root index", handler: plain, path: "/assets/", status: http.StatusOK, body: "<h1>home</h1>",
+			wantHeaders: map[string]string{"Cache-Control": "no-cache"}},
+		{name: "directory redirect keeps query", handler: plain, path: "/assets/nested?v=1", status: http.StatusMovedPermanently,
+			wantHeaders: map[string]string{"Location": "/assets/nested/?v=1"}},
+		{name: "nested index", handler: plain, path: "/assets/nested/", status: http.StatusOK, body: "nested home"},
+		{name: "directory without index", handler: plain, path: "/assets/docs/", status: http.StatusNotFound},
+		{name: "listing", handler: listing, path: "/assets/docs/", status: http.StatusOK,
+			contains: []string{`<a href="api/">api/</a>`, `<a href="guide.txt">guide.txt</a>`}, notContains: []string{".secret"}},
+		{name: "dotfile", handler: plain, path: "/assets/.env", status: http.StatusNotFound},
+		{name: "file in dot directory", handler: plain, path: "/assets/docs/.secret", status: http.StatusNotFound},
+		{name: "missing", handler: plain, path: "/assets/nope", status: http.StatusNotFound},
+		{name: "SPA fallback", handler: spa, path: "/assets/users/42", status: http.StatusOK, body: "<h1>home</h1>",
+			wantHeaders: map[string]string{"Cache-Control": "no-cache"}},
+		{name: "SPA leaves missing files alone", handler: spa, path: "/assets/missing.js", status: http.StatusNotFound},
+		{name: "SPA does not expose dotfiles", handler: spa, path: "/assets/.env", status: http.StatusNotFound},
+		{name: "precompressed br", handler: plain, path: "/assets/app.js", headers: map[string]string{"Accept-Encoding": "gzip, br"},
+			status: http.StatusOK, body: "brotli app",
+			wantHeaders: map[string]string{"Content-Encoding": "br", "Vary": "Accept-Encoding", "Content-Type": "text/javascript; charset=utf-8"}},
+		{name: "precompressed gzip", handler: plain, path: "/assets/app.js", headers: map[string]string{"Accept-Encoding": "gzip"},
+			status: http.StatusOK, body: "gzipped app",
+			wantHeaders: map[string]string{"Content-Encoding": "gzip", "Vary": "Accept-Encoding"}},
+		{name: "precompressed not accepted", handler: plain, path: "/assets/app.js",
+			status: http.StatusOK, body: "console.log('app')", wantNoEncoding: true,
+			wantHeaders: map[string]string{"Vary": "Accept-Encoding"}},
+		{name: "range", handler: plain, path: "/assets/data.txt", headers: map[string]string{"Range": "bytes=2-4"},
+			status: http.StatusPartialContent, body: "234",
+			wantHeaders: map[string]string{"Content-Range": "bytes 2-4/10"}},
+		{name: "HEAD", handler: plain, method: http.MethodHead, path: "/assets/data.txt", status: http.StatusOK, body: "",
+			wantHeaders: map[string]string{"Content-Length": "10"}},
+		{name: "conditional", handler: plain, path: "/assets/data.txt", headers: map[string]string{"If-None-Match": fmt.Sprintf(`"%x-%x"`, staticTestModTime.UnixNano(), 10)},
+			status: http.StatusNotModified, body: ""},
+		{name: "traversal", handler: plain, path: "/assets/../../etc/passwd", notContains: []string{"root:"}},
+		{name: "encoded traversal", handler: plain, path: "/assets/%2e%2e/%2e%2e/etc/passwd", notContains: []string{"root:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, tt.path, nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+
+			if tt.status != 0 && w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status == 0 && w.Code == http.StatusOK {
+				t.Fatalf("status 200 for %s", tt.path)
+			}
+			body := w.Body.String()
+			if tt.contains == nil && tt.status == http.StatusOK && body != tt.body {
+				t.Errorf("body %q, want %q", body, tt.body)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("body lacks %s:\n%s", want, body)
+				}
+			}
+			for _, notWant := range tt.notContains {
+				if strings.Contains(body, notWant) {
+					t.Errorf("body has %s:\n%s", notWant, body)
+				}
+			}
+			for k, want := range tt.wantHeaders {
+				if got := w.Header().Get(k); got != want {
+					t.Errorf("%s %q, want %q", k, got, want)
+				}
+			}
+			if tt.wantNoEncoding && w.Header().Get("Content-Encoding") != "" {
+				t.Errorf("Content-Encoding %q, want none", w.Header().Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestStaticETagWithoutModTime(t *testing.T) {
+	handler := newTestStaticHandler(StaticConfig{})
+	get := func(etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/assets/docs/guide.txt", nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("status %d, ETag %q, want a content hash", first.Code, etag)
+	}
+	if w := get(etag); w.Code != http.StatusNotModified {
+		t.Fatalf("status %d with a matching ETag, want 304", w.Code)
+	}
+}
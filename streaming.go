@@ -0,0 +1,126 @@
+package gogi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamWriter writes a response incrementally. Writes fail with the
+// request's context error once the client has gone away.
+type StreamWriter interface {
+	Write(p []byte) (int, error)
+	// Flush sends everything written so far to the client.
+	Flush() error
+	// Event writes and flushes one Server-Sent Event.
+	Event(event *SSEEvent) error
+	// Comment writes and flushes an SSE comment, useful as a keepalive.
+	Comment(text string) error
+	Context() context.Context
+}
+
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// Stream switches the response to streaming mode. Status and headers are
+// sent once the handler returns, then fn runs until it returns or the
+// client disconnects.
+func (res *HTTPServerResponse) Stream(fn func(w StreamWriter) error) {
+	res.stream = fn
+}
+
+// SSE is Stream with the headers required for Server-Sent Events.
+func (res *HTTPServerResponse) SSE(fn func(w StreamWriter) error) {
+	res.Headers["Content-Type"] = "text/event-stream"
+	res.Headers["Cache-Control"] = "no-cache"
+	res.Headers["X-Accel-Buffering"] = "no"
+	res.stream = fn
+}
+
+type streamWriter struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	ctx context.Context
+}
+
+func writeStream(w http.ResponseWriter, r *http.Request, fn func(w StreamWriter) error) {
+	sw := &streamWriter{
+		w:   w,
+		rc:  http.NewResponseController(w),
+		ctx: r.Context(),
+	}
+
+	// Streams outlive the server's write timeout by design.
+	if err := sw.rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		GetLogger().Warn(fmt.Sprintf("[HTTP] Stream %s: clearing write deadline: %v", r.URL.Path, err))
+	}
+
+	if err := sw.Flush(); err != nil {
+		GetLogger().Error(fmt.Sprintf("[HTTP] Stream %s: %v", r.URL.Path, err))
+		return
+	}
+
+	if err := fn(sw); err != nil && !errors.Is(err, context.Canceled) {
+		GetLogger().Error(fmt.Sprintf("[HTTP] Stream %s: %v", r.URL.Path, err))
+	}
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if err := sw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return sw.w.Write(p)
+}
+
+func (sw *streamWriter) Flush() error {
+	if err := sw.ctx.Err(); err != nil {
+		return err
+	}
+	return sw.rc.Flush()
+}
+
+func (sw *streamWriter) Event(event *SSEEvent) error {
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + sseField(event.ID) + "\n")
+	}
+	if event.Event != "" {
+		b.WriteString("event: " + sseField(event.Event) + "\n")
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(event.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(event.Data, "\r\n", "\n"), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	if _, err := sw.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	return sw.Flush()
+}
+
+func (sw *streamWriter) Comment(text string) error {
+	if _, err := sw.Write([]byte(": " + sseField(text) + "\n\n")); err != nil {
+		return err
+	}
+	return sw.Flush()
+}
+
+func (sw *streamWriter) Context() context.Context {
+	return sw.ctx
+}
+
+// sseField drops line breaks, which would otherwise end the field early.
+func sseField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,128 @@
+package gogi
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEEventFraming(t *testing.T) {
+	tests := []struct {
+		name  string
+		event SSEEvent
+		want  string
+	}{
+		{name: "data only", event: SSEEvent{Data: "hello"}, want: "data: hello\n\n"},
+		{name: "multi-line data", event: SSEEvent{Data: "one\ntwo\r\nthree"}, want: "data: one\ndata: two\ndata: three\n\n"},
+		{name: "empty data", event: SSEEvent{}, want: "data: \n\n"},
+		{
+			name:  "all fields",
+			event: SSEEvent{ID: "7", Event: "update", Retry: 1500 * time.Millisecond, Data: "x"},
+			want:  "id: 7\nevent: update\nretry: 1500\ndata: x\n\n",
+		},
+		{name: "line breaks dropped from fields", event: SSEEvent{ID: "1\n2", Event: "a\r\nb", Data: "x"}, want: "id: 12\nevent: ab\ndata: x\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			sw := &streamWriter{w: w, rc: http.NewResponseController(w), ctx: r.Context()}
+			if err := sw.Event(&tt.event); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Fatalf("framed %q, want %q", got, tt.want)
+			}
+			if !w.Flushed {
+				t.Error("event was not flushed")
+			}
+		})
+	}
+}
+
+func TestSSEFlushesEachEvent(t *testing.T) {
+	next := make(chan struct{})
+	server := &HttpServer{router: newRouter()}
+	server.addRoute(nil, HTTP_GET, "/events", httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		res.SSE(func(w StreamWriter) error {
+			for _, data := range []string{"first", "second"} {
+				if err := w.Event(&SSEEvent{Data: data}); err != nil {
+					return err
+				}
+				<-next
+			}
+			return nil
+		})
+	}))
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	res, err := client.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	for header, want := range map[string]string{"Content-Type": "text/event-stream", "Cache-Control": "no-cache", "X-Accel-Buffering": "no"} {
+		if got := res.Header.Get(header); got != want {
+			t.Errorf("%s %q, want %q", header, got, want)
+		}
+	}
+
+	// Each event must arrive while the handler waits before the next one.
+	br := bufio.NewReader(res.Body)
+	for _, want := range []string{"first", "second"} {
+		line, err := br.ReadString('\n')
+		if err != nil || line != "data: "+want+"\n" {
+			t.Fatalf("read %q (%v), want the %s event", line, err, want)
+		}
+		br.ReadString('\n')
+		next <- struct{}{}
+	}
+}
+
+func TestStreamStopsWhenClientCancels(t *testing.T) {
+	done := make(chan error, 1)
+	server := &HttpServer{router: newRouter()}
+	server.addRoute(nil, HTTP_GET, "/events", httpHandler(func(req *HTTPServerRequest, res *HTTPServerResponse) {
+		res.SSE(func(w StreamWriter) error {
+			for {
+				if err := w.Comment("tick"); err != nil {
+					done <- err
+					return err
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		})
+	}))
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/events", nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, _ := bufio.NewReader(res.Body).ReadString('\n')
+	if !strings.HasPrefix(line, ": tick") {
+		t.Fatalf("read %q, want a comment", line)
+	}
+	cancel()
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("stream ended without an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream kept running after the client went away")
+	}
+}
@@ -0,0 +1,338 @@
+package gogi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName             = "github.com/dejaniskra/go-gi"
+	tracingDefaultService  = "gogi"
+	tracingRoleAttribute   = attribute.Key("gogi.role")
+	tracingBackendFallback = "gogi."
+)
+
+// TracingConfig configures EnableTracing. Spans go to Exporter or, when it
+// is nil, to an OTLP/HTTP collector at Endpoint (host:port, default taken
+// from OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318). SampleRatio samples
+// that fraction of new traces; zero samples all of them. Incoming sampling
+// decisions are always respected.
+type TracingConfig struct {
+	ServiceName string
+	Exporter    sdktrace.SpanExporter
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+	// Synchronous exports each span as it ends instead of batching, so a
+	// test reading an in-memory exporter sees spans straight away.
+	Synchronous bool
+}
+
+// EnableTracing installs an OpenTelemetry tracer provider and the W3C trace
+// context propagator. Routes then continue the trace of an incoming
+// traceparent header, HTTPClient propagates it to outgoing requests, and
+// database, Redis, queue and pub/sub calls record spans under the context
+// they are given. Shutdown flushes pending spans.
+func (application *Application) EnableTracing(config TracingConfig) error {
+	httpServer := getServer()
+	if application.httpServer == nil {
+		application.httpServer = httpServer
+	} // TODO: revisit this
+
+	if config.ServiceName == "" {
+		config.ServiceName = tracingDefaultService
+	}
+
+	exporter := config.Exporter
+	if exporter == nil {
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		otlp, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return fmt.Errorf("creating OTLP exporter: %w", err)
+		}
+		exporter = otlp
+	}
+
+	processor := sdktrace.NewBatchSpanProcessor(exporter)
+	if config.Synchronous {
+		processor = sdktrace.NewSimpleSpanProcessor(exporter)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.SampleRatio > 0 && config.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(config.SampleRatio)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(config.ServiceName)))
+	if err != nil {
+		return fmt.Errorf("creating trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	application.tracerProvider = provider
+	httpServer.tracingEnabled = true
+	return nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceContext returns the propagation headers for ctx, or nil when
+// there is no trace to propagate.
+func injectTraceContext(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+func extractTraceContext(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// serveTraced continues the caller's trace in a server span named after the
+// route pattern.
+func (route *route) serveTraced(w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer().Start(ctx, route.Method+" "+route.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(route.Method),
+			semconv.HTTPRoute(route.Path),
+			semconv.URLPath(r.URL.Path),
+		),
+	)
+	recorder := &statusRecorder{ResponseWriter: w}
+
+	completed := false
+	defer func() {
+		status := recorder.result(completed, isWebSocketRoute(route))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		span.End()
+	}()
+
+	serve(recorder, r.WithContext(ctx))
+	completed = true
+}
+
+func startHTTPClientSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(method)),
+	)
+}
+
+func endHTTPClientSpan(span trace.Span, status int) {
+	span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	if status >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// urlAttribute records the URL without credentials or query, which may hold
+// secrets.
+func urlAttribute(u *url.URL) attribute.KeyValue {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	redacted.Fragment = ""
+	return semconv.URLFull(redacted.String())
+}
+
+func injectTraceHeaders(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+var dbSystems = map[string]attribute.KeyValue{
+	"mysql":    semconv.DBSystemNameMySQL,
+	"postgres": semconv.DBSystemNamePostgreSQL,
+	"mongo":    semconv.DBSystemNameMongoDB,
+	"dynamo":   semconv.DBSystemNameAWSDynamoDB,
+}
+
+// startDBCall starts a client span for a database call. The helper defers
+// the returned function with a pointer to its named error result, which
+// ends the span and records the call metrics. A query that found no rows is
+// not a failure.
+func startDBCall(ctx context.Context, client, role, operation string, attrs ...attribute.KeyValue) (context.Context, func(*error)) {
+	start := time.Now()
+	attrs = append(attrs, dbSystems[client], semconv.DBOperationName(operation), tracingRoleAttribute.String(role))
+	ctx, span := tracer().Start(ctx, client+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	return ctx, func(err *error) {
+		dbCallDuration.ObserveSince(start, client, role, operation)
+		if *err != nil && !isNoRows(*err) {
+			dbCallErrors.Inc(client, role, operation)
+			endSpan(span, *err)
+			return
+		}
+		span.End()
+	}
+}
+
+func startRedisSpan(ctx context.Context, role, command string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, command,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNameRedis, semconv.DBOperationName(command), tracingRoleAttribute.String(role)),
+	)
+}
+
+func queryAttribute(query string) attribute.KeyValue {
+	return semconv.DBQueryText(query)
+}
+
+func collectionAttributes(db, coll string) []attribute.KeyValue {
+	return []attribute.KeyValue{semconv.DBNamespace(db), semconv.DBCollectionName(coll)}
+}
+
+// dynamoInstrumentation records every DynamoDB operation made through the
+// client, including those on DynamoClient.Client directly.
+func dynamoInstrumentation(role string) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("GogiInstrumentation",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (out middleware.InitializeOutput, metadata middleware.Metadata, err error) {
+				ctx, done := startDBCall(ctx, "dynamo", role, awsmiddleware.GetOperationName(ctx))
+				defer done(&err)
+				return next.HandleInitialize(ctx, in)
+			}), middleware.After)
+	}
+}
+
+func messagingSystem(backend string) attribute.KeyValue {
+	switch backend {
+	case "sqs":
+		return semconv.MessagingSystemAWSSQS
+	case "sns":
+		return semconv.MessagingSystemAWSSNS
+	}
+	return semconv.MessagingSystemKey.String(tracingBackendFallback + backend)
+}
+
+func startProducerSpan(ctx context.Context, backend, destination string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, messagingSpanName("send", destination),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(messagingAttributes(backend, destination, semconv.MessagingOperationTypeSend)...),
+	)
+}
+
+// startConsumerSpan continues the trace carried by a received message.
+// Cancellation still comes from ctx.
+func startConsumerSpan(ctx context.Context, backend, destination string, carrier map[string]string) (context.Context, trace.Span) {
+	return tracer().Start(extractTraceContext(ctx, carrier), messagingSpanName("process", destination),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(messagingAttributes(backend, destination, semconv.MessagingOperationTypeProcess)...),
+	)
+}
+
+func messagingSpanName(operation, destination string) string {
+	if destination == "" {
+		return operation
+	}
+	return operation + " " + destination
+}
+
+func messagingAttributes(backend, destination string, operation attribute.KeyValue) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{messagingSystem(backend), operation}
+	if destination != "" {
+		attrs = append(attrs, semconv.MessagingDestinationName(destination))
+	}
+	return attrs
+}
+
+// startJobSend starts a producer span and returns a copy of job carrying its
+// trace context, so the caller's Job is left untouched.
+func startJobSend(ctx context.Context, backend, queue string, job *Job) (context.Context, *Job, func(*error)) {
+	ctx, span := startProducerSpan(ctx, backend, queue)
+	sent := *job
+	sent.TraceContext = injectTraceContext(ctx)
+	return ctx, &sent, func(err *error) {
+		observeQueueSend(backend, queue, err)
+		endSpan(span, *err)
+	}
+}
+
+// processJob runs handler in a consumer span that continues the sender's
+// trace; the handler reaches it through job.Context().
+func processJob(ctx context.Context, backend, queue string, job *Job, handler func(*Job) error) error {
+	queueReceivedTotal.Inc(backend, queue)
+	ctx, span := startConsumerSpan(ctx, backend, queue, job.TraceContext)
+	job.ctx = ctx
+
+	err := handler(job)
+	observeQueueError(backend, queue, "handle", err)
+	endSpan(span, err)
+	return err
+}
+
+func startPublish(ctx context.Context, backend string, event *Event) (context.Context, *Event, func(*error)) {
+	ctx, span := startProducerSpan(ctx, backend, event.Topic)
+	published := *event
+	published.TraceContext = injectTraceContext(ctx)
+	return ctx, &published, func(err *error) {
+		observePublish(backend, event.Topic, err)
+		endSpan(span, *err)
+	}
+}
+
+// processEvent gives each subscriber its own copy of event, whose Context
+// continues the publisher's trace.
+func processEvent(ctx context.Context, backend string, event *Event, handler func(*Event) error) error {
+	pubsubReceivedTotal.Inc(backend, event.Topic)
+	ctx, span := startConsumerSpan(ctx, backend, event.Topic, event.TraceContext)
+	received := *event
+	received.ctx = ctx
+
+	err := handler(&received)
+	observePubSubError(backend, event.Topic, "handle", err)
+	endSpan(span, err)
+	return err
+}
@@ -0,0 +1,280 @@
+package gogi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+	testTraceparent = "00-" + testTraceID + "-" + testParentID + "-01"
+)
+
+func enableTestTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	app := NewApplication()
+	if err := app.EnableTracing(TracingConfig{Exporter: exporter, Synchronous: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.tracerProvider.Shutdown(context.Background()) })
+	return exporter
+}
+
+func findSpan(t *testing.T, exporter *tracetest.InMemoryExporter, kind trace.SpanKind) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.SpanKind == kind {
+			return span
+		}
+	}
+	t.Fatalf("no %s span in %d exported", kind, len(exporter.GetSpans()))
+	return tracetest.SpanStub{}
+}
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracingServerSpan(t *testing.T) {
+	exporter := enableTestTracing(t)
+
+	server := &HttpServer{router: newRouter(), tracingEnabled: true}
+	var handlerSpan trace.SpanContext
+	server.addRoute(nil, HTTP_GET, "/users/:id", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	handler := server.handler()
+
+	tests := []struct {
+		name        string
+		traceparent string
+	}{
+		{name: "continues incoming trace", traceparent: testTraceparent},
+		{name: "starts new trace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			r := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			if tt.traceparent != "" {
+				r.Header.Set("traceparent", tt.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			span := findSpan(t, exporter, trace.SpanKindServer)
+			if span.Name != "GET /users/:id" {
+				t.Errorf("name %q, want the route pattern", span.Name)
+			}
+			if span.SpanContext.SpanID() != handlerSpan.SpanID() {
+				t.Errorf("handler context does not carry the server span")
+			}
+			if got := spanAttribute(span, "http.response.status_code").AsInt64(); got != http.StatusInternalServerError {
+				t.Errorf("status attribute %d, want 500", got)
+			}
+			if span.Status.Code != codes.Error {
+				t.Errorf("span status %v, want error", span.Status.Code)
+			}
+
+			if tt.traceparent == "" {
+				if span.Parent.IsValid() {
+					t.Fatalf("unexpected parent %s", span.Parent.SpanID())
+				}
+				return
+			}
+			if span.SpanContext.TraceID().String() != testTraceID || span.Parent.SpanID().String() != testParentID {
+				t.Fatalf("trace %s parent %s, want %s %s", span.SpanContext.TraceID(), span.Parent.SpanID(), testTraceID, testParentID)
+			}
+			if !span.Parent.IsRemote() {
+				t.Fatal("parent is not marked remote")
+			}
+		})
+	}
+}
+
+func TestTracingHTTPClientInjects(t *testing.T) {
+	exporter := enableTestTracing(t)
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	ctx, parent := tracer().Start(context.Background(), "parent")
+	path := "/items?token=secret"
+	_, err := GetHTTPClient(&ts.URL, nil, nil).Execute(&HTTPClientRequest{Context: ctx, Method: HTTP_GET, Path: &path})
+	parent.End()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	span := findSpan(t, exporter, trace.SpanKindClient)
+	if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("client span parent %s, want %s", span.Parent.SpanID(), parent.SpanContext().SpanID())
+	}
+	want := "00-" + span.SpanContext.TraceID().String() + "-" + span.SpanContext.SpanID().String() + "-01"
+	if traceparent != want {
+		t.Fatalf("traceparent %q, want %q", traceparent, want)
+	}
+	if got := spanAttribute(span, "url.full").AsString(); got != ts.URL+"/items" {
+		t.Errorf("url attribute %q, want the query stripped", got)
+	}
+	if span.Status.Code != codes.Error {
+		t.Errorf("span status %v, want error for a 404", span.Status.Code)
+	}
+}
+
+func TestTracingJobPropagation(t *testing.T) {
+	exporter := enableTestTracing(t)
+
+	ctx, parent := tracer().Start(context.Background(), "parent")
+	queue := NewInMemoryJobQueue()
+	job := &Job{Type: "email"}
+	if err := queue.SendJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+	if job.TraceContext != nil {
+		t.Fatal("SendJob modified the caller's job")
+	}
+
+	var received trace.SpanContext
+	err := queue.ReceiveJobs(context.Background(), func(job *Job) error {
+		received = trace.SpanContextFromContext(job.Context())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer := findSpan(t, exporter, trace.SpanKindProducer)
+	consumer := findSpan(t, exporter, trace.SpanKindConsumer)
+	if producer.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("producer parent %s, want %s", producer.Parent.SpanID(), parent.SpanContext().SpanID())
+	}
+	if consumer.Parent.SpanID() != producer.SpanContext.SpanID() || consumer.SpanContext.TraceID() != parent.SpanContext().TraceID() {
+		t.Fatal("consumer span does not continue the producer span")
+	}
+	if received.SpanID() != consumer.SpanContext.SpanID() {
+		t.Fatal("job.Context does not carry the consumer span")
+	}
+}
+
+func TestTracingEventPropagation(t *testing.T) {
+	exporter := enableTestTracing(t)
+
+	pubsub := NewInMemoryPubSub()
+	received := make(chan trace.SpanContext, 1)
+	pubsub.Subscribe(context.Background(), "orders", func(event *Event) error {
+		received <- trace.SpanContextFromContext(event.Context())
+		return nil
+	})
+
+	ctx, parent := tracer().Start(context.Background(), "parent")
+	if err := pubsub.Publish(ctx, &Event{Topic: "orders"}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	var got trace.SpanContext
+	select {
+	case got = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+	if got.TraceID() != parent.SpanContext().TraceID() {
+		t.Fatalf("event trace %s, want %s", got.TraceID(), parent.SpanContext().TraceID())
+	}
+	producer := findSpan(t, exporter, trace.SpanKindProducer)
+	if got.SpanID() == producer.SpanContext.SpanID() || !got.IsValid() {
+		t.Fatal("event.Context does not carry its own consumer span")
+	}
+}
+
+func TestSNSEvent(t *testing.T) {
+	enableTestTracing(t)
+	envelope := `{"Type":"Notification","TopicArn":"arn:aws:sns:eu-west-1:1:orders","Message":"{\"id\":1}",` +
+		`"MessageAttributes":{"traceparent":{"Type":"String","Value":"` + testTraceparent + `"},"size":{"Type":"Number","Value":"3"}}}`
+
+	tests := []struct {
+		name    string
+		msg     sqstypes.Message
+		payload string
+		carrier map[string]string
+	}{
+		{
+			name: "raw delivery",
+			msg: sqstypes.Message{
+				Body:              aws.String(`{"id":1}`),
+				MessageAttributes: map[string]sqstypes.MessageAttributeValue{"traceparent": {DataType: aws.String("String"), StringValue: aws.String(testTraceparent)}},
+			},
+			payload: `{"id":1}`,
+			carrier: map[string]string{"traceparent": testTraceparent},
+		},
+		{
+			name:    "notification envelope",
+			msg:     sqstypes.Message{Body: aws.String(envelope)},
+			payload: `{"id":1}`,
+			carrier: map[string]string{"traceparent": testTraceparent},
+		},
+		{
+			name:    "raw JSON that is not an envelope",
+			msg:     sqstypes.Message{Body: aws.String(`{"Type":"Notification"}`)},
+			payload: `{"Type":"Notification"}`,
+			carrier: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := snsEvent("arn:aws:sns:eu-west-1:1:orders", tt.msg)
+			if string(event.Payload) != tt.payload {
+				t.Errorf("payload %q, want %q", event.Payload, tt.payload)
+			}
+			if len(event.TraceContext) != len(tt.carrier) || event.TraceContext["traceparent"] != tt.carrier["traceparent"] {
+				t.Errorf("trace context %v, want %v", event.TraceContext, tt.carrier)
+			}
+			ctx := extractTraceContext(context.Background(), event.TraceContext)
+			if len(tt.carrier) > 0 && trace.SpanContextFromContext(ctx).TraceID().String() != testTraceID {
+				t.Errorf("trace context does not extract")
+			}
+		})
+	}
+}
+
+func TestSQSQueueName(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://sqs.us-east-1.amazonaws.com/123456789012/orders", want: "orders"},
+		{url: "https://sqs.eu-west-1.amazonaws.com/123456789012/orders.fifo/", want: "orders.fifo"},
+		{url: "http://localhost:4566/000000000000/jobs", want: "jobs"},
+		{url: "jobs", want: "jobs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := NewSQSJobQueue(nil, tt.url).queueName; got != tt.want {
+				t.Fatalf("queue name %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,552 @@
+package gogi
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	webSocketDefaultMaxMessageSize = 1 << 20
+	webSocketDefaultPingInterval   = 30 * time.Second
+	webSocketDefaultPongTimeout    = 60 * time.Second
+	webSocketDefaultWriteTimeout   = 10 * time.Second
+)
+
+type WebSocketMessageType int
+
+const (
+	WebSocketText   WebSocketMessageType = 1
+	WebSocketBinary WebSocketMessageType = 2
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Close codes from RFC 6455 section 7.4.1.
+const (
+	WebSocketCloseNormal          = 1000
+	WebSocketCloseGoingAway       = 1001
+	WebSocketCloseProtocolError   = 1002
+	WebSocketCloseUnsupportedData = 1003
+	WebSocketCloseNoStatus        = 1005
+	WebSocketCloseInvalidPayload  = 1007
+	WebSocketClosePolicyViolation = 1008
+	WebSocketCloseMessageTooBig   = 1009
+	WebSocketCloseInternalError   = 1011
+)
+
+var ErrWebSocketClosed = errors.New("websocket: connection closed")
+
+// WebSocketCloseError is returned by reads once a close frame has been
+// received or sent because the peer broke the protocol.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with code %d %s", e.Code, e.Reason)
+}
+
+type WebSocketHandler func(req *HTTPServerRequest, conn *WebSocketConn)
+
+// WebSocketConfig tunes a WebSocket endpoint. Zero values fall back to
+// defaults: 1 MiB messages, a ping every 30s, 60s to hear back from the
+// client and 10s per write. A negative PingInterval disables keepalive.
+// CheckOrigin defaults to accepting requests without an Origin header or
+// whose Origin host matches the request host.
+type WebSocketConfig struct {
+	MaxMessageSize int64
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	Subprotocols   []string
+	CheckOrigin    func(r *http.Request) bool
+}
+
+func WithWebSocketConfig(config WebSocketConfig) RouteOption {
+	return func(route *route) {
+		route.webSocket = &config
+	}
+}
+
+func (config WebSocketConfig) withDefaults() WebSocketConfig {
+	if config.MaxMessageSize <= 0 {
+		config.MaxMessageSize = webSocketDefaultMaxMessageSize
+	}
+	if config.PingInterval == 0 {
+		config.PingInterval = webSocketDefaultPingInterval
+	}
+	if config.PingInterval < 0 {
+		config.PongTimeout = 0
+	} else if config.PongTimeout <= 0 {
+		config.PongTimeout = webSocketDefaultPongTimeout
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = webSocketDefaultWriteTimeout
+	}
+	if config.CheckOrigin == nil {
+		config.CheckOrigin = sameOrigin
+	}
+	return config
+}
+
+type webSocketEndpoint struct {
+	httpServer *HttpServer
+	handler    WebSocketHandler
+	config     WebSocketConfig
+}
+
+func (endpoint *webSocketEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebSocket(w, r, &endpoint.config)
+	if err != nil {
+		GetLogger().Debug(fmt.Sprintf("[WebSocket] Upgrade %s failed: %v", r.URL.Path, err))
+		return
+	}
+
+	if !endpoint.httpServer.trackWebSocket(conn) {
+		conn.CloseWithReason(WebSocketCloseGoingAway, "server shutting down")
+		return
+	}
+	defer endpoint.httpServer.untrackWebSocket(conn)
+	defer conn.Close()
+
+	endpoint.handler(newServerRequest(r), conn)
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, config *WebSocketConfig) (*WebSocketConn, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("missing upgrade headers")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("invalid key")
+	}
+	if !config.CheckOrigin(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return nil, errors.New("origin not allowed")
+	}
+
+	subprotocol := selectSubprotocol(r, config.Subprotocols)
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket upgrade not supported", http.StatusInternalServerError)
+		return nil, err
+	}
+	if rw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, errors.New("client sent data before handshake completed")
+	}
+
+	// Clear deadlines set by the server's read and write timeouts.
+	netConn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	// Keep headers set by middleware, such as a request id.
+	for k, values := range w.Header() {
+		for _, v := range values {
+			b.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+
+	netConn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+	if _, err := rw.Writer.WriteString(b.String()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if err := rw.Writer.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetWriteDeadline(time.Time{})
+
+	return newWebSocketConn(r.Context(), netConn, rw.Reader, config, subprotocol), nil
+}
+
+func webSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, requested := range strings.Split(value, ",") {
+			requested = strings.TrimSpace(requested)
+			for _, s := range supported {
+				if requested == s {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// WebSocketConn is an upgraded connection. Reads must come from a single
+// goroutine; writes may be made concurrently.
+type WebSocketConn struct {
+	Subprotocol string
+
+	conn   net.Conn
+	reader *bufio.Reader
+	config *WebSocketConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	writeMu   sync.Mutex
+	closeSent bool
+	closeOnce sync.Once
+}
+
+func newWebSocketConn(parent context.Context, conn net.Conn, reader *bufio.Reader, config *WebSocketConfig, subprotocol string) *WebSocketConn {
+	ctx, cancel := context.WithCancel(parent)
+	c := &WebSocketConn{
+		Subprotocol: subprotocol,
+		conn:        conn,
+		reader:      reader,
+		config:      config,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	if config.PingInterval > 0 {
+		go c.keepalive()
+	}
+	return c
+}
+
+// Context is cancelled once the connection is closed.
+func (c *WebSocketConn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage returns the next complete data message, answering pings and
+// reassembling fragments along the way.
+func (c *WebSocketConn) ReadMessage() (WebSocketMessageType, []byte, error) {
+	var (
+		messageType WebSocketMessageType
+		message     []byte
+	)
+
+	for {
+		fin, opcode, payload, err := c.readFrame(c.config.MaxMessageSize - int64(len(message)))
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, c.fail(err)
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			closeErr, err := parseClosePayload(payload)
+			if err != nil {
+				return 0, nil, c.fail(err)
+			}
+			c.CloseWithReason(closeErr.Code, "")
+			return 0, nil, closeErr
+		case opText, opBinary:
+			if messageType != 0 {
+				return 0, nil, c.fail(&WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "expected continuation frame"})
+			}
+			messageType = WebSocketMessageType(opcode)
+		case opContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(&WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "unexpected continuation frame"})
+			}
+		default:
+			return 0, nil, c.fail(&WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "unknown opcode"})
+		}
+
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+
+		if messageType == WebSocketText && !utf8.Valid(message) {
+			return 0, nil, c.fail(&WebSocketCloseError{Code: WebSocketCloseInvalidPayload, Reason: "invalid UTF-8"})
+		}
+		return messageType, message, nil
+	}
+}
+
+func (c *WebSocketConn) WriteMessage(messageType WebSocketMessageType, data []byte) error {
+	if messageType != WebSocketText && messageType != WebSocketBinary {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.writeFrame(byte(messageType), data)
+}
+
+func (c *WebSocketConn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (c *WebSocketConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+// ReadWebSocketJSON reads the next message and decodes it into a T.
+func ReadWebSocketJSON[T any](conn *WebSocketConn) (T, error) {
+	var result T
+	err := conn.ReadJSON(&result)
+	return result, err
+}
+
+func (c *WebSocketConn) Close() error {
+	return c.CloseWithReason(WebSocketCloseNormal, "")
+}
+
+// CloseWithReason sends a close frame, unless one was already sent, and
+// closes the underlying connection.
+func (c *WebSocketConn) CloseWithReason(code int, reason string) error {
+	var payload []byte
+	if code != WebSocketCloseNoStatus {
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > 125 {
+			payload = payload[:125]
+		}
+	}
+
+	err := c.writeFrame(opClose, payload)
+	if errors.Is(err, ErrWebSocketClosed) {
+		err = nil
+	}
+	if closeErr := c.closeConn(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *WebSocketConn) closeConn() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.cancel()
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// fail closes the connection after a read error, telling the peer why when
+// the error came from a protocol violation.
+func (c *WebSocketConn) fail(err error) error {
+	var closeErr *WebSocketCloseError
+	if errors.As(err, &closeErr) {
+		c.CloseWithReason(closeErr.Code, closeErr.Reason)
+	} else {
+		c.closeConn()
+	}
+	return err
+}
+
+func (c *WebSocketConn) keepalive() {
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(opPing, nil); err != nil {
+				c.closeConn()
+				return
+			}
+		}
+	}
+}
+
+func (c *WebSocketConn) readFrame(remaining int64) (bool, byte, []byte, error) {
+	if c.config.PongTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.config.PongTimeout))
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "reserved bits set"}
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, &WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "client frames must be masked"}
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= opClose {
+		if !fin || length > 125 {
+			return false, 0, nil, &WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "invalid control frame"}
+		}
+	} else if length > uint64(max(remaining, 0)) {
+		return false, 0, nil, &WebSocketCloseError{Code: WebSocketCloseMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, 10+len(payload))
+	frame = append(frame, 0x80|opcode)
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// parseClosePayload reads the code and reason of a received close frame. A
+// payload that is one byte long, carries a code peers may not send or has
+// a reason that is not UTF-8 is returned as the error to fail with.
+func parseClosePayload(payload []byte) (*WebSocketCloseError, error) {
+	switch {
+	case len(payload) == 0:
+		return &WebSocketCloseError{Code: WebSocketCloseNoStatus}, nil
+	case len(payload) == 1:
+		return nil, &WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "truncated close code"}
+	}
+	code := int(binary.BigEndian.Uint16(payload))
+	if !validCloseCode(code) {
+		return nil, &WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "invalid close code"}
+	}
+	if !utf8.Valid(payload[2:]) {
+		return nil, &WebSocketCloseError{Code: WebSocketCloseInvalidPayload, Reason: "invalid UTF-8"}
+	}
+	return &WebSocketCloseError{Code: code, Reason: string(payload[2:])}, nil
+}
+
+// validCloseCode reports whether a peer may send code: the codes defined
+// by RFC 6455 and its registry, or one from the application range.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < 1000 || code > 1014:
+		return false
+	}
+	return code != 1004 && code != WebSocketCloseNoStatus && code != 1006
+}
@@ -0,0 +1,406 @@
+package gogi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestFrame sends a masked client frame with a zero mask key.
+func writeTestFrame(t *testing.T, w io.Writer, opcode byte, payload []byte) {
+	t.Helper()
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload)), 0, 0, 0, 0}, payload...)
+	if _, err := w.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebSocketClosePayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  []byte
+		wantCode int // 0 expects an empty close payload back
+	}{
+		{name: "empty", payload: nil},
+		{name: "normal", payload: binary.BigEndian.AppendUint16(nil, 1000), wantCode: WebSocketCloseNormal},
+		{name: "application code with reason", payload: append(binary.BigEndian.AppendUint16(nil, 4000), "bye"...), wantCode: 4000},
+		{name: "one byte", payload: []byte{0x03}, wantCode: WebSocketCloseProtocolError},
+		{name: "no status code on the wire", payload: binary.BigEndian.AppendUint16(nil, 1005), wantCode: WebSocketCloseProtocolError},
+		{name: "reserved code", payload: binary.BigEndian.AppendUint16(nil, 2000), wantCode: WebSocketCloseProtocolError},
+		{name: "invalid UTF-8 reason", payload: append(binary.BigEndian.AppendUint16(nil, 1000), 0xff), wantCode: WebSocketCloseInvalidPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &HttpServer{router: newRouter()}
+			server.addWebSocket(nil, "/ws", func(req *HTTPServerRequest, conn *WebSocketConn) {
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			})
+			ts := httptest.NewServer(server.handler())
+			defer ts.Close()
+
+			conn, br := dialTestWebSocket(t, ts, "/ws")
+			writeTestFrame(t, conn, opClose, tt.payload)
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			header := make([]byte, 2)
+			if _, err := io.ReadFull(br, header); err != nil {
+				t.Fatal(err)
+			}
+			if header[0] != 0x80|opClose {
+				t.Fatalf("frame %#x, want a close frame", header[0])
+			}
+			payload := make([]byte, header[1])
+			if _, err := io.ReadFull(br, payload); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantCode == 0 {
+				if len(payload) != 0 {
+					t.Fatalf("close payload %v, want none", payload)
+				}
+				return
+			}
+			if len(payload) < 2 {
+				t.Fatalf("close payload %v, want code %d", payload, tt.wantCode)
+			}
+			if code := int(binary.BigEndian.Uint16(payload)); code != tt.wantCode {
+				t.Fatalf("close code %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+// writeFragment sends a client frame masked with key, so the server has to
+// unmask it, using the extended length encodings when needed.
+func writeFragment(t *testing.T, w io.Writer, fin bool, opcode byte, key [4]byte, payload []byte) {
+	t.Helper()
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, key[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+	if _, err := w.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestFrame reads a server frame, which must not be masked.
+func readTestFrame(t *testing.T, conn net.Conn, br *bufio.Reader) (bool, byte, []byte) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		t.Fatalf("reading frame: %v", err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("server frame is masked")
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		io.ReadFull(br, ext)
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		io.ReadFull(br, ext)
+		length = binary.BigEndian.Uint64(ext)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatalf("reading payload: %v", err)
+	}
+	return header[0]&0x80 != 0, header[0] & 0x0f, payload
+}
+
+func newEchoWebSocketServer(t *testing.T, config WebSocketConfig) (*HttpServer, *httptest.Server) {
+	t.Helper()
+	server := &HttpServer{router: newRouter()}
+	server.addWebSocket(nil, "/ws", func(req *HTTPServerRequest, conn *WebSocketConn) {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				return
+			}
+		}
+	}, WithWebSocketConfig(config))
+	ts := httptest.NewServer(server.handler())
+	t.Cleanup(ts.Close)
+	return server, ts
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := &HttpServer{router: newRouter()}
+	server.addWebSocket(nil, "/ws", func(req *HTTPServerRequest, conn *WebSocketConn) {
+		conn.WriteMessage(WebSocketText, []byte(conn.Subprotocol))
+	}, WithWebSocketConfig(WebSocketConfig{Subprotocols: []string{"chat.v2", "chat.v1"}}))
+	ts := httptest.NewServer(server.handler())
+	defer ts.Close()
+
+	valid := map[string]string{
+		"Upgrade":               "websocket",
+		"Connection":            "keep-alive, Upgrade",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+		"Sec-WebSocket-Version": "13",
+	}
+	with := func(changes map[string]string) map[string]string {
+		headers := maps.Clone(valid)
+		for k, v := range changes {
+			if v == "" {
+				delete(headers, k)
+			} else {
+				headers[k] = v
+			}
+		}
+		return headers
+	}
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		status       int
+		wantProtocol string
+	}{
+		{name: "valid", headers: valid, status: http.StatusSwitchingProtocols},
+		{name: "same origin", headers: with(map[string]string{"Origin": "http://test"}), status: http.StatusSwitchingProtocols},
+		{name: "subprotocol", headers: with(map[string]string{"Sec-WebSocket-Protocol": "chat.v0, chat.v1"}), status: http.StatusSwitchingProtocols, wantProtocol: "chat.v1"},
+		{name: "no upgrade", headers: with(map[string]string{"Upgrade": ""}), status: http.StatusUpgradeRequired},
+		{name: "no connection upgrade", headers: with(map[string]string{"Connection": "keep-alive"}), status: http.StatusUpgradeRequired},
+		{name: "old version", headers: with(map[string]string{"Sec-WebSocket-Version": "8"}), status: http.StatusUpgradeRequired},
+		{name: "short key", headers: with(map[string]string{"Sec-WebSocket-Key": "c2hvcnQ="}), status: http.StatusBadRequest},
+		{name: "cross origin", headers: with(map[string]string{"Origin": "http://evil.example"}), status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			var b strings.Builder
+			b.WriteString("GET /ws HTTP/1.1\r\nHost: test\r\n")
+			for k, v := range tt.headers {
+				b.WriteString(k + ": " + v + "\r\n")
+			}
+			b.WriteString("\r\n")
+			conn.Write([]byte(b.String()))
+
+			br := bufio.NewReader(conn)
+			res, err := http.ReadResponse(br, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", res.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusSwitchingProtocols {
+				return
+			}
+			if got := res.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+				t.Errorf("Sec-WebSocket-Accept %q, want the RFC 6455 example", got)
+			}
+			if got := res.Header.Get("Sec-WebSocket-Protocol"); got != tt.wantProtocol {
+				t.Errorf("Sec-WebSocket-Protocol %q, want %q", got, tt.wantProtocol)
+			}
+			if _, opcode, payload := readTestFrame(t, conn, br); opcode != opText || string(payload) != tt.wantProtocol {
+				t.Errorf("frame %#x %q, want the handler to see subprotocol %q", opcode, payload, tt.wantProtocol)
+			}
+		})
+	}
+}
+
+func TestWebSocketMessages(t *testing.T) {
+	_, ts := newEchoWebSocketServer(t, WebSocketConfig{})
+	conn, br := dialTestWebSocket(t, ts, "/ws")
+	key := [4]byte{0x37, 0xfa, 0x21, 0x3d}
+
+	// A masked text message comes back unmasked.
+	writeFragment(t, conn, true, opText, key, []byte("Hello"))
+	if fin, opcode, payload := readTestFrame(t, conn, br); !fin || opcode != opText || string(payload) != "Hello" {
+		t.Fatalf("echo %v %#x %q, want the text message", fin, opcode, payload)
+	}
+
+	// Fragments are reassembled, with a ping answered in between.
+	writeFragment(t, conn, false, opBinary, key, []byte{1, 2})
+	writeFragment(t, conn, true, opPing, key, []byte("are you there"))
+	writeFragment(t, conn, false, opContinuation, key, []byte{3})
+	writeFragment(t, conn, true, opContinuation, key, []byte{4, 5})
+	if _, opcode, payload := readTestFrame(t, conn, br); opcode != opPong || string(payload) != "are you there" {
+		t.Fatalf("frame %#x %q, want a pong echoing the ping", opcode, payload)
+	}
+	if _, opcode, payload := readTestFrame(t, conn, br); opcode != opBinary || !bytes.Equal(payload, []byte{1, 2, 3, 4, 5}) {
+		t.Fatalf("frame %#x %v, want the reassembled binary message", opcode, payload)
+	}
+
+	// Unsolicited pongs are ignored and lengths past 125 use extended encodings.
+	large := bytes.Repeat([]byte("x"), 70000)
+	writeFragment(t, conn, true, opPong, key, nil)
+	writeFragment(t, conn, true, opText, key, large)
+	if _, opcode, payload := readTestFrame(t, conn, br); opcode != opText || !bytes.Equal(payload, large) {
+		t.Fatalf("frame %#x of %d bytes, want the %d byte message", opcode, len(payload), len(large))
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	key := [4]byte{1, 2, 3, 4}
+	tests := []struct {
+		name     string
+		send     func(t *testing.T, w io.Writer)
+		wantCode int
+	}{
+		{
+			name:     "unmasked frame",
+			send:     func(t *testing.T, w io.Writer) { w.Write([]byte{0x80 | opText, 2, 'h', 'i'}) },
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name:     "reserved bits",
+			send:     func(t *testing.T, w io.Writer) { writeFragment(t, w, true, 0x40|opText, key, []byte("hi")) },
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name:     "unknown opcode",
+			send:     func(t *testing.T, w io.Writer) { writeFragment(t, w, true, 0x3, key, nil) },
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name:     "fragmented control frame",
+			send:     func(t *testing.T, w io.Writer) { writeFragment(t, w, false, opPing, key, nil) },
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name:     "control frame over 125 bytes",
+			send:     func(t *testing.T, w io.Writer) { writeFragment(t, w, true, opPing, key, make([]byte, 126)) },
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name:     "continuation without a message",
+			send:     func(t *testing.T, w io.Writer) { writeFragment(t, w, true, opContinuation, key, []byte("x")) },
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name: "new message inside a fragmented one",
+			send: func(t *testing.T, w io.Writer) {
+				writeFragment(t, w, false, opText, key, []byte("a"))
+				writeFragment(t, w, true, opText, key, []byte("b"))
+			},
+			wantCode: WebSocketCloseProtocolError,
+		},
+		{
+			name:     "invalid UTF-8 text",
+			send:     func(t *testing.T, w io.Writer) { writeFragment(t, w, true, opText, key, []byte{0xff, 0xfe}) },
+			wantCode: WebSocketCloseInvalidPayload,
+		},
+		{
+			name:     "message over the read limit",
+			send:     func(t *testing.T, w io.Writer) { writeFragment(t, w, true, opBinary, key, make([]byte, 65)) },
+			wantCode: WebSocketCloseMessageTooBig,
+		},
+		{
+			name: "fragments over the read limit",
+			send: func(t *testing.T, w io.Writer) {
+				writeFragment(t, w, false, opBinary, key, make([]byte, 40))
+				writeFragment(t, w, true, opContinuation, key, make([]byte, 40))
+			},
+			wantCode: WebSocketCloseMessageTooBig,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts := newEchoWebSocketServer(t, WebSocketConfig{MaxMessageSize: 64})
+			conn, br := dialTestWebSocket(t, ts, "/ws")
+			tt.send(t, conn)
+
+			_, opcode, payload := readTestFrame(t, conn, br)
+			if opcode != opClose || len(payload) < 2 {
+				t.Fatalf("frame %#x %v, want a close frame with a code", opcode, payload)
+			}
+			if code := int(binary.BigEndian.Uint16(payload)); code != tt.wantCode {
+				t.Fatalf("close code %d, want %d", code, tt.wantCode)
+			}
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			if _, err := br.ReadByte(); err != io.EOF {
+				t.Fatalf("read after close: %v, want the connection closed", err)
+			}
+		})
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	_, ts := newEchoWebSocketServer(t, WebSocketConfig{PingInterval: 20 * time.Millisecond, PongTimeout: 200 * time.Millisecond})
+	conn, br := dialTestWebSocket(t, ts, "/ws")
+
+	if _, opcode, _ := readTestFrame(t, conn, br); opcode != opPing {
+		t.Fatalf("frame %#x, want a ping", opcode)
+	}
+
+	// Without a pong or any other frame, the server gives up after PongTimeout.
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, err := br.ReadByte(); err != nil {
+			if err != io.EOF {
+				t.Fatalf("read: %v, want the server to close the connection", err)
+			}
+			break
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("connection closed after %v, want about the PongTimeout", elapsed)
+	}
+}
+
+func TestShutdownClosesWebSockets(t *testing.T) {
+	server, ts := newEchoWebSocketServer(t, WebSocketConfig{})
+	server.server = ts.Config
+	conn, br := dialTestWebSocket(t, ts, "/ws")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	_, opcode, payload := readTestFrame(t, conn, br)
+	if opcode != opClose || len(payload) < 2 || binary.BigEndian.Uint16(payload) != WebSocketCloseGoingAway {
+		t.Fatalf("frame %#x %v, want a 1001 close", opcode, payload)
+	}
+	if string(payload[2:]) != "server shutting down" {
+		t.Errorf("close reason %q", payload[2:])
+	}
+
+	// Connections upgraded after shutdown are turned away.
+	if server.trackWebSocket(&WebSocketConn{}) {
+		t.Fatal("a new WebSocket was tracked after shutdown")
+	}
+}